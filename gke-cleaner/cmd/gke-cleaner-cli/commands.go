@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/client"
+)
+
+var commands = map[string]command{
+	"list":     {args: 0, setup: listCommand},
+	"get":      {args: 1, setup: getCommand},
+	"renew":    {args: 1, setup: renewCommand},
+	"ignore":   {args: 1, setup: ignoreCommand},
+	"unignore": {args: 1, setup: unignoreCommand},
+	"action":   {args: 2, setup: actionCommand},
+	"restore":  {args: 1, setup: restoreCommand},
+	"history":  {args: 1, setup: historyCommand},
+	"sync":     {args: 0, setup: syncCommand},
+	"reload":   {args: 0, setup: reloadCommand},
+	"breaker":  {args: 0, setup: breakerCommand},
+	"arm":      {args: 0, setup: armCommand},
+	"savings":  {args: 0, setup: savingsCommand},
+	"digest":   {args: 0, setup: digestCommand},
+}
+
+func listCommand(fs *flag.FlagSet) runFunc {
+	kind := fs.String("kind", "", "only list resources of this kind, e.g. compute-instance")
+	owner := fs.String("owner", "", "only list resources owned by this owner, e.g. jane@example.com")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		var clusters []api.Cluster
+		var err error
+		switch {
+		case *owner != "":
+			clusters, err = c.ListByOwner(ctx, *owner)
+		case *kind != "":
+			clusters, err = c.ListByKind(ctx, *kind)
+		default:
+			clusters, err = c.List(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		if *owner != "" && *kind != "" {
+			matching := []api.Cluster{}
+			for _, cluster := range clusters {
+				if cluster.Kind == *kind {
+					matching = append(matching, cluster)
+				}
+			}
+			clusters = matching
+		}
+
+		return printClusters(w, output, clusters)
+	}
+}
+
+func getCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+func renewCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+	renewRequest := renewFlags(fs)
+	justification := fs.String("justification", "", "why the cluster needs renewing beyond the renewal limits")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		request, err := renewRequest()
+		if err != nil {
+			return err
+		}
+		request.Justification = *justification
+
+		err = c.Renew(ctx, *kind, args[0], request)
+		if err != nil {
+			return err
+		}
+
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+func restoreCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+	renewRequest := renewFlags(fs)
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		request, err := renewRequest()
+		if err != nil {
+			return err
+		}
+
+		err = c.Restore(ctx, *kind, args[0], request)
+		if err != nil {
+			return err
+		}
+
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+func actionCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		action := args[1]
+		if action == "default" {
+			action = ""
+		}
+
+		err := c.SetExpiryAction(ctx, *kind, args[0], api.ActionRequest{Action: action})
+		if err != nil {
+			return err
+		}
+
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+// kindFlag is the kind of the named resource, needed only when resources of
+// more than one kind have the name.
+func kindFlag(fs *flag.FlagSet) *string {
+	return fs.String("kind", "", "the kind of the resource, when resources of more than one kind have its name")
+}
+
+func renewFlags(fs *flag.FlagSet) func() (api.RenewRequest, error) {
+	duration := fs.Duration("for", 0, "renew the cluster for this duration from now, e.g. 4h")
+	until := fs.String("until", "", "renew the cluster until this RFC3339 time")
+
+	return func() (api.RenewRequest, error) {
+		request := api.RenewRequest{}
+		switch {
+		case *duration != 0 && *until != "":
+			return api.RenewRequest{}, errors.New("only one of -for or -until may be set")
+		case *duration != 0:
+			request.Duration = duration.String()
+		case *until != "":
+			t, err := time.Parse(time.RFC3339, *until)
+			if err != nil {
+				return api.RenewRequest{}, fmt.Errorf("failed to parse -until: %s", err)
+			}
+			request.Until = t
+		}
+
+		return request, nil
+	}
+}
+
+func ignoreCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+	until := fs.String("until", "", "ignore the cluster until this RFC3339 time")
+	reason := fs.String("reason", "", "why the cluster is being ignored")
+	by := fs.String("by", "", "who is ignoring the cluster, defaults to the username, only an admin can set it")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		request := api.IgnoreRequest{Reason: *reason, By: *by}
+		if *until != "" {
+			t, err := time.Parse(time.RFC3339, *until)
+			if err != nil {
+				return fmt.Errorf("failed to parse -until: %s", err)
+			}
+			request.Until = t
+		}
+
+		err := c.Ignore(ctx, *kind, args[0], request)
+		if err != nil {
+			return err
+		}
+
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+func unignoreCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		err := c.Unignore(ctx, *kind, args[0])
+		if err != nil {
+			return err
+		}
+
+		return showCluster(ctx, c, w, output, *kind, args[0])
+	}
+}
+
+func historyCommand(fs *flag.FlagSet) runFunc {
+	kind := kindFlag(fs)
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		events, err := c.History(ctx, *kind, args[0])
+		if err != nil {
+			return err
+		}
+
+		return printEvents(w, output, events)
+	}
+}
+
+func syncCommand(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		err := c.Sync(ctx)
+		if err != nil {
+			return err
+		}
+
+		clusters, err := c.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printClusters(w, output, clusters)
+	}
+}
+
+func reloadCommand(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		changes, err := c.Reload(ctx)
+		if err != nil {
+			return err
+		}
+
+		if output != outputTable {
+			return printStructured(w, output, api.ReloadResponse{Changes: changes})
+		}
+
+		if len(changes) == 0 {
+			_, err = fmt.Fprintln(w, "No changes")
+			return err
+		}
+
+		for _, change := range changes {
+			_, err = fmt.Fprintln(w, change)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func breakerCommand(fs *flag.FlagSet) runFunc {
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		breaker, err := c.Breaker(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printBreaker(w, output, breaker)
+	}
+}
+
+func armCommand(fs *flag.FlagSet) runFunc {
+	reason := fs.String("reason", "", "why the breaker is being re-armed")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		breaker, err := c.Arm(ctx, api.ArmRequest{Reason: *reason})
+		if err != nil {
+			return err
+		}
+
+		return printBreaker(w, output, breaker)
+	}
+}
+
+func showCluster(ctx context.Context, c *client.Client, w io.Writer, output string, kind string, name string) error {
+	cluster, err := c.Get(ctx, kind, name)
+	if err != nil {
+		return err
+	}
+
+	if output != outputTable {
+		return printStructured(w, output, cluster)
+	}
+
+	return printClusters(w, output, []api.Cluster{cluster})
+}
+
+func savingsCommand(fs *flag.FlagSet) runFunc {
+	from := fs.String("from", "", "start of the report as an RFC3339 time, defaults to 30 days before -to")
+	to := fs.String("to", "", "end of the report as an RFC3339 time, defaults to now")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		fromTime, toTime, err := parseRange(*from, *to)
+		if err != nil {
+			return err
+		}
+
+		report, err := c.Savings(ctx, fromTime, toTime)
+		if err != nil {
+			return err
+		}
+
+		return printSavings(w, output, report)
+	}
+}
+
+func digestCommand(fs *flag.FlagSet) runFunc {
+	format := fs.String("format", "", "markdown or html, defaults to the backend's digest format")
+	from := fs.String("from", "", "start of the digest as an RFC3339 time, defaults to one digest period before -to")
+	to := fs.String("to", "", "end of the digest as an RFC3339 time, defaults to now")
+
+	return func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error {
+		fromTime, toTime, err := parseRange(*from, *to)
+		if err != nil {
+			return err
+		}
+
+		digest, err := c.Digest(ctx, *format, fromTime, toTime)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, digest)
+		return err
+	}
+}
+
+// parseRange parses the -from and -to flags of a report, leaving empty flags
+// zero.
+func parseRange(from string, to string) (time.Time, time.Time, error) {
+	var fromTime, toTime time.Time
+	if from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse -from: %s", err)
+		}
+		fromTime = t
+	}
+	if to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse -to: %s", err)
+		}
+		toTime = t
+	}
+
+	return fromTime, toTime, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+type cliConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "gke-cleaner", "cli.yml")
+}
+
+func loadConfig(path string) (cliConfig, error) {
+	var cfg cliConfig
+
+	explicit := path != ""
+	if envPath, ok := os.LookupEnv("GKE_CLEANER_CONFIG"); ok && !explicit {
+		path = envPath
+		explicit = true
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+
+	if path != "" {
+		contents, err := ioutil.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return cliConfig{}, fmt.Errorf("failed to read config file: %s", err)
+		default:
+			err = yaml.UnmarshalStrict(contents, &cfg)
+			if err != nil {
+				return cliConfig{}, fmt.Errorf("failed to parse config file %s: %s", path, err)
+			}
+		}
+	}
+
+	if url, ok := os.LookupEnv("GKE_CLEANER_URL"); ok {
+		cfg.URL = url
+	}
+	if username, ok := os.LookupEnv("GKE_CLEANER_USERNAME"); ok {
+		cfg.Username = username
+	}
+	if password, ok := os.LookupEnv("GKE_CLEANER_PASSWORD"); ok {
+		cfg.Password = password
+	}
+	if token, ok := os.LookupEnv("GKE_CLEANER_TOKEN"); ok {
+		cfg.Token = token
+	}
+
+	if cfg.URL == "" {
+		return cliConfig{}, errors.New("no backend url configured: set GKE_CLEANER_URL or url in the config file")
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets or, when value is nil, unsets an environment variable for the
+// test.
+func setenv(t *testing.T, key string, value *string) {
+	previous, found := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if found {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+
+	if value == nil {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, *value)
+	}
+}
+
+func writeConfig(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "gke-cleaner-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "cli.yml")
+	err = ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	envURL := "https://env.example.com"
+	envUsername := "env-user"
+
+	tests := []struct {
+		name     string
+		file     string
+		url      *string
+		username *string
+		expected cliConfig
+		err      bool
+	}{
+		{
+			name:     "from the file",
+			file:     "url: https://file.example.com\nusername: file-user\npassword: file-secret\n",
+			expected: cliConfig{URL: "https://file.example.com", Username: "file-user", Password: "file-secret"},
+		},
+		{
+			name:     "the environment over the file",
+			file:     "url: https://file.example.com\nusername: file-user\npassword: file-secret\n",
+			url:      &envURL,
+			username: &envUsername,
+			expected: cliConfig{URL: envURL, Username: envUsername, Password: "file-secret"},
+		},
+		{
+			name: "an unknown key",
+			file: "url: https://file.example.com\nuser: file-user\n",
+			err:  true,
+		},
+		{
+			name: "without a url",
+			file: "username: file-user\n",
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, "GKE_CLEANER_CONFIG", nil)
+			setenv(t, "GKE_CLEANER_URL", test.url)
+			setenv(t, "GKE_CLEANER_USERNAME", test.username)
+			setenv(t, "GKE_CLEANER_PASSWORD", nil)
+			setenv(t, "GKE_CLEANER_TOKEN", nil)
+
+			cfg, err := loadConfig(writeConfig(t, test.file))
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	url := "https://env.example.com"
+	setenv(t, "GKE_CLEANER_URL", &url)
+
+	_, err := loadConfig(filepath.Join(os.TempDir(), "gke-cleaner-cli-missing.yml"))
+	if err == nil {
+		t.Error("expected an error for a config file that was passed but doesn't exist")
+	}
+}
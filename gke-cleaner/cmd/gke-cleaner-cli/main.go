@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/client"
+)
+
+const usage = `Usage: gke-cleaner-cli [-config path] <command> [flags] [args]
+
+Commands:
+  list [-kind kind] [-owner owner]       List all known clusters and other resources
+  get <name>                             Show a single cluster
+  renew [-for 4h | -until time] [-justification text] <name>
+                                         Renew a cluster
+  ignore [-until time] [-reason text] [-by name] <name>
+                                         Ignore a cluster
+  unignore <name>                        Stop ignoring a cluster
+  action <name> <action>                 Set what happens when a cluster expires:
+                                         delete, scale-to-zero, notify-only or default
+  restore [-for 4h | -until time] <name>
+                                         Scale a scaled-down cluster back up and renew it
+  history <name>                         Show the events recorded for a cluster
+  sync                                   Sync clusters with GKE immediately
+  reload                                 Reload the backend's policy config
+  breaker                                Show the deletion circuit breaker
+  arm [-reason text]                     Re-arm a tripped deletion circuit breaker
+  savings [-from time] [-to time]        Show what deleted clusters saved
+  digest [-format markdown|html] [-from time] [-to time]
+                                         Render the digest of expiring, ignored,
+                                         old, deleted clusters and failures
+
+Every command accepts -o table|json|yaml. Times are RFC3339. Commands taking
+a <name> accept -kind kind when resources of more than one kind have the name.
+
+Credentials are read from GKE_CLEANER_URL, GKE_CLEANER_USERNAME,
+GKE_CLEANER_PASSWORD and GKE_CLEANER_TOKEN, which override the config file
+(GKE_CLEANER_CONFIG or -config, defaulting to the user config directory's
+gke-cleaner/cli.yml).
+`
+
+type runFunc func(ctx context.Context, c *client.Client, w io.Writer, output string, args []string) error
+
+type command struct {
+	args  int
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	global := flag.NewFlagSet("gke-cleaner-cli", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	configPath := global.String("config", "", "path to the cli config file")
+
+	err := global.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", outputTable, "output format: table, json or yaml")
+	runCommand := cmd.setup(fs)
+
+	positional, err := parseInterspersed(fs, global.Args()[1:])
+	if err != nil {
+		return 2
+	}
+
+	if len(positional) != cmd.args {
+		fmt.Fprintf(stderr, "%s expects %d argument(s), got %d\n\n%s", name, cmd.args, len(positional), usage)
+		return 2
+	}
+
+	err = validateOutput(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	c := &client.Client{
+		URL:  cfg.URL,
+		Auth: client.BasicAuth{Username: cfg.Username, Password: cfg.Password},
+	}
+	if cfg.Token != "" {
+		c.Auth = client.BearerToken{Token: cfg.Token}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	err = runCommand(ctx, c, stdout, *output, positional)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validateOutput(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q: must be one of table, json, yaml", format)
+	}
+}
+
+func printStructured(w io.Writer, format string, v interface{}) error {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if format == outputJSON {
+		_, err = fmt.Fprintln(w, string(body))
+		return err
+	}
+
+	var generic interface{}
+	err = yaml.Unmarshal(body, &generic)
+	if err != nil {
+		return err
+	}
+
+	body, err = yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+func printClusters(w io.Writer, format string, clusters []api.Cluster) error {
+	if format != outputTable {
+		return printStructured(w, format, clusters)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKIND\tOWNER\tSTATUS\tCREATED\tEXPIRES\tDELETES\tRENEWALS\tACTION\tIGNORED\tREASON")
+	for _, cluster := range clusters {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			cluster.Name,
+			cluster.Kind,
+			formatOwner(cluster.Owner),
+			formatStatus(cluster),
+			formatTime(cluster.CreateDate),
+			formatTime(cluster.ExpirationDate),
+			formatTime(cluster.DeletionDate),
+			formatRenewals(cluster),
+			formatAction(cluster),
+			formatIgnore(cluster),
+			cluster.IgnoreReason,
+		)
+	}
+
+	return tw.Flush()
+}
+
+func printEvents(w io.Writer, format string, events []api.Event) error {
+	if format != outputTable {
+		return printStructured(w, format, events)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTYPE\tMESSAGE")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", formatTime(event.CreateDate), event.Type, event.Message)
+	}
+
+	return tw.Flush()
+}
+
+func printBreaker(w io.Writer, format string, breaker api.Breaker) error {
+	if format != outputTable {
+		return printStructured(w, format, breaker)
+	}
+
+	state := "armed"
+	if breaker.Tripped {
+		state = "tripped"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATE\tSINCE\tREASON")
+	fmt.Fprintf(tw, "%s\t%s\t%s\n", state, formatTime(breaker.UpdatedAt), breaker.Reason)
+
+	return tw.Flush()
+}
+
+func printSavings(w io.Writer, format string, report api.SavingsReport) error {
+	if format != outputTable {
+		return printStructured(w, format, report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tKIND\tOWNER\tDELETED\tHOURLY\tHOURS\tSAVED")
+	for _, saving := range report.Deletions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.2f\t%.1f\t%.2f\n", saving.ClusterName, saving.Kind, saving.Owner, formatTime(saving.DeleteDate), saving.HourlyCost, saving.Hours, saving.Saved)
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t\t\t\t\t%.2f %s\n", report.Saved, report.Currency)
+
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatStatus(cluster api.Cluster) string {
+	if cluster.Idle {
+		return cluster.Status + " (idle)"
+	}
+
+	return cluster.Status
+}
+
+func formatOwner(owner string) string {
+	if owner == "" {
+		return "-"
+	}
+
+	return owner
+}
+
+func formatAction(cluster api.Cluster) string {
+	if cluster.ActionTaken != "" {
+		return cluster.ActionTaken + " (taken)"
+	}
+
+	return cluster.ExpiryAction
+}
+
+func formatRenewals(cluster api.Cluster) string {
+	if cluster.RenewalLimitReached {
+		return fmt.Sprintf("%d (limit)", cluster.RenewalCount)
+	}
+
+	return strconv.Itoa(cluster.RenewalCount)
+}
+
+func formatIgnore(cluster api.Cluster) string {
+	switch {
+	case cluster.Protected:
+		return "protected"
+	case !cluster.Ignore:
+		return "no"
+	case cluster.IgnoreUntil.IsZero():
+		return "yes"
+	default:
+		return "until " + formatTime(cluster.IgnoreUntil)
+	}
+}
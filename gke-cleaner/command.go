@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/poller"
+	"github.com/christianang/gke-cleaner/pkg/recovery"
+	"github.com/christianang/gke-cleaner/pkg/snapshot"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+
+	container "cloud.google.com/go/container/apiv1"
+)
+
+const commandUsage = `Usage: gke-cleaner [-config path] [command]
+
+Without a command the backend is started.
+
+Commands:
+  config validate   Load and validate the config, then print the effective
+                    config with secrets redacted
+  export [-format json|csv] [-out path]
+                    Write every stored cluster to stdout or a file
+  import [-format json|csv] [-strategy skip|overwrite|merge] <path>
+                    Insert the clusters of an export into the store and
+                    resolve clusters that are already stored with the
+                    strategy, which defaults to skip
+  recover [-from path] [-apply]
+                    Rebuild the expiration, ignore and owner of clusters from
+                    their GKE state labels, or from an export file, and print
+                    the changes to the store. The changes are only written
+                    with -apply
+`
+
+func runCommand(args []string, configPath string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "validate" {
+		return validateConfig(configPath)
+	}
+	if len(args) > 0 && args[0] == "export" {
+		return exportClusters(args[1:], configPath)
+	}
+	if len(args) > 0 && args[0] == "import" {
+		return importClusters(args[1:], configPath)
+	}
+	if len(args) > 0 && args[0] == "recover" {
+		return recoverClusters(args[1:], configPath)
+	}
+
+	fmt.Fprint(os.Stderr, commandUsage)
+	return 2
+}
+
+func validateConfig(configPath string) int {
+	cfg, err := config.Load(zapr.NewLogger(zap.NewNop()), configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	out, err := cfg.RedactedYAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render config: %s\n", err)
+		return 1
+	}
+
+	fmt.Print(string(out))
+	return 0
+}
+
+// openStore opens the configured database directly, so commands work without
+// the backend running.
+func openStore(log logr.Logger, configPath string) (config.Config, *store.DB, error) {
+	cfg, err := config.Load(log, configPath)
+	if err != nil {
+		return config.Config{}, nil, err
+	}
+
+	db, err := store.Open(cfg.DatabaseDriver, cfg.DatabaseURI)
+	if err != nil {
+		return config.Config{}, nil, fmt.Errorf("failed to open connection to database: %s", err)
+	}
+
+	err = (&migrate.DB{Log: log, DB: db}).Migrate()
+	if err != nil {
+		db.Close()
+		return config.Config{}, nil, err
+	}
+
+	return cfg, db, nil
+}
+
+func exportClusters(args []string, configPath string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "json or csv, defaults to the -out extension or json")
+	out := fs.String("out", "", "path to write the export to instead of stdout")
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+
+	if *format == "" {
+		*format = snapshot.FormatFor(*out)
+	}
+	err = snapshot.ValidateFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 2
+	}
+
+	_, db, err := openStore(zapr.NewLogger(zap.NewNop()), configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	clusters, err := (&store.Cluster{DB: db}).List(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list clusters: %s\n", err)
+		return 1
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create export: %s\n", err)
+			return 1
+		}
+		defer w.Close()
+	}
+
+	err = snapshot.Write(w, *format, clusters)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write export: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func importClusters(args []string, configPath string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "json or csv, defaults to the file extension")
+	strategy := fs.String("strategy", snapshot.StrategySkip, "how to resolve clusters that are already stored: skip, overwrite or merge")
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = snapshot.FormatFor(path)
+	}
+	err = snapshot.ValidateFormat(*format)
+	if err == nil {
+		err = snapshot.ValidateStrategy(*strategy)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open export: %s\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	records, err := snapshot.Read(file, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	_, db, err := openStore(zapr.NewLogger(zap.NewNop()), configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	response, err := snapshot.Import(ctx, &store.Cluster{DB: db}, records, *strategy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	message := fmt.Sprintf("%d inserted, %d updated, %d skipped with the %s strategy", len(response.Inserted), len(response.Updated), len(response.Skipped), *strategy)
+	err = (&store.Event{DB: db}).Insert(ctx, "", "", store.EventImported, message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to record event: %s\n", err)
+	}
+	fmt.Println(message)
+
+	return 0
+}
+
+func recoverClusters(args []string, configPath string) int {
+	fs := flag.NewFlagSet("recover", flag.ContinueOnError)
+	from := fs.String("from", "", "path to an export file to recover from instead of the gke labels")
+	apply := fs.Bool("apply", false, "write the changes to the store")
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+
+	log := zapr.NewLogger(zap.NewNop())
+	cfg, db, err := openStore(log, configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	source := "gke labels"
+	var recovered []store.ClusterRecord
+	if *from != "" {
+		source = *from
+		file, err := os.Open(*from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open export: %s\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		recovered, err = snapshot.Read(file, snapshot.FormatFor(*from))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	} else {
+		recovered, err = recoverFromLabels(ctx, log, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+
+	clusterStore := &store.Cluster{DB: db}
+	existing, err := clusterStore.List(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list clusters: %s\n", err)
+		return 1
+	}
+
+	changes := recovery.Diff(existing, recovered)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	fmt.Printf("%d of %d recovered clusters differ from the store\n", len(changes), len(recovered))
+
+	if !*apply {
+		if len(changes) > 0 {
+			fmt.Println("Dry run, rerun with -apply to write the changes")
+		}
+		return 0
+	}
+
+	err = recovery.Apply(ctx, db, changes, source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	fmt.Println("Applied")
+
+	return 0
+}
+
+func recoverFromLabels(ctx context.Context, log logr.Logger, cfg config.Config) ([]store.ClusterRecord, error) {
+	err := cfg.SetupGCPCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup gcp credentials: %s", err)
+	}
+
+	client, err := container.NewClusterManagerClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcloud cluster manager client: %s", err)
+	}
+	defer client.Close()
+
+	gkePoller := &poller.GKE{
+		Log:      log,
+		Client:   client,
+		Owners:   &owner.Resolver{Labels: cfg.OwnerLabels, Mappings: cfg.OwnerMappings},
+		Policy:   policy.NewStore(cfg.Policy()),
+		Projects: cfg.Projects,
+	}
+
+	return gkePoller.RecoverFromLabels(ctx)
+}
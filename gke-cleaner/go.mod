@@ -4,17 +4,22 @@ go 1.14
 
 require (
 	cloud.google.com/go v0.57.0
+	cloud.google.com/go/pubsub v1.3.1
 	github.com/go-logr/logr v0.1.0
 	github.com/go-logr/zapr v0.1.1
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/protobuf v1.4.1 // indirect
 	github.com/google/martian v2.1.0+incompatible
 	github.com/gorilla/mux v1.7.4
+	github.com/lib/pq v1.7.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
 	github.com/tedsuo/ifrit v0.0.0-20191009134036-9a97d0632f00
 	go.uber.org/zap v1.15.0
 	golang.org/x/net v0.0.0-20200506145744-7e3656a0809f // indirect
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/sys v0.0.0-20200501145240-bc7a7d42d5c3 // indirect
 	google.golang.org/api v0.23.0
 	google.golang.org/genproto v0.0.0-20200507105951-43844f6eee31
+	google.golang.org/grpc v1.29.1
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -2,28 +2,39 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/digest"
 	"github.com/christianang/gke-cleaner/pkg/handler"
+	"github.com/christianang/gke-cleaner/pkg/idle"
+	"github.com/christianang/gke-cleaner/pkg/janitor"
+	"github.com/christianang/gke-cleaner/pkg/leader"
 	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/nodepool"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/policy"
 	"github.com/christianang/gke-cleaner/pkg/poller"
+	"github.com/christianang/gke-cleaner/pkg/provider"
 	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/christianang/gke-cleaner/pkg/subscriber"
 	"github.com/go-logr/zapr"
-	"github.com/gorilla/mux"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
 	"github.com/tedsuo/ifrit/sigmon"
 	"go.uber.org/zap"
 
 	container "cloud.google.com/go/container/apiv1"
+	"cloud.google.com/go/pubsub"
 	ifrithttpserver "github.com/tedsuo/ifrit/http_server"
-
-	_ "github.com/go-sql-driver/mysql"
+	compute "google.golang.org/api/compute/v1"
+	logging "google.golang.org/api/logging/v2"
 )
 
 func main() {
@@ -35,29 +46,49 @@ func main() {
 
 	log := zapr.NewLogger(zapLog)
 
-	cfg, err := config.LoadFromEnv(log.WithName("config.LoadFromEnv"))
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a yaml config file")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args(), *configPath))
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	cfg, err := config.Load(log.WithName("config.Load"), *configPath)
 	if err != nil {
 		log.WithName("main").Error(err, "failed to load config")
 		os.Exit(1)
 	}
 
-	dbService, ok := cfg.GetUserProvidedVCAPServiceByBindingName("db")
-	if !ok {
-		log.WithName("main").Error(err, "failed to find user provided vcap service with binding name 'db' in VCAP_SERVICES")
+	err = cfg.SetupGCPCredentials()
+	if err != nil {
+		log.WithName("main").Error(err, "failed to setup gcp credentials")
 		os.Exit(1)
 	}
 
-	dbURI, ok := dbService.Credentials["uri"]
-	if !ok {
-		log.WithName("main").Error(err, "failed to find uri in credentials of user provided service with binding name 'db'")
+	notifier, err := notify.NewNotifier(log.WithName("notify.Notifier"), cfg.NotificationSinks)
+	if err != nil {
+		log.WithName("main").Error(err, "failed to setup notifications")
 		os.Exit(1)
 	}
 
-	db, err := sql.Open("mysql", fmt.Sprintf("%s?parseTime=true", dbURI))
+	db, err := store.Open(cfg.DatabaseDriver, cfg.DatabaseURI)
 	if err != nil {
 		log.WithName("main").Error(err, "failed to open connection to database")
 		os.Exit(1)
 	}
+	if cfg.DatabaseMaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.DatabaseMaxOpenConns)
+	}
+	if cfg.DatabaseMaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.DatabaseMaxIdleConns)
+	}
+	if cfg.DatabaseConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.DatabaseConnMaxLifetime)
+	}
+	db.Timeout = cfg.DatabaseCallTimeout
 
 	migrateDB := &migrate.DB{
 		Log: log.WithName("migrate.DB"),
@@ -68,47 +99,255 @@ func main() {
 		DB: db,
 	}
 
-	clusterHandler := &handler.Cluster{
-		Log:              log.WithName("handler.Cluster"),
-		ClusterStore:     clusterStore,
-		LifetimeDuration: cfg.ClusterLifetimeDuration,
+	eventStore := &store.Event{
+		DB: db,
 	}
 
-	basicAuthHandler := &handler.BasicAuth{
-		Username: cfg.BasicAuthUsername,
-		Password: cfg.BasicAuthPassword,
+	breakerStore := &store.Breaker{
+		DB: db,
 	}
 
-	router := mux.NewRouter()
-	router.HandleFunc("/clusters", clusterHandler.List)
-	router.HandleFunc("/clusters/renew/{name}", clusterHandler.Renew).Methods("POST")
-	router.HandleFunc("/clusters/ignore/{name}", clusterHandler.Ignore).Methods("POST")
-	router.HandleFunc("/clusters/unignore/{name}", clusterHandler.Unignore).Methods("POST")
-	router.Use(basicAuthHandler.Handle)
+	leaseStore := &store.Lease{
+		DB: db,
+	}
 
-	server := ifrithttpserver.New(fmt.Sprintf(":%d", cfg.Port), router)
+	elector := &leader.Elector{
+		Log:           log.WithName("leader.Elector"),
+		LeaseStore:    leaseStore,
+		EventStore:    eventStore,
+		Identity:      cfg.InstanceID,
+		LeaseDuration: cfg.LeaderLeaseDuration,
+	}
+
+	policyStore := policy.NewStore(cfg.Policy())
+
+	reloader := &policy.Reloader{
+		Log:           log.WithName("policy.Reloader"),
+		Policy:        policyStore,
+		EventStore:    eventStore,
+		ConfigPath:    *configPath,
+		WatchInterval: 10 * time.Second,
+		Hangups:       hangups,
+	}
+
+	nodePoolStore := &store.NodePool{
+		DB: db,
+	}
+
+	sweepStore := &store.Sweep{
+		DB: db,
+	}
+
+	deletionStore := &store.Deletion{
+		DB: db,
+	}
+
+	pollers := grouper.Members{}
+	syncers := poller.Syncers{}
+	var restorer handler.Restorer
+
+	var clusterManagerClient *container.ClusterManagerClient
+	var computeService *compute.Service
+	if cfg.UsesGCP() {
+		clusterManagerClient, err = container.NewClusterManagerClient(context.Background())
+		if err != nil {
+			log.WithName("main").Error(err, "failed to create gcloud cluster manager client")
+			os.Exit(1)
+		}
+
+		computeService, err = compute.NewService(context.Background())
+		if err != nil {
+			log.WithName("main").Error(err, "failed to create gcloud compute client")
+			os.Exit(1)
+		}
+	}
 
-	clusterManagerClient, err := container.NewClusterManagerClient(context.Background())
+	owners := &owner.Resolver{
+		Labels:   cfg.OwnerLabels,
+		Mappings: cfg.OwnerMappings,
+	}
+
+	if cfg.ProviderEnabled(config.ProviderGKECluster) {
+		if cfg.OwnerAuditLog {
+			loggingService, err := logging.NewService(context.Background())
+			if err != nil {
+				log.WithName("main").Error(err, "failed to create gcloud logging client")
+				os.Exit(1)
+			}
+			owners.AuditLog = &owner.CloudLogging{Service: loggingService}
+		}
+
+		var idleDetector *idle.Detector
+		if cfg.IdleDetection.Enabled() {
+			idleDetector, err = idle.NewDetector(context.Background(), cfg.IdleDetection)
+			if err != nil {
+				log.WithName("main").Error(err, "failed to create idle detector")
+				os.Exit(1)
+			}
+		}
+
+		gkePoller := &poller.GKE{
+			Log:           log.WithName("poller.GKE"),
+			Client:        clusterManagerClient,
+			ClusterStore:  clusterStore,
+			EventStore:    eventStore,
+			BreakerStore:  breakerStore,
+			NodePoolStore: nodePoolStore,
+			NodePools:     &nodepool.Compute{Service: computeService},
+			SweepStore:    sweepStore,
+			DeletionStore: deletionStore,
+			Janitor:       &janitor.Compute{Service: computeService},
+			Notifier:      notifier,
+			Owners:        owners,
+			Idle:          idleDetector,
+			Pricing:       cfg.Pricing,
+			Policy:        policyStore,
+			Leader:        elector,
+			Projects:      cfg.Projects,
+			PollInterval:  cfg.GCloudPollInterval,
+			PollJitter:    cfg.GCloudPollJitter,
+
+			CallTimeout:     cfg.GCloudCallTimeout,
+			ShutdownTimeout: cfg.ShutdownTimeout,
+		}
+		pollers = append(pollers, grouper.Member{Name: "gke-poller", Runner: gkePoller})
+		syncers = append(syncers, gkePoller)
+		restorer = gkePoller
+
+		if cfg.EventsSubscription != "" {
+			project, subscription, _ := cfg.ParseEventsSubscription()
+			pubsubClient, err := pubsub.NewClient(context.Background(), project)
+			if err != nil {
+				log.WithName("main").Error(err, "failed to create pubsub client")
+				os.Exit(1)
+			}
+
+			events := &poller.Events{
+				Log:           log.WithName("poller.Events"),
+				Subscriber:    &subscriber.PubSub{Subscription: pubsubClient.Subscription(subscription)},
+				Syncer:        gkePoller,
+				Leader:        elector,
+				RetryInterval: 10 * time.Second,
+			}
+			pollers = append(pollers, grouper.Member{Name: "gke-events", Runner: events})
+		}
+	}
+
+	resourceProviders := []provider.Provider{}
+	if cfg.ProviderEnabled(config.ProviderComputeInstance) {
+		resourceProviders = append(resourceProviders, &provider.ComputeInstances{Service: computeService, Projects: cfg.Projects})
+	}
+	if cfg.ProviderEnabled(config.ProviderKindCluster) {
+		resourceProviders = append(resourceProviders, &provider.KindClusters{})
+	}
+
+	for _, resourceProvider := range resourceProviders {
+		resourcePoller := &poller.Resources{
+			Log:           log.WithName("poller.Resources").WithValues("kind", resourceProvider.Kind()),
+			Provider:      resourceProvider,
+			ClusterStore:  clusterStore,
+			DeletionStore: deletionStore,
+			EventStore:    eventStore,
+			BreakerStore:  breakerStore,
+			Notifier:      notifier,
+			Owners:        owners,
+			Policy:        policyStore,
+			Leader:        elector,
+			PollInterval:  cfg.GCloudPollInterval,
+			PollJitter:    cfg.GCloudPollJitter,
+
+			ShutdownTimeout: cfg.ShutdownTimeout,
+		}
+		pollers = append(pollers, grouper.Member{Name: resourceProvider.Kind() + "-poller", Runner: resourcePoller})
+		syncers = append(syncers, resourcePoller)
+	}
+
+	digestBuilder := &digest.Builder{
+		ClusterStore:  clusterStore,
+		DeletionStore: deletionStore,
+		EventStore:    eventStore,
+		Policy:        policyStore,
+		IgnoredDays:   cfg.Digest.IgnoredDays,
+		Oldest:        cfg.Digest.Oldest,
+	}
+
+	digestRenderer, err := digest.NewRenderer(cfg.Digest.Format, cfg.Digest.TemplateFile)
 	if err != nil {
-		log.WithName("main").Error(err, "failed to create gcloud cluster manager client")
+		log.WithName("main").Error(err, "failed to load digest template")
 		os.Exit(1)
 	}
 
-	gkePoller := &poller.GKE{
-		Log:                    log.WithName("poller.GKE"),
-		Client:                 clusterManagerClient,
-		ClusterStore:           clusterStore,
-		Project:                cfg.Project,
-		PollInterval:           cfg.GCloudPollInterval,
-		LifetimeDuration:       cfg.ClusterLifetimeDuration,
-		ResourceLabelFilterMap: cfg.GCloudGKELabelFilters,
+	if cfg.Digest.Enabled() {
+		digestScheduler := &digest.Scheduler{
+			Log:           log.WithName("digest.Scheduler"),
+			Builder:       digestBuilder,
+			Renderer:      digestRenderer,
+			Notifier:      notifier,
+			EventStore:    eventStore,
+			Leader:        elector,
+			Format:        cfg.Digest.Format,
+			Period:        cfg.Digest.Period(),
+			CheckInterval: cfg.GCloudPollInterval,
+		}
+		pollers = append(pollers, grouper.Member{Name: "digest", Runner: digestScheduler})
 	}
 
-	group := grouper.NewOrdered(os.Interrupt, grouper.Members{
+	clusterHandler := &handler.Cluster{
+		Log:          log.WithName("handler.Cluster"),
+		ClusterStore: clusterStore,
+		EventStore:   eventStore,
+		Notifier:     notifier,
+		Syncer:       syncers,
+		Restorer:     restorer,
+		Policy:       policyStore,
+	}
+
+	adminHandler := &handler.Admin{
+		Log:          log.WithName("handler.Admin"),
+		Reloader:     reloader,
+		ClusterStore: clusterStore,
+		BreakerStore: breakerStore,
+		EventStore:   eventStore,
+		Notifier:     notifier,
+	}
+
+	reportHandler := &handler.Report{
+		Log:            log.WithName("handler.Report"),
+		DeletionStore:  deletionStore,
+		DigestBuilder:  digestBuilder,
+		DigestRenderer: digestRenderer,
+		DigestFormat:   cfg.Digest.Format,
+		DigestPeriod:   cfg.Digest.Period(),
+	}
+	if cfg.Pricing != nil {
+		reportHandler.Currency = cfg.Pricing.Currency
+	}
+
+	healthHandler := &handler.Health{
+		Log:     log.WithName("handler.Health"),
+		DB:      db,
+		Elector: elector,
+	}
+
+	basicAuthHandler := &handler.BasicAuth{
+		Username:         cfg.BasicAuthUsername,
+		Password:         cfg.BasicAuthPassword,
+		BearerToken:      cfg.BearerToken,
+		AdminBearerToken: cfg.AdminBearerToken,
+	}
+
+	router := handler.NewRouter(clusterHandler, adminHandler, reportHandler, healthHandler, basicAuthHandler)
+
+	server := ifrithttpserver.New(fmt.Sprintf(":%d", cfg.Port), router)
+
+	members := append(grouper.Members{
 		grouper.Member{Name: "migrate-db", Runner: migrateDB},
 		grouper.Member{Name: "server", Runner: server},
-		grouper.Member{Name: "gke-poller", Runner: gkePoller},
-	})
+		grouper.Member{Name: "leader-elector", Runner: elector},
+		grouper.Member{Name: "policy-reloader", Runner: reloader},
+	}, pollers...)
+
+	group := grouper.NewOrdered(os.Interrupt, members)
 
 	monitor := ifrit.Invoke(sigmon.New(group, syscall.SIGTERM, syscall.SIGINT))
 
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"time"
+)
+
+const (
+	ClustersPath        = "/clusters"
+	ClustersExportPath  = "/clusters/export"
+	ClustersImportPath  = "/clusters/import"
+	ClusterPath         = "/clusters/{name}"
+	ClusterHistoryPath  = "/clusters/history/{name}"
+	ClusterRenewPath    = "/clusters/renew/{name}"
+	ClusterIgnorePath   = "/clusters/ignore/{name}"
+	ClusterUnignorePath = "/clusters/unignore/{name}"
+	ClusterRestorePath  = "/clusters/restore/{name}"
+	ClusterActionPath   = "/clusters/action/{name}"
+	SyncPath            = "/clusters/sync"
+
+	AdminReloadPath     = "/admin/reload"
+	AdminBreakerPath    = "/admin/breaker"
+	AdminBreakerArmPath = "/admin/breaker/arm"
+
+	ReportsSavingsPath = "/reports/savings"
+	ReportsDigestPath  = "/reports/digest"
+
+	HealthPath = "/health"
+)
+
+type Cluster struct {
+	ID              int
+	Kind            string
+	Name            string
+	Project         string
+	Location        string
+	CreateDate      time.Time
+	ExpirationDate  time.Time
+	DeletionDate    time.Time
+	Ignore          bool
+	IgnoreUntil     time.Time
+	IgnoreReason    string
+	IgnoredBy       string
+	IgnoreDate      time.Time
+	Status          string
+	Labels          map[string]string
+	Protected       bool
+	ProtectedReason string `json:",omitempty"`
+	ExpiryAction    string
+	ActionTaken     string
+	Owner           string
+	OwnerSource     string
+	Idle            bool
+	IdleSince       time.Time
+	HourlyCost      float64
+
+	// RenewalExtension is how far renewals pushed back the expiration in
+	// total, e.g. 72h0m0s.
+	RenewalCount        int
+	RenewalExtension    string
+	RenewalLimitReached bool
+}
+
+// ExportedCluster is a cluster as it is stored, for exports and imports.
+type ExportedCluster struct {
+	Kind           string
+	Name           string
+	CreateDate     time.Time
+	ExpirationDate time.Time
+	Ignore         bool
+	IgnoreUntil    time.Time
+	IgnoreReason   string
+	IgnoredBy      string
+	IgnoreDate     time.Time
+	Status         string
+	Labels         map[string]string
+	SelfLink       string
+	Project        string
+	Location       string
+	ExpiryAction   string
+	ActionTaken    string
+	Owner          string
+	OwnerSource    string
+	IdleSince      time.Time
+	HourlyCost     float64
+
+	ExpirationBeforeIdle time.Time
+
+	RenewalCount     int
+	RenewalExtension time.Duration
+}
+
+type ImportResponse struct {
+	Inserted []string
+	Updated  []string
+	Skipped  []string
+}
+
+type Event struct {
+	ID          int
+	Kind        string
+	ClusterName string
+	Type        string
+	Message     string
+	CreateDate  time.Time
+}
+
+type RenewRequest struct {
+	Duration string `json:",omitempty"`
+	Until    time.Time
+
+	// Justification allows a renewal beyond the renewal limits.
+	Justification string `json:",omitempty"`
+}
+
+type IgnoreRequest struct {
+	Until  time.Time
+	Reason string `json:",omitempty"`
+
+	// By is who is ignoring the cluster. It is the basic auth username, only
+	// an admin can set it to someone else.
+	By string `json:",omitempty"`
+}
+
+type ActionRequest struct {
+	Action string
+}
+
+type ErrorResponse struct {
+	Error string
+}
+
+type ReloadResponse struct {
+	Changes []string
+}
+
+type Breaker struct {
+	Tripped   bool
+	Reason    string
+	UpdatedAt time.Time
+}
+
+type ArmRequest struct {
+	Reason string `json:",omitempty"`
+}
+
+// SavingsReport sums what the resources deleted from From up to To would
+// have cost had they kept running until To.
+type SavingsReport struct {
+	From      time.Time
+	To        time.Time
+	Currency  string
+	Saved     float64
+	Deletions []Saving
+}
+
+type Saving struct {
+	ClusterName string
+	Kind        string
+	Owner       string
+	HourlyCost  float64
+	DeleteDate  time.Time
+	Hours       float64
+	Saved       float64
+}
+
+type HealthResponse struct {
+	Status          string
+	Instance        string
+	IsLeader        bool
+	Leader          string
+	LeaderExpiresAt time.Time
+}
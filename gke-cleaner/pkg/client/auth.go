@@ -0,0 +1,26 @@
+package client
+
+import (
+	"net/http"
+)
+
+type Auth interface {
+	Apply(req *http.Request)
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (b BasicAuth) Apply(req *http.Request) {
+	req.SetBasicAuth(b.Username, b.Password)
+}
+
+type BearerToken struct {
+	Token string
+}
+
+func (b BearerToken) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+}
@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+)
+
+type Client struct {
+	URL        string
+	Auth       Auth
+	HTTPClient *http.Client
+
+	// MaxRetries is the number of times a GET request is retried after a 5xx
+	// response. Zero uses the default, a negative value disables retries.
+	// Other requests are never retried, since the server may have acted on
+	// them before failing.
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+func (c *Client) List(ctx context.Context) ([]api.Cluster, error) {
+	clusters := []api.Cluster{}
+	err := c.do(ctx, http.MethodGet, api.ClustersPath, nil, &clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+// ListByKind lists the known resources of a single kind, e.g. gke-cluster.
+func (c *Client) ListByKind(ctx context.Context, kind string) ([]api.Cluster, error) {
+	clusters := []api.Cluster{}
+	err := c.do(ctx, http.MethodGet, api.ClustersPath+"?kind="+url.QueryEscape(kind), nil, &clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+// ListByOwner lists the known resources of a single owner, compared case
+// insensitively.
+func (c *Client) ListByOwner(ctx context.Context, owner string) ([]api.Cluster, error) {
+	clusters := []api.Cluster{}
+	err := c.do(ctx, http.MethodGet, api.ClustersPath+"?owner="+url.QueryEscape(owner), nil, &clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+// Savings reports what the deletions between from and to saved. A zero time
+// leaves the server's default in place.
+func (c *Client) Savings(ctx context.Context, from, to time.Time) (api.SavingsReport, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	path := api.ReportsSavingsPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var report api.SavingsReport
+	err := c.do(ctx, http.MethodGet, path, nil, &report)
+	if err != nil {
+		return api.SavingsReport{}, err
+	}
+
+	return report, nil
+}
+
+// Digest renders the digest of the period from from up to to in the format,
+// markdown or html. Zero values leave the server's defaults in place.
+func (c *Client) Digest(ctx context.Context, format string, from, to time.Time) (string, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	path := api.ReportsDigestPath
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var digest []byte
+	err := c.do(ctx, http.MethodGet, path, nil, &digest)
+	if err != nil {
+		return "", err
+	}
+
+	return string(digest), nil
+}
+
+// Export lists every stored resource with all of its fields.
+func (c *Client) Export(ctx context.Context) ([]api.ExportedCluster, error) {
+	clusters := []api.ExportedCluster{}
+	err := c.do(ctx, http.MethodGet, api.ClustersExportPath+"?format=json", nil, &clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+// Import upserts clusters, resolving conflicts with the skip, overwrite or
+// merge strategy.
+func (c *Client) Import(ctx context.Context, clusters []api.ExportedCluster, strategy string) (api.ImportResponse, error) {
+	var response api.ImportResponse
+	err := c.do(ctx, http.MethodPost, api.ClustersImportPath+"?strategy="+url.QueryEscape(strategy), clusters, &response)
+	if err != nil {
+		return api.ImportResponse{}, err
+	}
+
+	return response, nil
+}
+
+// Get gets the cluster or other resource with the name. The kind may be
+// empty when no resource of another kind has the same name.
+func (c *Client) Get(ctx context.Context, kind string, name string) (api.Cluster, error) {
+	var cluster api.Cluster
+	err := c.do(ctx, http.MethodGet, clusterPath(api.ClusterPath, kind, name), nil, &cluster)
+	if err != nil {
+		return api.Cluster{}, err
+	}
+
+	return cluster, nil
+}
+
+func (c *Client) History(ctx context.Context, kind string, name string) ([]api.Event, error) {
+	events := []api.Event{}
+	err := c.do(ctx, http.MethodGet, clusterPath(api.ClusterHistoryPath, kind, name), nil, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (c *Client) Renew(ctx context.Context, kind string, name string, request api.RenewRequest) error {
+	return c.do(ctx, http.MethodPost, clusterPath(api.ClusterRenewPath, kind, name), request, nil)
+}
+
+func (c *Client) Ignore(ctx context.Context, kind string, name string, request api.IgnoreRequest) error {
+	return c.do(ctx, http.MethodPost, clusterPath(api.ClusterIgnorePath, kind, name), request, nil)
+}
+
+func (c *Client) Unignore(ctx context.Context, kind string, name string) error {
+	return c.do(ctx, http.MethodPost, clusterPath(api.ClusterUnignorePath, kind, name), nil, nil)
+}
+
+func (c *Client) Restore(ctx context.Context, kind string, name string, request api.RenewRequest) error {
+	return c.do(ctx, http.MethodPost, clusterPath(api.ClusterRestorePath, kind, name), request, nil)
+}
+
+func (c *Client) SetExpiryAction(ctx context.Context, kind string, name string, request api.ActionRequest) error {
+	return c.do(ctx, http.MethodPost, clusterPath(api.ClusterActionPath, kind, name), request, nil)
+}
+
+func (c *Client) Sync(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, api.SyncPath, nil, nil)
+}
+
+func (c *Client) Reload(ctx context.Context) ([]string, error) {
+	var response api.ReloadResponse
+	err := c.do(ctx, http.MethodPost, api.AdminReloadPath, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Changes, nil
+}
+
+func (c *Client) Breaker(ctx context.Context) (api.Breaker, error) {
+	var breaker api.Breaker
+	err := c.do(ctx, http.MethodGet, api.AdminBreakerPath, nil, &breaker)
+	if err != nil {
+		return api.Breaker{}, err
+	}
+
+	return breaker, nil
+}
+
+func (c *Client) Arm(ctx context.Context, request api.ArmRequest) (api.Breaker, error) {
+	var breaker api.Breaker
+	err := c.do(ctx, http.MethodPost, api.AdminBreakerArmPath, request, &breaker)
+	if err != nil {
+		return api.Breaker{}, err
+	}
+
+	return breaker, nil
+}
+
+func (c *Client) Health(ctx context.Context) (api.HealthResponse, error) {
+	var response api.HealthResponse
+	err := c.do(ctx, http.MethodGet, api.HealthPath, nil, &response)
+	if err != nil {
+		return api.HealthResponse{}, err
+	}
+
+	return response, nil
+}
+
+func clusterPath(route string, kind string, name string) string {
+	path := strings.Replace(route, "{name}", url.PathEscape(name), 1)
+	if kind != "" {
+		path += "?kind=" + url.QueryEscape(kind)
+	}
+
+	return path
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, requestBody interface{}, responseBody interface{}) error {
+	var body []byte
+	if requestBody != nil {
+		var err error
+		body, err = json.Marshal(requestBody)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %s", err)
+		}
+	}
+
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	if method != http.MethodGet {
+		maxRetries = 0
+	}
+
+	backoff := c.RetryBackoff
+	if backoff == 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.doOnce(ctx, method, path, body, responseBody)
+
+		apiErr, ok := err.(*Error)
+		if !ok || apiErr.StatusCode < 500 || attempt >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff << uint(attempt)):
+		}
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method string, path string, body []byte, responseBody interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.Auth != nil {
+		c.Auth.Apply(req)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %s", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errorResponse api.ErrorResponse
+		json.Unmarshal(b, &errorResponse)
+
+		return &Error{StatusCode: resp.StatusCode, Message: errorResponse.Error}
+	}
+
+	if raw, ok := responseBody.(*[]byte); ok {
+		*raw = b
+		return nil
+	}
+
+	if responseBody != nil {
+		err = json.Unmarshal(b, responseBody)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal response: %s", err)
+		}
+	}
+
+	return nil
+}
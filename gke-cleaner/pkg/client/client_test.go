@@ -0,0 +1,221 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/client"
+	"github.com/christianang/gke-cleaner/pkg/handler"
+	"github.com/gorilla/mux"
+)
+
+const (
+	username    = "user"
+	password    = "secret"
+	bearerToken = "token"
+)
+
+// server serves the API paths behind the real auth middleware. The dev
+// cluster is the only one found, and the first failures requests are answered
+// with a 503 before reaching the API.
+type server struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests int
+	failures int
+}
+
+func newServer(t *testing.T) *server {
+	router := mux.NewRouter()
+	router.HandleFunc(api.ClustersPath, func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, []api.Cluster{{Name: "dev"}})
+	})
+	router.HandleFunc(api.ClusterPath, func(w http.ResponseWriter, req *http.Request) {
+		if mux.Vars(req)["name"] != "dev" {
+			writeJSON(w, http.StatusNotFound, api.ErrorResponse{Error: "cluster not found"})
+			return
+		}
+		kind := req.URL.Query().Get("kind")
+		if kind == "" {
+			writeJSON(w, http.StatusConflict, api.ErrorResponse{Error: `"dev" names resources of more than one kind`})
+			return
+		}
+		writeJSON(w, http.StatusOK, api.Cluster{Kind: kind, Name: "dev"})
+	}).Methods("GET")
+	router.HandleFunc(api.ClusterRenewPath, func(w http.ResponseWriter, req *http.Request) {}).Methods("POST")
+	router.HandleFunc(api.ClusterIgnorePath, func(w http.ResponseWriter, req *http.Request) {}).Methods("POST")
+	router.Use(handler.BasicAuth{Username: username, Password: password, BearerToken: bearerToken}.Handle)
+
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mutex.Lock()
+		s.requests++
+		fail := s.failures > 0
+		if fail {
+			s.failures--
+		}
+		s.mutex.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, req)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) failNext(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = n
+	s.requests = 0
+}
+
+func (s *server) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+func (s *server) client(auth client.Auth) *client.Client {
+	return &client.Client{
+		URL:          s.URL,
+		Auth:         auth,
+		RetryBackoff: time.Millisecond,
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	s := newServer(t)
+
+	tests := []struct {
+		name string
+		auth client.Auth
+		err  error
+	}{
+		{name: "basic auth", auth: client.BasicAuth{Username: username, Password: password}},
+		{name: "bearer token", auth: client.BearerToken{Token: bearerToken}},
+		{name: "wrong password", auth: client.BasicAuth{Username: username, Password: "wrong"}, err: client.ErrUnauthorized},
+		{name: "wrong bearer token", auth: client.BearerToken{Token: "wrong"}, err: client.ErrUnauthorized},
+		{name: "no auth", err: client.ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		clusters, err := s.client(test.auth).List(context.Background())
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if len(clusters) != 1 || clusters[0].Name != "dev" {
+			t.Errorf("%s: expected the dev cluster, got %+v", test.name, clusters)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	s := newServer(t)
+	c := s.client(client.BasicAuth{Username: username, Password: password})
+
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, "", "missing")
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected %v, got %v", client.ErrNotFound, err)
+	}
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "cluster not found" {
+		t.Errorf("expected a 404 error with the server's message, got %#v", err)
+	}
+
+	_, err = c.Get(ctx, "", "dev")
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected %v for a name shared by two kinds, got %v", client.ErrConflict, err)
+	}
+
+	cluster, err := c.Get(ctx, "compute-instance", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Kind != "compute-instance" {
+		t.Errorf("expected the kind to be sent, got %+v", cluster)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	s := newServer(t)
+	c := s.client(client.BasicAuth{Username: username, Password: password})
+	ctx := context.Background()
+
+	s.failNext(2)
+	_, err := c.Get(ctx, "gke-cluster", "dev")
+	if err != nil {
+		t.Errorf("expected a GET to succeed after retrying, got %s", err)
+	}
+	if got := s.requestCount(); got != 3 {
+		t.Errorf("expected a GET to be sent 3 times, got %d", got)
+	}
+
+	s.failNext(10)
+	_, err = c.List(ctx)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 error after retrying, got %v", err)
+	}
+	if got := s.requestCount(); got != 4 {
+		t.Errorf("expected a GET to be sent 4 times, got %d", got)
+	}
+
+	s.failNext(1)
+	err = c.Renew(ctx, "gke-cluster", "dev", api.RenewRequest{Duration: "1h"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected renewing to fail with a 503, got %v", err)
+	}
+	if got := s.requestCount(); got != 1 {
+		t.Errorf("expected renewing not to be retried, sent %d times", got)
+	}
+
+	s.failNext(1)
+	err = c.Ignore(ctx, "gke-cluster", "dev", api.IgnoreRequest{Reason: "testing"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected ignoring to fail with a 503, got %v", err)
+	}
+	if got := s.requestCount(); got != 1 {
+		t.Errorf("expected ignoring not to be retried, sent %d times", got)
+	}
+
+	noRetries := s.client(client.BasicAuth{Username: username, Password: password})
+	noRetries.MaxRetries = -1
+	s.failNext(1)
+	_, err = noRetries.List(ctx)
+	if err == nil {
+		t.Errorf("expected a GET to fail without retries")
+	}
+	if got := s.requestCount(); got != 1 {
+		t.Errorf("expected a GET to be sent once without retries, got %d", got)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+const (
+	ActionDelete      = "delete"
+	ActionScaleToZero = "scale-to-zero"
+	ActionNotifyOnly  = "notify-only"
+)
+
+// ExpiryActionLabel chooses the expiry action of a cluster from GKE, it
+// takes precedence over the expiry action rules.
+const ExpiryActionLabel = "gke-cleaner-expiry-action"
+
+type ExpiryActionRule struct {
+	Labels map[string]string
+	Action string
+}
+
+func ValidExpiryAction(action string) bool {
+	switch action {
+	case ActionDelete, ActionScaleToZero, ActionNotifyOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r ExpiryActionRule) Matches(labels map[string]string) bool {
+	for k, v := range r.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ExpiryActionFor returns what happens to a cluster when it expires. A
+// cluster's own override comes first, then its expiry action label, then the
+// first matching rule and finally the default action.
+func (p Policy) ExpiryActionFor(override string, labels map[string]string) string {
+	if ValidExpiryAction(override) {
+		return override
+	}
+
+	if action := labels[ExpiryActionLabel]; ValidExpiryAction(action) {
+		return action
+	}
+
+	for _, rule := range p.ExpiryActionRules {
+		if rule.Matches(labels) {
+			return rule.Action
+		}
+	}
+
+	if p.DefaultExpiryAction == "" {
+		return ActionDelete
+	}
+
+	return p.DefaultExpiryAction
+}
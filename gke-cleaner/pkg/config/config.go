@@ -7,20 +7,95 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/christianang/gke-cleaner/pkg/cost"
+	"github.com/christianang/gke-cleaner/pkg/schedule"
 	"github.com/go-logr/logr"
 )
 
 type Config struct {
 	Port                    int
-	Project                 string
+	Projects                []string
+	Providers               []string
+	GCPServiceAccountKey    string
 	GCloudPollInterval      time.Duration
+	GCloudPollJitter        float64
+	GCloudCallTimeout       time.Duration
 	GCloudGKELabelFilters   []string
 	ClusterLifetimeDuration time.Duration
+	LifetimeRules           []LifetimeRule
+	UnhealthyLifetime       time.Duration
+	DefaultExpiryAction     string
+	ExpiryActionRules       []ExpiryActionRule
+	DatabaseDriver          string
+	DatabaseURI             string
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+	DatabaseCallTimeout     time.Duration
+	NotificationSinks       []NotificationSink
+	DryRun                  bool
+	Safeguards              Safeguards
+	RenewalLimits           RenewalLimits
+	Protection              Protection
+	DeletionWindows         []string
+	DeletionTimezone        string
+	HolidayCalendarFile     string
+	DeletionSchedule        *schedule.Schedule
+	OrphanCleanup           string
+	EventsSubscription      string
+	OwnerLabels             []string
+	OwnerMappings           []OwnerMapping
+	OwnerAuditLog           bool
+	IdleDetection           IdleDetection
+	PricingFile             string
+	Pricing                 *cost.Pricing
+	Digest                  Digest
+	InstanceID              string
+	LeaderLeaseDuration     time.Duration
+	ShutdownTimeout         time.Duration
 	VCAPServices            VCAPServices
 	BasicAuthUsername       string
 	BasicAuthPassword       string
+	BearerToken             string
+	AdminBearerToken        string
+}
+
+// Providers that can be enabled, named after the kind of resource they
+// track.
+const (
+	ProviderGKECluster      = "gke-cluster"
+	ProviderComputeInstance = "compute-instance"
+	ProviderKindCluster     = "kind-cluster"
+)
+
+// OrphanCleanup modes decide what happens to the compute resources a deleted
+// cluster leaves behind.
+const (
+	OrphanCleanupOff    = "off"
+	OrphanCleanupReport = "report"
+	OrphanCleanupDelete = "delete"
+)
+
+type LifetimeRule struct {
+	Labels   map[string]string
+	Lifetime time.Duration
+}
+
+// Safeguards limit how many clusters are deleted. A zero value disables the
+// limit.
+type Safeguards struct {
+	MaxDeletionsPerPass   int
+	MaxDeletionsPerHour   int
+	CircuitBreakerPercent int
+}
+
+type NotificationSink struct {
+	Type   string
+	URL    string
+	Events []string
 }
 
 type VCAPServices struct {
@@ -38,110 +113,665 @@ type UserProvidedVCAPServices struct {
 	VolumeMounts   []string          `json:"volume_mounts"`
 }
 
-func LoadFromEnv(log logr.Logger) (Config, error) {
-	portStr, ok := os.LookupEnv("PORT")
-	if !ok {
-		return Config{}, errors.New("PORT environment variable not found")
+// Load reads the yaml config file at path, if one is given, and then applies
+// any environment variables on top of it.
+func Load(log logr.Logger, path string) (Config, error) {
+	cfg := Config{
+		GCloudPollInterval:      10 * time.Minute,
+		GCloudCallTimeout:       2 * time.Minute,
+		DatabaseCallTimeout:     30 * time.Second,
+		ShutdownTimeout:         30 * time.Second,
+		ClusterLifetimeDuration: 24 * time.Hour,
+		LeaderLeaseDuration:     30 * time.Second,
+		DeletionTimezone:        "UTC",
+		DefaultExpiryAction:     ActionDelete,
+		OrphanCleanup:           OrphanCleanupOff,
+		Providers:               []string{ProviderGKECluster},
+		OwnerLabels:             []string{"gke-cleaner-owner", "owner"},
+		OwnerAuditLog:           true,
+		IdleDetection: IdleDetection{
+			Period:       time.Hour,
+			Lifetime:     time.Hour,
+			CPUThreshold: 0.05,
+		},
+		Digest: Digest{
+			Format:      DigestFormatMarkdown,
+			IgnoredDays: 7,
+			Oldest:      5,
+		},
 	}
-	log.Info("Loaded", "PORT", portStr)
 
-	port, err := strconv.Atoi(portStr)
+	if path != "" {
+		err := loadFile(path, &cfg)
+		if err != nil {
+			return Config{}, err
+		}
+		log.Info("Loaded", "CONFIG_FILE", path)
+	}
+
+	err := loadEnv(log, &cfg)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to convert PORT environment variable: %s", err)
+		return Config{}, err
 	}
 
-	project, ok := os.LookupEnv("PROJECT")
-	if !ok {
-		return Config{}, errors.New("PROJECT environment variable not found")
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, err
 	}
-	log.Info("Loaded", "PROJECT", project)
 
-	gcpServiceAccountKey, ok := os.LookupEnv("GCP_SERVICE_ACCOUNT_KEY")
-	if !ok {
-		return Config{}, errors.New("GCP_SERVICE_ACCOUNT_KEY environment variable not found")
+	if len(cfg.DeletionWindows) > 0 || cfg.HolidayCalendarFile != "" {
+		cfg.DeletionSchedule, err = schedule.New(cfg.DeletionWindows, cfg.DeletionTimezone, cfg.HolidayCalendarFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid deletion schedule: %s", err)
+		}
 	}
-	gcpKeyFile, err := ioutil.TempFile("", "gcp-service-account-key")
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to create temporary file for gcp service account key: %s", err)
+
+	if cfg.PricingFile != "" {
+		cfg.Pricing, err = cost.LoadPricing(cfg.PricingFile)
+		if err != nil {
+			return Config{}, err
+		}
 	}
-	defer gcpKeyFile.Close()
 
-	_, err = gcpKeyFile.Write([]byte(gcpServiceAccountKey))
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to write to temporary file for gcp service account key: %s", err)
+	return cfg, nil
+}
+
+func loadEnv(log logr.Logger, cfg *Config) error {
+	portStr, ok := os.LookupEnv("PORT")
+	if ok {
+		log.Info("Loaded", "PORT", portStr)
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return fmt.Errorf("failed to convert PORT environment variable: %s", err)
+		}
+		cfg.Port = port
 	}
 
-	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", gcpKeyFile.Name())
-	log.Info("Loaded", "GCP_SERVICE_ACCOUNT_KEY", "<redacted>")
+	project, ok := os.LookupEnv("PROJECT")
+	if ok {
+		cfg.Projects = strings.Split(project, ",")
+		log.Info("Loaded", "PROJECT", project)
+	}
+
+	gcpServiceAccountKey, ok := os.LookupEnv("GCP_SERVICE_ACCOUNT_KEY")
+	if ok {
+		cfg.GCPServiceAccountKey = gcpServiceAccountKey
+		log.Info("Loaded", "GCP_SERVICE_ACCOUNT_KEY", "<redacted>")
+	}
 
 	gcloudPollIntervalStr, ok := os.LookupEnv("GCLOUD_POLL_INTERVAL")
-	if !ok {
-		gcloudPollIntervalStr = "10m"
+	if ok {
+		log.Info("Loaded", "GCLOUD_POLL_INTERVAL", gcloudPollIntervalStr)
+
+		gcloudPollInterval, err := time.ParseDuration(gcloudPollIntervalStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse GCLOUD_POLL_INTERVAL environment variable: %s", err)
+		}
+		cfg.GCloudPollInterval = gcloudPollInterval
 	}
-	log.Info("Loaded", "GCLOUD_POLL_INTERVAL", gcloudPollIntervalStr)
 
-	gcloudPollInterval, err := time.ParseDuration(gcloudPollIntervalStr)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse GCLOUD_POLL_INTERVAL environment variable: %s", err)
+	gcloudPollJitterStr, ok := os.LookupEnv("GCLOUD_POLL_JITTER")
+	if ok {
+		log.Info("Loaded", "GCLOUD_POLL_JITTER", gcloudPollJitterStr)
+
+		gcloudPollJitter, err := strconv.ParseFloat(gcloudPollJitterStr, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse GCLOUD_POLL_JITTER environment variable: %s", err)
+		}
+		cfg.GCloudPollJitter = gcloudPollJitter
 	}
 
 	clusterLifetimeDurationStr, ok := os.LookupEnv("CLUSTER_LIFETIME_DURATION")
-	if !ok {
-		clusterLifetimeDurationStr = "24h"
-	}
-	log.Info("Loaded", "CLUSTER_LIFETIME_DURATION", clusterLifetimeDurationStr)
+	if ok {
+		log.Info("Loaded", "CLUSTER_LIFETIME_DURATION", clusterLifetimeDurationStr)
 
-	clusterLifetimeDuration, err := time.ParseDuration(clusterLifetimeDurationStr)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse CLUSTER_LIFETIME_DURATION environment variable: %s", err)
+		clusterLifetimeDuration, err := time.ParseDuration(clusterLifetimeDurationStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse CLUSTER_LIFETIME_DURATION environment variable: %s", err)
+		}
+		cfg.ClusterLifetimeDuration = clusterLifetimeDuration
 	}
 
-	var gcloudGKELabelFilter []string
 	gcloudGKELabelFilterStr, ok := os.LookupEnv("GCLOUD_GKE_LABEL_FILTERS")
 	if ok {
-		err = json.Unmarshal([]byte(gcloudGKELabelFilterStr), &gcloudGKELabelFilter)
+		var gcloudGKELabelFilter []string
+		err := json.Unmarshal([]byte(gcloudGKELabelFilterStr), &gcloudGKELabelFilter)
 		if err != nil {
-			return Config{}, fmt.Errorf("failed to parse GCLOUD_GKE_LABEL_FILTERS environment variable: %s", err)
+			return fmt.Errorf("failed to parse GCLOUD_GKE_LABEL_FILTERS environment variable: %s", err)
 		}
+		cfg.GCloudGKELabelFilters = gcloudGKELabelFilter
 		log.Info("Loaded", "GCLOUD_GKE_LABEL_FILTERS", gcloudGKELabelFilter)
 	} else {
 		log.Info("GCLOUD_GKE_LABEL_FILTERS unset.")
 	}
 
-	vcapServicesStr, ok := os.LookupEnv("VCAP_SERVICES")
+	lifetimeRulesStr, ok := os.LookupEnv("CLUSTER_LIFETIME_RULES")
+	if ok {
+		var rules []fileLifetimeRule
+		err := unmarshalStrictJSON([]byte(lifetimeRulesStr), &rules)
+		if err != nil {
+			return fmt.Errorf("failed to parse CLUSTER_LIFETIME_RULES environment variable: %s", err)
+		}
+
+		cfg.LifetimeRules, err = parseLifetimeRules(rules)
+		if err != nil {
+			return fmt.Errorf("failed to parse CLUSTER_LIFETIME_RULES environment variable: %s", err)
+		}
+		log.Info("Loaded", "CLUSTER_LIFETIME_RULES", lifetimeRulesStr)
+	}
+
+	unhealthyLifetimeStr, ok := os.LookupEnv("UNHEALTHY_CLUSTER_LIFETIME_DURATION")
+	if ok {
+		log.Info("Loaded", "UNHEALTHY_CLUSTER_LIFETIME_DURATION", unhealthyLifetimeStr)
+
+		unhealthyLifetime, err := time.ParseDuration(unhealthyLifetimeStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse UNHEALTHY_CLUSTER_LIFETIME_DURATION environment variable: %s", err)
+		}
+		cfg.UnhealthyLifetime = unhealthyLifetime
+	}
+
+	defaultExpiryAction, ok := os.LookupEnv("DEFAULT_EXPIRY_ACTION")
+	if ok {
+		cfg.DefaultExpiryAction = defaultExpiryAction
+		log.Info("Loaded", "DEFAULT_EXPIRY_ACTION", defaultExpiryAction)
+	}
+
+	expiryActionRulesStr, ok := os.LookupEnv("EXPIRY_ACTION_RULES")
+	if ok {
+		var rules []fileExpiryActionRule
+		err := unmarshalStrictJSON([]byte(expiryActionRulesStr), &rules)
+		if err != nil {
+			return fmt.Errorf("failed to parse EXPIRY_ACTION_RULES environment variable: %s", err)
+		}
+		cfg.ExpiryActionRules = parseExpiryActionRules(rules)
+		log.Info("Loaded", "EXPIRY_ACTION_RULES", expiryActionRulesStr)
+	}
+
+	notificationSinksStr, ok := os.LookupEnv("NOTIFICATION_SINKS")
+	if ok {
+		var sinks []fileNotificationSink
+		err := unmarshalStrictJSON([]byte(notificationSinksStr), &sinks)
+		if err != nil {
+			return fmt.Errorf("failed to parse NOTIFICATION_SINKS environment variable: %s", err)
+		}
+		cfg.NotificationSinks = parseNotificationSinks(sinks)
+		log.Info("Loaded", "NOTIFICATION_SINKS", "<redacted>")
+	}
+
+	dryRunStr, ok := os.LookupEnv("DRY_RUN")
+	if ok {
+		log.Info("Loaded", "DRY_RUN", dryRunStr)
+
+		dryRun, err := strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse DRY_RUN environment variable: %s", err)
+		}
+		cfg.DryRun = dryRun
+	}
+
+	for name, target := range map[string]*[]string{
+		"PROTECTED_CLUSTER_NAMES":        &cfg.Protection.NameGlobs,
+		"PROTECTED_CLUSTER_NAME_REGEXES": &cfg.Protection.NameRegexes,
+		"PROTECTED_CLUSTER_LABELS":       &cfg.Protection.LabelSelectors,
+		"DELETION_WINDOWS":               &cfg.DeletionWindows,
+		"PROVIDERS":                      &cfg.Providers,
+		"OWNER_LABELS":                   &cfg.OwnerLabels,
+		"IDLE_SIGNALS":                   &cfg.IdleDetection.Signals,
+	} {
+		valueStr, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		log.Info("Loaded", name, valueStr)
+
+		var value []string
+		err := json.Unmarshal([]byte(valueStr), &value)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s environment variable: %s", name, err)
+		}
+		*target = value
+	}
+
+	for name, target := range map[string]*int{
+		"MAX_DELETIONS_PER_PASS":  &cfg.Safeguards.MaxDeletionsPerPass,
+		"MAX_DELETIONS_PER_HOUR":  &cfg.Safeguards.MaxDeletionsPerHour,
+		"CIRCUIT_BREAKER_PERCENT": &cfg.Safeguards.CircuitBreakerPercent,
+		"DIGEST_IGNORED_DAYS":     &cfg.Digest.IgnoredDays,
+		"DIGEST_OLDEST":           &cfg.Digest.Oldest,
+		"MAX_RENEWALS":            &cfg.RenewalLimits.MaxRenewals,
+	} {
+		valueStr, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		log.Info("Loaded", name, valueStr)
+
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s environment variable: %s", name, err)
+		}
+		*target = value
+	}
+
+	deletionTimezone, ok := os.LookupEnv("DELETION_TIMEZONE")
+	if ok {
+		cfg.DeletionTimezone = deletionTimezone
+		log.Info("Loaded", "DELETION_TIMEZONE", deletionTimezone)
+	}
+
+	orphanCleanup, ok := os.LookupEnv("ORPHAN_CLEANUP")
+	if ok {
+		cfg.OrphanCleanup = orphanCleanup
+		log.Info("Loaded", "ORPHAN_CLEANUP", orphanCleanup)
+	}
+
+	ownerMappingsStr, ok := os.LookupEnv("OWNER_MAPPINGS")
+	if ok {
+		var mappings []fileOwnerMapping
+		err := unmarshalStrictJSON([]byte(ownerMappingsStr), &mappings)
+		if err != nil {
+			return fmt.Errorf("failed to parse OWNER_MAPPINGS environment variable: %s", err)
+		}
+		cfg.OwnerMappings = parseOwnerMappings(mappings)
+		log.Info("Loaded", "OWNER_MAPPINGS", ownerMappingsStr)
+	}
+
+	ownerAuditLogStr, ok := os.LookupEnv("OWNER_AUDIT_LOG")
+	if ok {
+		log.Info("Loaded", "OWNER_AUDIT_LOG", ownerAuditLogStr)
+
+		ownerAuditLog, err := strconv.ParseBool(ownerAuditLogStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse OWNER_AUDIT_LOG environment variable: %s", err)
+		}
+		cfg.OwnerAuditLog = ownerAuditLog
+	}
+
+	for name, target := range map[string]*time.Duration{
+		"IDLE_PERIOD":                    &cfg.IdleDetection.Period,
+		"IDLE_CLUSTER_LIFETIME_DURATION": &cfg.IdleDetection.Lifetime,
+		"MAX_CLUSTER_AGE":                &cfg.RenewalLimits.MaxAge,
+		"GCLOUD_CALL_TIMEOUT":            &cfg.GCloudCallTimeout,
+		"DATABASE_CALL_TIMEOUT":          &cfg.DatabaseCallTimeout,
+		"SHUTDOWN_TIMEOUT":               &cfg.ShutdownTimeout,
+	} {
+		valueStr, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		log.Info("Loaded", name, valueStr)
+
+		value, err := time.ParseDuration(valueStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s environment variable: %s", name, err)
+		}
+		*target = value
+	}
+
+	idleCPUThresholdStr, ok := os.LookupEnv("IDLE_CPU_THRESHOLD")
+	if ok {
+		log.Info("Loaded", "IDLE_CPU_THRESHOLD", idleCPUThresholdStr)
+
+		idleCPUThreshold, err := strconv.ParseFloat(idleCPUThresholdStr, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse IDLE_CPU_THRESHOLD environment variable: %s", err)
+		}
+		cfg.IdleDetection.CPUThreshold = idleCPUThreshold
+	}
+
+	pricingFile, ok := os.LookupEnv("PRICING_FILE")
+	if ok {
+		cfg.PricingFile = pricingFile
+		log.Info("Loaded", "PRICING_FILE", pricingFile)
+	}
+
+	for name, target := range map[string]*string{
+		"DIGEST_SCHEDULE":      &cfg.Digest.Schedule,
+		"DIGEST_FORMAT":        &cfg.Digest.Format,
+		"DIGEST_TEMPLATE_FILE": &cfg.Digest.TemplateFile,
+	} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		log.Info("Loaded", name, value)
+		*target = value
+	}
+
+	eventsSubscription, ok := os.LookupEnv("GKE_EVENTS_SUBSCRIPTION")
+	if ok {
+		cfg.EventsSubscription = eventsSubscription
+		log.Info("Loaded", "GKE_EVENTS_SUBSCRIPTION", eventsSubscription)
+	}
+
+	holidayCalendarFile, ok := os.LookupEnv("HOLIDAY_CALENDAR_FILE")
+	if ok {
+		cfg.HolidayCalendarFile = holidayCalendarFile
+		log.Info("Loaded", "HOLIDAY_CALENDAR_FILE", holidayCalendarFile)
+	}
+
+	instanceID, ok := os.LookupEnv("INSTANCE_ID")
 	if !ok {
-		return Config{}, fmt.Errorf("VCAP_SERVICES environment variable not found")
+		instanceID, ok = os.LookupEnv("CF_INSTANCE_GUID")
+	}
+	if ok {
+		cfg.InstanceID = instanceID
+		log.Info("Loaded", "INSTANCE_ID", instanceID)
+	}
+	if cfg.InstanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname for INSTANCE_ID: %s", err)
+		}
+		cfg.InstanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
 	}
-	log.Info("Loaded", "VCAP_SERVICES", "<redacted>")
 
-	var vcapServices VCAPServices
-	err = json.Unmarshal([]byte(vcapServicesStr), &vcapServices)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse VCAP_SERVICES environment variable: %s", err)
+	leaderLeaseDurationStr, ok := os.LookupEnv("LEADER_LEASE_DURATION")
+	if ok {
+		log.Info("Loaded", "LEADER_LEASE_DURATION", leaderLeaseDurationStr)
+
+		leaderLeaseDuration, err := time.ParseDuration(leaderLeaseDurationStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse LEADER_LEASE_DURATION environment variable: %s", err)
+		}
+		cfg.LeaderLeaseDuration = leaderLeaseDuration
+	}
+
+	vcapServicesStr, ok := os.LookupEnv("VCAP_SERVICES")
+	if ok {
+		log.Info("Loaded", "VCAP_SERVICES", "<redacted>")
+
+		var vcapServices VCAPServices
+		err := json.Unmarshal([]byte(vcapServicesStr), &vcapServices)
+		if err != nil {
+			return fmt.Errorf("failed to parse VCAP_SERVICES environment variable: %s", err)
+		}
+		cfg.VCAPServices = vcapServices
+
+		dbService, ok := cfg.GetUserProvidedVCAPServiceByBindingName("db")
+		if ok {
+			dbURI, ok := dbService.Credentials["uri"]
+			if !ok {
+				return errors.New("failed to find uri in credentials of user provided service with binding name 'db'")
+			}
+			cfg.DatabaseURI = dbURI
+		}
+	}
+
+	databaseDriver, ok := os.LookupEnv("DATABASE_DRIVER")
+	if ok {
+		cfg.DatabaseDriver = databaseDriver
+		log.Info("Loaded", "DATABASE_DRIVER", databaseDriver)
+	}
+
+	databaseURLFile, ok := os.LookupEnv("DATABASE_URL_FILE")
+	if ok {
+		databaseURL, err := readSecretFile(databaseURLFile)
+		if err != nil {
+			return fmt.Errorf("failed to read DATABASE_URL_FILE: %s", err)
+		}
+		cfg.DatabaseURI = databaseURL
+		log.Info("Loaded", "DATABASE_URL_FILE", databaseURLFile)
+	}
+
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if ok {
+		cfg.DatabaseURI = databaseURL
+		log.Info("Loaded", "DATABASE_URL", "<redacted>")
+	}
+
+	for name, target := range map[string]*int{
+		"DATABASE_MAX_OPEN_CONNS": &cfg.DatabaseMaxOpenConns,
+		"DATABASE_MAX_IDLE_CONNS": &cfg.DatabaseMaxIdleConns,
+	} {
+		valueStr, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		log.Info("Loaded", name, valueStr)
+
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s environment variable: %s", name, err)
+		}
+		*target = value
+	}
+
+	connMaxLifetimeStr, ok := os.LookupEnv("DATABASE_CONN_MAX_LIFETIME")
+	if ok {
+		log.Info("Loaded", "DATABASE_CONN_MAX_LIFETIME", connMaxLifetimeStr)
+
+		connMaxLifetime, err := time.ParseDuration(connMaxLifetimeStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse DATABASE_CONN_MAX_LIFETIME environment variable: %s", err)
+		}
+		cfg.DatabaseConnMaxLifetime = connMaxLifetime
 	}
 
 	basicAuthUsername, ok := os.LookupEnv("BASIC_AUTH_USERNAME")
-	if !ok {
-		return Config{}, fmt.Errorf("BASIC_AUTH_USERNAME environment variable not found")
+	if ok {
+		cfg.BasicAuthUsername = basicAuthUsername
+		log.Info("Loaded", "BASIC_AUTH_USERNAME", "<redacted>")
 	}
-	log.Info("Loaded", "BASIC_AUTH_USERNAME", "<redacted>")
 
 	basicAuthPassword, ok := os.LookupEnv("BASIC_AUTH_PASSWORD")
-	if !ok {
-		return Config{}, fmt.Errorf("BASIC_AUTH_PASSWORD environment variable not found")
-	}
-	log.Info("Loaded", "BASIC_AUTH_PASSWORD", "<redacted>")
-
-	return Config{
-		Port:                    port,
-		Project:                 project,
-		GCloudPollInterval:      gcloudPollInterval,
-		GCloudGKELabelFilters:   gcloudGKELabelFilter,
-		ClusterLifetimeDuration: clusterLifetimeDuration,
-		VCAPServices:            vcapServices,
-		BasicAuthUsername:       basicAuthUsername,
-		BasicAuthPassword:       basicAuthPassword,
-	}, nil
+	if ok {
+		cfg.BasicAuthPassword = basicAuthPassword
+		log.Info("Loaded", "BASIC_AUTH_PASSWORD", "<redacted>")
+	}
+
+	bearerToken, ok := os.LookupEnv("BEARER_TOKEN")
+	if ok {
+		cfg.BearerToken = bearerToken
+		log.Info("Loaded", "BEARER_TOKEN", "<redacted>")
+	}
+
+	adminBearerToken, ok := os.LookupEnv("ADMIN_BEARER_TOKEN")
+	if ok {
+		cfg.AdminBearerToken = adminBearerToken
+		log.Info("Loaded", "ADMIN_BEARER_TOKEN", "<redacted>")
+	}
+
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Port == 0 {
+		problems = append(problems, "port must be set with PORT or port")
+	}
+	if len(c.Projects) == 0 && c.UsesGCP() {
+		problems = append(problems, "at least one project must be set with PROJECT or projects")
+	}
+	if c.GCloudPollInterval <= 0 {
+		problems = append(problems, "poll interval must be positive")
+	}
+	if c.GCloudPollJitter < 0 || c.GCloudPollJitter >= 1 {
+		problems = append(problems, "poll jitter must be at least 0 and less than 1")
+	}
+	if c.GCloudCallTimeout < 0 || c.DatabaseCallTimeout < 0 || c.ShutdownTimeout < 0 {
+		problems = append(problems, "gcloud call timeout, database call timeout and shutdown timeout must not be negative")
+	}
+	if c.LeaderLeaseDuration < 3*time.Second {
+		problems = append(problems, "leader lease duration must be at least 3s")
+	}
+	if c.ClusterLifetimeDuration <= 0 {
+		problems = append(problems, "cluster lifetime duration must be positive")
+	}
+	if c.UnhealthyLifetime < 0 {
+		problems = append(problems, "unhealthy cluster lifetime duration must not be negative")
+	}
+	if c.Safeguards.MaxDeletionsPerPass < 0 || c.Safeguards.MaxDeletionsPerHour < 0 {
+		problems = append(problems, "max deletions must not be negative")
+	}
+	if c.Safeguards.CircuitBreakerPercent < 0 || c.Safeguards.CircuitBreakerPercent > 100 {
+		problems = append(problems, "circuit breaker percent must be between 0 and 100")
+	}
+	problems = append(problems, c.Protection.validate()...)
+	if !ValidExpiryAction(c.DefaultExpiryAction) {
+		problems = append(problems, fmt.Sprintf("default expiry action %q must be one of delete, scale-to-zero or notify-only", c.DefaultExpiryAction))
+	}
+	for i, rule := range c.ExpiryActionRules {
+		if len(rule.Labels) == 0 {
+			problems = append(problems, fmt.Sprintf("expiry action rule %d must have at least one label", i))
+		}
+		if !ValidExpiryAction(rule.Action) {
+			problems = append(problems, fmt.Sprintf("expiry action rule %d has action %q, it must be one of delete, scale-to-zero or notify-only", i, rule.Action))
+		}
+	}
+	if len(c.Providers) == 0 {
+		problems = append(problems, "at least one provider must be enabled")
+	}
+	for _, provider := range c.Providers {
+		switch provider {
+		case ProviderGKECluster, ProviderComputeInstance, ProviderKindCluster:
+		default:
+			problems = append(problems, fmt.Sprintf("unknown provider %q: must be gke-cluster, compute-instance or kind-cluster", provider))
+		}
+	}
+	switch c.OrphanCleanup {
+	case OrphanCleanupOff, OrphanCleanupReport, OrphanCleanupDelete:
+	default:
+		problems = append(problems, fmt.Sprintf("orphan cleanup %q must be one of off, report or delete", c.OrphanCleanup))
+	}
+	if c.EventsSubscription != "" {
+		if _, _, err := c.ParseEventsSubscription(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if !c.ProviderEnabled(ProviderGKECluster) {
+			problems = append(problems, "gke events subscription requires the gke-cluster provider")
+		}
+	}
+	if c.IdleDetection.Enabled() {
+		problems = append(problems, c.IdleDetection.validate()...)
+		if !c.ProviderEnabled(ProviderGKECluster) {
+			problems = append(problems, "idle detection requires the gke-cluster provider")
+		}
+	}
+	problems = append(problems, c.Digest.validate()...)
+	problems = append(problems, c.RenewalLimits.validate()...)
+	if c.AdminBearerToken != "" && c.AdminBearerToken == c.BearerToken {
+		problems = append(problems, "admin bearer token must differ from the bearer token")
+	}
+	for i, mapping := range c.OwnerMappings {
+		if len(mapping.Labels) == 0 {
+			problems = append(problems, fmt.Sprintf("owner mapping %d must have at least one label", i))
+		}
+		if mapping.Email == "" {
+			problems = append(problems, fmt.Sprintf("owner mapping %d must have an email", i))
+		}
+	}
+	for _, filter := range c.GCloudGKELabelFilters {
+		if !strings.Contains(filter, "=") {
+			problems = append(problems, fmt.Sprintf("label filter %q must be of the form key=value", filter))
+		}
+	}
+	for i, rule := range c.LifetimeRules {
+		if len(rule.Labels) == 0 {
+			problems = append(problems, fmt.Sprintf("lifetime rule %d must have at least one label", i))
+		}
+		if rule.Lifetime <= 0 {
+			problems = append(problems, fmt.Sprintf("lifetime rule %d must have a positive lifetime", i))
+		}
+	}
+	for i, sink := range c.NotificationSinks {
+		if sink.Type != "webhook" && sink.Type != "slack" {
+			problems = append(problems, fmt.Sprintf("notification sink %d has unknown type %q: must be webhook or slack", i, sink.Type))
+		}
+		if sink.URL == "" {
+			problems = append(problems, fmt.Sprintf("notification sink %d must have a url", i))
+		}
+	}
+	if c.DatabaseURI == "" {
+		problems = append(problems, "database uri must be set with DATABASE_URL, DATABASE_URL_FILE, database.uri, database.uri_file or a user provided service with binding name 'db' in VCAP_SERVICES")
+	}
+	switch c.DatabaseDriver {
+	case "", "mysql", "sqlite", "postgres":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown database driver %q: must be one of mysql, sqlite, postgres", c.DatabaseDriver))
+	}
+	if c.DatabaseMaxOpenConns < 0 || c.DatabaseMaxIdleConns < 0 || c.DatabaseConnMaxLifetime < 0 {
+		problems = append(problems, "database connection pool settings must not be negative")
+	}
+	if c.BasicAuthUsername == "" || c.BasicAuthPassword == "" {
+		problems = append(problems, "basic auth username and password must be set with BASIC_AUTH_USERNAME and BASIC_AUTH_PASSWORD or auth.username and auth.password")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+func (c Config) ProviderEnabled(provider string) bool {
+	for _, p := range c.Providers {
+		if p == provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseEventsSubscription splits the events subscription into its project and
+// subscription id.
+func (c Config) ParseEventsSubscription() (string, string, error) {
+	parts := strings.Split(c.EventsSubscription, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[2] != "subscriptions" || parts[3] == "" {
+		return "", "", fmt.Errorf("gke events subscription %q must be of the form projects/<project>/subscriptions/<subscription>", c.EventsSubscription)
+	}
+
+	return parts[1], parts[3], nil
+}
+
+// UsesGCP reports whether any enabled provider talks to GCP.
+func (c Config) UsesGCP() bool {
+	return c.ProviderEnabled(ProviderGKECluster) || c.ProviderEnabled(ProviderComputeInstance)
+}
+
+// SetupGCPCredentials writes the service account key to a temporary file and
+// points GOOGLE_APPLICATION_CREDENTIALS at it. Without a key the application
+// default credentials are used.
+func (c Config) SetupGCPCredentials() error {
+	if c.GCPServiceAccountKey == "" {
+		return nil
+	}
+
+	gcpKeyFile, err := ioutil.TempFile("", "gcp-service-account-key")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for gcp service account key: %s", err)
+	}
+	defer gcpKeyFile.Close()
+
+	_, err = gcpKeyFile.Write([]byte(c.GCPServiceAccountKey))
+	if err != nil {
+		return fmt.Errorf("failed to write to temporary file for gcp service account key: %s", err)
+	}
+
+	return os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", gcpKeyFile.Name())
+}
+
+func readSecretFile(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func (r LifetimeRule) Matches(labels map[string]string) bool {
+	for k, v := range r.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (c Config) GetUserProvidedVCAPServiceByBindingName(bindingName string) (UserProvidedVCAPServices, bool) {
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Digest schedules are how often a digest is sent.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Digest formats are what a digest is rendered as.
+const (
+	DigestFormatMarkdown = "markdown"
+	DigestFormatHTML     = "html"
+)
+
+// Digest sends a summary of the tracked clusters through the notification
+// sinks every Schedule. An empty schedule disables it.
+type Digest struct {
+	Schedule     string
+	Format       string
+	IgnoredDays  int
+	Oldest       int
+	TemplateFile string
+}
+
+type fileDigest struct {
+	Schedule     string `yaml:"schedule,omitempty"`
+	Format       string `yaml:"format,omitempty"`
+	IgnoredDays  int    `yaml:"ignored_days,omitempty"`
+	Oldest       int    `yaml:"oldest,omitempty"`
+	TemplateFile string `yaml:"template_file,omitempty"`
+}
+
+func (d Digest) Enabled() bool {
+	return d.Schedule != ""
+}
+
+// Period is the time a digest covers. It defaults to a day when digests are
+// not scheduled.
+func (d Digest) Period() time.Duration {
+	if d.Schedule == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+func (d Digest) validate() []string {
+	var problems []string
+	switch d.Schedule {
+	case "", DigestDaily, DigestWeekly:
+	default:
+		problems = append(problems, fmt.Sprintf("digest schedule %q must be daily or weekly", d.Schedule))
+	}
+	switch d.Format {
+	case DigestFormatMarkdown, DigestFormatHTML:
+	default:
+		problems = append(problems, fmt.Sprintf("digest format %q must be markdown or html", d.Format))
+	}
+	if d.IgnoredDays < 0 {
+		problems = append(problems, "digest ignored days must not be negative")
+	}
+	if d.Oldest < 0 {
+		problems = append(problems, "digest oldest must not be negative")
+	}
+
+	return problems
+}
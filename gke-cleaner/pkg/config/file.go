@@ -0,0 +1,474 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const redacted = "<redacted>"
+
+type fileConfig struct {
+	Port                    int                    `yaml:"port,omitempty"`
+	Projects                []string               `yaml:"projects,omitempty"`
+	Providers               []string               `yaml:"providers,omitempty"`
+	GCPServiceAccountKey    string                 `yaml:"gcp_service_account_key,omitempty"`
+	PollInterval            string                 `yaml:"poll_interval,omitempty"`
+	PollJitter              *float64               `yaml:"poll_jitter,omitempty"`
+	CallTimeout             string                 `yaml:"call_timeout,omitempty"`
+	LabelFilters            []string               `yaml:"label_filters,omitempty"`
+	ClusterLifetimeDuration string                 `yaml:"cluster_lifetime_duration,omitempty"`
+	LifetimeRules           []fileLifetimeRule     `yaml:"lifetime_rules,omitempty"`
+	UnhealthyLifetime       string                 `yaml:"unhealthy_cluster_lifetime_duration,omitempty"`
+	DefaultExpiryAction     string                 `yaml:"default_expiry_action,omitempty"`
+	ExpiryActionRules       []fileExpiryActionRule `yaml:"expiry_action_rules,omitempty"`
+	Database                fileDatabase           `yaml:"database,omitempty"`
+	NotificationSinks       []fileNotificationSink `yaml:"notification_sinks,omitempty"`
+	DryRun                  *bool                  `yaml:"dry_run,omitempty"`
+	Safeguards              fileSafeguards         `yaml:"safeguards,omitempty"`
+	RenewalLimits           fileRenewalLimits      `yaml:"renewal_limits,omitempty"`
+	Protection              fileProtection         `yaml:"protection,omitempty"`
+	DeletionSchedule        fileDeletionSchedule   `yaml:"deletion_schedule,omitempty"`
+	OrphanCleanup           string                 `yaml:"orphan_cleanup,omitempty"`
+	EventsSubscription      string                 `yaml:"gke_events_subscription,omitempty"`
+	OwnerLabels             []string               `yaml:"owner_labels,omitempty"`
+	OwnerMappings           []fileOwnerMapping     `yaml:"owner_mappings,omitempty"`
+	OwnerAuditLog           *bool                  `yaml:"owner_audit_log,omitempty"`
+	IdleDetection           fileIdleDetection      `yaml:"idle_detection,omitempty"`
+	PricingFile             string                 `yaml:"pricing_file,omitempty"`
+	Digest                  fileDigest             `yaml:"digest,omitempty"`
+	InstanceID              string                 `yaml:"instance_id,omitempty"`
+	LeaderLeaseDuration     string                 `yaml:"leader_lease_duration,omitempty"`
+	ShutdownTimeout         string                 `yaml:"shutdown_timeout,omitempty"`
+	Auth                    fileAuth               `yaml:"auth,omitempty"`
+}
+
+type fileLifetimeRule struct {
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+	Lifetime string            `yaml:"lifetime" json:"lifetime"`
+}
+
+type fileExpiryActionRule struct {
+	Labels map[string]string `yaml:"labels" json:"labels"`
+	Action string            `yaml:"action" json:"action"`
+}
+
+type fileDatabase struct {
+	Driver          string `yaml:"driver,omitempty"`
+	URI             string `yaml:"uri,omitempty"`
+	URIFile         string `yaml:"uri_file,omitempty"`
+	MaxOpenConns    int    `yaml:"max_open_conns,omitempty"`
+	MaxIdleConns    int    `yaml:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string `yaml:"conn_max_lifetime,omitempty"`
+	CallTimeout     string `yaml:"call_timeout,omitempty"`
+}
+
+type fileNotificationSink struct {
+	Type   string   `yaml:"type" json:"type"`
+	URL    string   `yaml:"url" json:"url"`
+	Events []string `yaml:"events,omitempty" json:"events,omitempty"`
+}
+
+type fileSafeguards struct {
+	MaxDeletionsPerPass   int `yaml:"max_deletions_per_pass,omitempty"`
+	MaxDeletionsPerHour   int `yaml:"max_deletions_per_hour,omitempty"`
+	CircuitBreakerPercent int `yaml:"circuit_breaker_percent,omitempty"`
+}
+
+type fileProtection struct {
+	Names       []string `yaml:"names,omitempty"`
+	NameRegexes []string `yaml:"name_regexes,omitempty"`
+	Labels      []string `yaml:"labels,omitempty"`
+}
+
+type fileDeletionSchedule struct {
+	Windows         []string `yaml:"windows,omitempty"`
+	Timezone        string   `yaml:"timezone,omitempty"`
+	HolidayCalendar string   `yaml:"holiday_calendar,omitempty"`
+}
+
+type fileAuth struct {
+	Username         string `yaml:"username,omitempty"`
+	Password         string `yaml:"password,omitempty"`
+	BearerToken      string `yaml:"bearer_token,omitempty"`
+	AdminBearerToken string `yaml:"admin_bearer_token,omitempty"`
+}
+
+func loadFile(path string, cfg *Config) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	var file fileConfig
+	err = yaml.UnmarshalStrict(contents, &file)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %s", path, err)
+	}
+
+	if file.Port != 0 {
+		cfg.Port = file.Port
+	}
+	if len(file.Projects) > 0 {
+		cfg.Projects = file.Projects
+	}
+	if file.GCPServiceAccountKey != "" {
+		cfg.GCPServiceAccountKey = file.GCPServiceAccountKey
+	}
+	if file.PollInterval != "" {
+		cfg.GCloudPollInterval, err = time.ParseDuration(file.PollInterval)
+		if err != nil {
+			return fmt.Errorf("failed to parse poll_interval in config file: %s", err)
+		}
+	}
+	if file.PollJitter != nil {
+		cfg.GCloudPollJitter = *file.PollJitter
+	}
+	if file.CallTimeout != "" {
+		cfg.GCloudCallTimeout, err = time.ParseDuration(file.CallTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to parse call_timeout in config file: %s", err)
+		}
+	}
+	if len(file.LabelFilters) > 0 {
+		cfg.GCloudGKELabelFilters = file.LabelFilters
+	}
+	if file.ClusterLifetimeDuration != "" {
+		cfg.ClusterLifetimeDuration, err = time.ParseDuration(file.ClusterLifetimeDuration)
+		if err != nil {
+			return fmt.Errorf("failed to parse cluster_lifetime_duration in config file: %s", err)
+		}
+	}
+	if len(file.LifetimeRules) > 0 {
+		cfg.LifetimeRules, err = parseLifetimeRules(file.LifetimeRules)
+		if err != nil {
+			return fmt.Errorf("failed to parse lifetime_rules in config file: %s", err)
+		}
+	}
+	if file.UnhealthyLifetime != "" {
+		cfg.UnhealthyLifetime, err = time.ParseDuration(file.UnhealthyLifetime)
+		if err != nil {
+			return fmt.Errorf("failed to parse unhealthy_cluster_lifetime_duration in config file: %s", err)
+		}
+	}
+	if file.DefaultExpiryAction != "" {
+		cfg.DefaultExpiryAction = file.DefaultExpiryAction
+	}
+	if len(file.ExpiryActionRules) > 0 {
+		cfg.ExpiryActionRules = parseExpiryActionRules(file.ExpiryActionRules)
+	}
+	if len(file.Providers) > 0 {
+		cfg.Providers = file.Providers
+	}
+	if file.OrphanCleanup != "" {
+		cfg.OrphanCleanup = file.OrphanCleanup
+	}
+	if file.EventsSubscription != "" {
+		cfg.EventsSubscription = file.EventsSubscription
+	}
+	if len(file.OwnerLabels) > 0 {
+		cfg.OwnerLabels = file.OwnerLabels
+	}
+	if len(file.OwnerMappings) > 0 {
+		cfg.OwnerMappings = parseOwnerMappings(file.OwnerMappings)
+	}
+	if file.OwnerAuditLog != nil {
+		cfg.OwnerAuditLog = *file.OwnerAuditLog
+	}
+	if len(file.IdleDetection.Signals) > 0 {
+		cfg.IdleDetection.Signals = file.IdleDetection.Signals
+	}
+	if file.IdleDetection.Period != "" {
+		cfg.IdleDetection.Period, err = time.ParseDuration(file.IdleDetection.Period)
+		if err != nil {
+			return fmt.Errorf("failed to parse idle_detection.period in config file: %s", err)
+		}
+	}
+	if file.IdleDetection.Lifetime != "" {
+		cfg.IdleDetection.Lifetime, err = time.ParseDuration(file.IdleDetection.Lifetime)
+		if err != nil {
+			return fmt.Errorf("failed to parse idle_detection.lifetime in config file: %s", err)
+		}
+	}
+	if file.IdleDetection.CPUThreshold != nil {
+		cfg.IdleDetection.CPUThreshold = *file.IdleDetection.CPUThreshold
+	}
+	if file.PricingFile != "" {
+		cfg.PricingFile = file.PricingFile
+	}
+	if file.Digest.Schedule != "" {
+		cfg.Digest.Schedule = file.Digest.Schedule
+	}
+	if file.Digest.Format != "" {
+		cfg.Digest.Format = file.Digest.Format
+	}
+	if file.Digest.IgnoredDays != 0 {
+		cfg.Digest.IgnoredDays = file.Digest.IgnoredDays
+	}
+	if file.Digest.Oldest != 0 {
+		cfg.Digest.Oldest = file.Digest.Oldest
+	}
+	if file.Digest.TemplateFile != "" {
+		cfg.Digest.TemplateFile = file.Digest.TemplateFile
+	}
+	if file.Database.URI != "" && file.Database.URIFile != "" {
+		return fmt.Errorf("only one of database.uri or database.uri_file may be set in config file")
+	}
+	if file.Database.URI != "" {
+		cfg.DatabaseURI = file.Database.URI
+	}
+	if file.Database.URIFile != "" {
+		cfg.DatabaseURI, err = readSecretFile(file.Database.URIFile)
+		if err != nil {
+			return fmt.Errorf("failed to read database.uri_file in config file: %s", err)
+		}
+	}
+	if file.Database.Driver != "" {
+		cfg.DatabaseDriver = file.Database.Driver
+	}
+	cfg.DatabaseMaxOpenConns = file.Database.MaxOpenConns
+	cfg.DatabaseMaxIdleConns = file.Database.MaxIdleConns
+	if file.Database.ConnMaxLifetime != "" {
+		cfg.DatabaseConnMaxLifetime, err = time.ParseDuration(file.Database.ConnMaxLifetime)
+		if err != nil {
+			return fmt.Errorf("failed to parse database.conn_max_lifetime in config file: %s", err)
+		}
+	}
+	if file.Database.CallTimeout != "" {
+		cfg.DatabaseCallTimeout, err = time.ParseDuration(file.Database.CallTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to parse database.call_timeout in config file: %s", err)
+		}
+	}
+	if len(file.NotificationSinks) > 0 {
+		cfg.NotificationSinks = parseNotificationSinks(file.NotificationSinks)
+	}
+	if file.DryRun != nil {
+		cfg.DryRun = *file.DryRun
+	}
+	cfg.Safeguards = Safeguards{
+		MaxDeletionsPerPass:   file.Safeguards.MaxDeletionsPerPass,
+		MaxDeletionsPerHour:   file.Safeguards.MaxDeletionsPerHour,
+		CircuitBreakerPercent: file.Safeguards.CircuitBreakerPercent,
+	}
+	cfg.RenewalLimits.MaxRenewals = file.RenewalLimits.MaxRenewals
+	if file.RenewalLimits.MaxAge != "" {
+		cfg.RenewalLimits.MaxAge, err = time.ParseDuration(file.RenewalLimits.MaxAge)
+		if err != nil {
+			return fmt.Errorf("failed to parse renewal_limits.max_age in config file: %s", err)
+		}
+	}
+	cfg.Protection = Protection{
+		NameGlobs:      file.Protection.Names,
+		NameRegexes:    file.Protection.NameRegexes,
+		LabelSelectors: file.Protection.Labels,
+	}
+	if len(file.DeletionSchedule.Windows) > 0 {
+		cfg.DeletionWindows = file.DeletionSchedule.Windows
+	}
+	if file.DeletionSchedule.Timezone != "" {
+		cfg.DeletionTimezone = file.DeletionSchedule.Timezone
+	}
+	if file.DeletionSchedule.HolidayCalendar != "" {
+		cfg.HolidayCalendarFile = file.DeletionSchedule.HolidayCalendar
+	}
+	if file.InstanceID != "" {
+		cfg.InstanceID = file.InstanceID
+	}
+	if file.LeaderLeaseDuration != "" {
+		cfg.LeaderLeaseDuration, err = time.ParseDuration(file.LeaderLeaseDuration)
+		if err != nil {
+			return fmt.Errorf("failed to parse leader_lease_duration in config file: %s", err)
+		}
+	}
+	if file.ShutdownTimeout != "" {
+		cfg.ShutdownTimeout, err = time.ParseDuration(file.ShutdownTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to parse shutdown_timeout in config file: %s", err)
+		}
+	}
+	if file.Auth.Username != "" {
+		cfg.BasicAuthUsername = file.Auth.Username
+	}
+	if file.Auth.Password != "" {
+		cfg.BasicAuthPassword = file.Auth.Password
+	}
+	if file.Auth.BearerToken != "" {
+		cfg.BearerToken = file.Auth.BearerToken
+	}
+	if file.Auth.AdminBearerToken != "" {
+		cfg.AdminBearerToken = file.Auth.AdminBearerToken
+	}
+
+	return nil
+}
+
+func parseLifetimeRules(rules []fileLifetimeRule) ([]LifetimeRule, error) {
+	lifetimeRules := []LifetimeRule{}
+	for i, rule := range rules {
+		lifetime, err := time.ParseDuration(rule.Lifetime)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err)
+		}
+
+		lifetimeRules = append(lifetimeRules, LifetimeRule{
+			Labels:   rule.Labels,
+			Lifetime: lifetime,
+		})
+	}
+
+	return lifetimeRules, nil
+}
+
+func parseExpiryActionRules(rules []fileExpiryActionRule) []ExpiryActionRule {
+	expiryActionRules := []ExpiryActionRule{}
+	for _, rule := range rules {
+		expiryActionRules = append(expiryActionRules, ExpiryActionRule{
+			Labels: rule.Labels,
+			Action: rule.Action,
+		})
+	}
+
+	return expiryActionRules
+}
+
+func parseNotificationSinks(sinks []fileNotificationSink) []NotificationSink {
+	notificationSinks := []NotificationSink{}
+	for _, sink := range sinks {
+		notificationSinks = append(notificationSinks, NotificationSink{
+			Type:   sink.Type,
+			URL:    sink.URL,
+			Events: sink.Events,
+		})
+	}
+
+	return notificationSinks
+}
+
+func unmarshalStrictJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+// RedactedYAML renders the effective config in the config file format with
+// every secret replaced.
+func (c Config) RedactedYAML() ([]byte, error) {
+	file := fileConfig{
+		Port:                    c.Port,
+		Projects:                c.Projects,
+		Providers:               c.Providers,
+		GCPServiceAccountKey:    redact(c.GCPServiceAccountKey),
+		PollInterval:            c.GCloudPollInterval.String(),
+		PollJitter:              &c.GCloudPollJitter,
+		CallTimeout:             c.GCloudCallTimeout.String(),
+		LabelFilters:            c.GCloudGKELabelFilters,
+		ClusterLifetimeDuration: c.ClusterLifetimeDuration.String(),
+		DefaultExpiryAction:     c.DefaultExpiryAction,
+		OrphanCleanup:           c.OrphanCleanup,
+		EventsSubscription:      c.EventsSubscription,
+		OwnerLabels:             c.OwnerLabels,
+		OwnerAuditLog:           &c.OwnerAuditLog,
+		PricingFile:             c.PricingFile,
+		DryRun:                  &c.DryRun,
+		InstanceID:              c.InstanceID,
+		LeaderLeaseDuration:     c.LeaderLeaseDuration.String(),
+		ShutdownTimeout:         c.ShutdownTimeout.String(),
+		IdleDetection: fileIdleDetection{
+			Signals:      c.IdleDetection.Signals,
+			Period:       c.IdleDetection.Period.String(),
+			Lifetime:     c.IdleDetection.Lifetime.String(),
+			CPUThreshold: &c.IdleDetection.CPUThreshold,
+		},
+		Digest: fileDigest{
+			Schedule:     c.Digest.Schedule,
+			Format:       c.Digest.Format,
+			IgnoredDays:  c.Digest.IgnoredDays,
+			Oldest:       c.Digest.Oldest,
+			TemplateFile: c.Digest.TemplateFile,
+		},
+		Safeguards: fileSafeguards{
+			MaxDeletionsPerPass:   c.Safeguards.MaxDeletionsPerPass,
+			MaxDeletionsPerHour:   c.Safeguards.MaxDeletionsPerHour,
+			CircuitBreakerPercent: c.Safeguards.CircuitBreakerPercent,
+		},
+		RenewalLimits: fileRenewalLimits{
+			MaxRenewals: c.RenewalLimits.MaxRenewals,
+			MaxAge:      c.RenewalLimits.MaxAge.String(),
+		},
+		Database: fileDatabase{
+			Driver:       c.DatabaseDriver,
+			URI:          redact(c.DatabaseURI),
+			MaxOpenConns: c.DatabaseMaxOpenConns,
+			MaxIdleConns: c.DatabaseMaxIdleConns,
+			CallTimeout:  c.DatabaseCallTimeout.String(),
+		},
+		Protection: fileProtection{
+			Names:       c.Protection.NameGlobs,
+			NameRegexes: c.Protection.NameRegexes,
+			Labels:      c.Protection.LabelSelectors,
+		},
+		DeletionSchedule: fileDeletionSchedule{
+			Windows:         c.DeletionWindows,
+			Timezone:        c.DeletionTimezone,
+			HolidayCalendar: c.HolidayCalendarFile,
+		},
+		Auth: fileAuth{
+			Username:         redact(c.BasicAuthUsername),
+			Password:         redact(c.BasicAuthPassword),
+			BearerToken:      redact(c.BearerToken),
+			AdminBearerToken: redact(c.AdminBearerToken),
+		},
+	}
+
+	if c.UnhealthyLifetime != 0 {
+		file.UnhealthyLifetime = c.UnhealthyLifetime.String()
+	}
+	if c.DatabaseConnMaxLifetime != 0 {
+		file.Database.ConnMaxLifetime = c.DatabaseConnMaxLifetime.String()
+	}
+
+	for _, rule := range c.LifetimeRules {
+		file.LifetimeRules = append(file.LifetimeRules, fileLifetimeRule{
+			Labels:   rule.Labels,
+			Lifetime: rule.Lifetime.String(),
+		})
+	}
+
+	for _, rule := range c.ExpiryActionRules {
+		file.ExpiryActionRules = append(file.ExpiryActionRules, fileExpiryActionRule{
+			Labels: rule.Labels,
+			Action: rule.Action,
+		})
+	}
+
+	for _, mapping := range c.OwnerMappings {
+		file.OwnerMappings = append(file.OwnerMappings, fileOwnerMapping{
+			Labels: mapping.Labels,
+			Email:  mapping.Email,
+		})
+	}
+
+	for _, sink := range c.NotificationSinks {
+		file.NotificationSinks = append(file.NotificationSinks, fileNotificationSink{
+			Type:   sink.Type,
+			URL:    redact(sink.URL),
+			Events: sink.Events,
+		})
+	}
+
+	return yaml.Marshal(file)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return redacted
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Idle signals tell whether a cluster is in use.
+const (
+	IdleSignalCPU       = "cpu"
+	IdleSignalAPIServer = "api-server"
+	IdleSignalWorkloads = "workloads"
+)
+
+// IdleDetection expires clusters that show no activity on any of the signals
+// for Period early, Lifetime after they were found idle. No signals disables
+// it.
+type IdleDetection struct {
+	Signals      []string
+	Period       time.Duration
+	Lifetime     time.Duration
+	CPUThreshold float64
+}
+
+type fileIdleDetection struct {
+	Signals      []string `yaml:"signals,omitempty"`
+	Period       string   `yaml:"period,omitempty"`
+	Lifetime     string   `yaml:"lifetime,omitempty"`
+	CPUThreshold *float64 `yaml:"cpu_threshold,omitempty"`
+}
+
+func (i IdleDetection) Enabled() bool {
+	return len(i.Signals) > 0
+}
+
+func (i IdleDetection) validate() []string {
+	var problems []string
+	for _, signal := range i.Signals {
+		switch signal {
+		case IdleSignalCPU, IdleSignalAPIServer, IdleSignalWorkloads:
+		default:
+			problems = append(problems, fmt.Sprintf("unknown idle signal %q: must be cpu, api-server or workloads", signal))
+		}
+	}
+	if i.Period <= 0 {
+		problems = append(problems, "idle period must be positive")
+	}
+	if i.Lifetime < 0 {
+		problems = append(problems, "idle cluster lifetime duration must not be negative")
+	}
+	if i.CPUThreshold < 0 || i.CPUThreshold > 1 {
+		problems = append(problems, "idle cpu threshold must be between 0 and 1")
+	}
+
+	return problems
+}
@@ -0,0 +1,35 @@
+package config
+
+// OwnerMapping gives clusters whose labels all match an owner, e.g. a team's
+// email address.
+type OwnerMapping struct {
+	Labels map[string]string
+	Email  string
+}
+
+type fileOwnerMapping struct {
+	Labels map[string]string `yaml:"labels" json:"labels"`
+	Email  string            `yaml:"email" json:"email"`
+}
+
+func (m OwnerMapping) Matches(labels map[string]string) bool {
+	for k, v := range m.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseOwnerMappings(mappings []fileOwnerMapping) []OwnerMapping {
+	ownerMappings := []OwnerMapping{}
+	for _, mapping := range mappings {
+		ownerMappings = append(ownerMappings, OwnerMapping{
+			Labels: mapping.Labels,
+			Email:  mapping.Email,
+		})
+	}
+
+	return ownerMappings
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/schedule"
+)
+
+// Policy is the part of the config that can be reloaded without a restart.
+type Policy struct {
+	ClusterLifetimeDuration time.Duration
+	LifetimeRules           []LifetimeRule
+	UnhealthyLifetime       time.Duration
+	DefaultExpiryAction     string
+	ExpiryActionRules       []ExpiryActionRule
+	LabelFilters            []string
+	NotificationSinks       []NotificationSink
+	DryRun                  bool
+	Safeguards              Safeguards
+	RenewalLimits           RenewalLimits
+	Protection              Protection
+	Schedule                *schedule.Schedule
+	OrphanCleanup           string
+}
+
+func (c Config) Policy() Policy {
+	return Policy{
+		ClusterLifetimeDuration: c.ClusterLifetimeDuration,
+		LifetimeRules:           c.LifetimeRules,
+		UnhealthyLifetime:       c.UnhealthyLifetime,
+		DefaultExpiryAction:     c.DefaultExpiryAction,
+		ExpiryActionRules:       c.ExpiryActionRules,
+		LabelFilters:            c.GCloudGKELabelFilters,
+		NotificationSinks:       c.NotificationSinks,
+		DryRun:                  c.DryRun,
+		Safeguards:              c.Safeguards,
+		RenewalLimits:           c.RenewalLimits,
+		Protection:              c.Protection,
+		Schedule:                c.DeletionSchedule,
+		OrphanCleanup:           c.OrphanCleanup,
+	}
+}
+
+func (p Policy) LifetimeFor(labels map[string]string) time.Duration {
+	for _, rule := range p.LifetimeRules {
+		if rule.Matches(labels) {
+			return rule.Lifetime
+		}
+	}
+
+	return p.ClusterLifetimeDuration
+}
+
+// Diff describes every setting that differs between p and other. Notification
+// sink urls are secrets so only the sink types and events are described.
+func (p Policy) Diff(other Policy) []string {
+	changes := []string{}
+
+	if p.ClusterLifetimeDuration != other.ClusterLifetimeDuration {
+		changes = append(changes, fmt.Sprintf("cluster_lifetime_duration: %s -> %s", p.ClusterLifetimeDuration, other.ClusterLifetimeDuration))
+	}
+	if !reflect.DeepEqual(p.LifetimeRules, other.LifetimeRules) {
+		changes = append(changes, fmt.Sprintf("lifetime_rules: %s -> %s", describeLifetimeRules(p.LifetimeRules), describeLifetimeRules(other.LifetimeRules)))
+	}
+	if p.UnhealthyLifetime != other.UnhealthyLifetime {
+		changes = append(changes, fmt.Sprintf("unhealthy_cluster_lifetime_duration: %s -> %s", p.UnhealthyLifetime, other.UnhealthyLifetime))
+	}
+	if p.DefaultExpiryAction != other.DefaultExpiryAction {
+		changes = append(changes, fmt.Sprintf("default_expiry_action: %s -> %s", p.DefaultExpiryAction, other.DefaultExpiryAction))
+	}
+	if !reflect.DeepEqual(p.ExpiryActionRules, other.ExpiryActionRules) {
+		changes = append(changes, fmt.Sprintf("expiry_action_rules: %v -> %v", p.ExpiryActionRules, other.ExpiryActionRules))
+	}
+	if !reflect.DeepEqual(p.LabelFilters, other.LabelFilters) {
+		changes = append(changes, fmt.Sprintf("label_filters: %v -> %v", p.LabelFilters, other.LabelFilters))
+	}
+	if !reflect.DeepEqual(p.NotificationSinks, other.NotificationSinks) {
+		changes = append(changes, fmt.Sprintf("notification_sinks: %s -> %s", describeNotificationSinks(p.NotificationSinks), describeNotificationSinks(other.NotificationSinks)))
+	}
+	if p.DryRun != other.DryRun {
+		changes = append(changes, fmt.Sprintf("dry_run: %t -> %t", p.DryRun, other.DryRun))
+	}
+	if p.Safeguards != other.Safeguards {
+		changes = append(changes, fmt.Sprintf("safeguards: %+v -> %+v", p.Safeguards, other.Safeguards))
+	}
+	if p.RenewalLimits != other.RenewalLimits {
+		changes = append(changes, fmt.Sprintf("renewal_limits: %+v -> %+v", p.RenewalLimits, other.RenewalLimits))
+	}
+	if !p.Protection.Equal(other.Protection) {
+		changes = append(changes, fmt.Sprintf("protection: %s -> %s", p.Protection, other.Protection))
+	}
+	if !p.Schedule.Equal(other.Schedule) {
+		changes = append(changes, fmt.Sprintf("deletion_schedule: %s -> %s", p.Schedule, other.Schedule))
+	}
+	if p.OrphanCleanup != other.OrphanCleanup {
+		changes = append(changes, fmt.Sprintf("orphan_cleanup: %s -> %s", p.OrphanCleanup, other.OrphanCleanup))
+	}
+
+	return changes
+}
+
+func describeLifetimeRules(rules []LifetimeRule) string {
+	description := []string{}
+	for _, rule := range rules {
+		description = append(description, fmt.Sprintf("%v=%s", rule.Labels, rule.Lifetime))
+	}
+
+	return fmt.Sprintf("%v", description)
+}
+
+func describeNotificationSinks(sinks []NotificationSink) string {
+	description := []string{}
+	for _, sink := range sinks {
+		description = append(description, fmt.Sprintf("%s%v", sink.Type, sink.Events))
+	}
+
+	return fmt.Sprintf("%v", description)
+}
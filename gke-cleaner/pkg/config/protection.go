@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ProtectLabel protects any cluster labelled with it set to "true", whatever
+// the configured protection.
+const ProtectLabel = "gke-cleaner-protect"
+
+// Protection describes clusters that must never be deleted. A cluster is
+// protected if its name matches any glob or regex, or its labels match any
+// selector.
+type Protection struct {
+	NameGlobs      []string
+	NameRegexes    []string
+	LabelSelectors []string
+
+	// nameRegexps are the NameRegexes compiled by validate.
+	nameRegexps []*regexp.Regexp
+}
+
+// Protects reports whether a cluster is protected and why.
+func (p Protection) Protects(name string, labels map[string]string) (bool, string) {
+	if labels[ProtectLabel] == "true" {
+		return true, fmt.Sprintf("labelled %s=true", ProtectLabel)
+	}
+
+	for _, glob := range p.NameGlobs {
+		if matched, _ := path.Match(glob, name); matched {
+			return true, fmt.Sprintf("name matches %q", glob)
+		}
+	}
+
+	for _, re := range p.compiledNameRegexes() {
+		if re.MatchString(name) {
+			return true, fmt.Sprintf("name matches /%s/", re)
+		}
+	}
+
+	for _, selector := range p.LabelSelectors {
+		if matchesSelector(selector, labels) {
+			return true, fmt.Sprintf("labels match %q", selector)
+		}
+	}
+
+	return false, ""
+}
+
+// compiledNameRegexes returns the name regexes compiled by validate. A
+// protection that wasn't validated, e.g. one built in a test, compiles them
+// here.
+func (p Protection) compiledNameRegexes() []*regexp.Regexp {
+	if len(p.nameRegexps) == len(p.NameRegexes) {
+		return p.nameRegexps
+	}
+
+	compiled := []*regexp.Regexp{}
+	for _, expr := range p.NameRegexes {
+		if re, err := regexp.Compile(expr); err == nil {
+			compiled = append(compiled, re)
+		}
+	}
+
+	return compiled
+}
+
+// Equal reports whether p and other protect the same clusters.
+func (p Protection) Equal(other Protection) bool {
+	return reflect.DeepEqual(p.NameGlobs, other.NameGlobs) &&
+		reflect.DeepEqual(p.NameRegexes, other.NameRegexes) &&
+		reflect.DeepEqual(p.LabelSelectors, other.LabelSelectors)
+}
+
+func (p Protection) String() string {
+	return fmt.Sprintf("names %v, name regexes %v, labels %v", p.NameGlobs, p.NameRegexes, p.LabelSelectors)
+}
+
+// validate reports the invalid globs, regexes and selectors, and compiles the
+// regexes so they aren't compiled for every cluster.
+func (p *Protection) validate() []string {
+	var problems []string
+
+	for _, glob := range p.NameGlobs {
+		if _, err := path.Match(glob, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("protected name glob %q is invalid: %s", glob, err))
+		}
+	}
+	p.nameRegexps = nil
+	for _, expr := range p.NameRegexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("protected name regex %q is invalid: %s", expr, err))
+			continue
+		}
+		p.nameRegexps = append(p.nameRegexps, re)
+	}
+	for _, selector := range p.LabelSelectors {
+		for _, requirement := range strings.Split(selector, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(requirement), "!") == "" {
+				problems = append(problems, fmt.Sprintf("protected label selector %q has an empty requirement", selector))
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// matchesSelector matches labels against a comma separated list of
+// requirements, all of which must hold. A requirement is one of key=value,
+// key!=value, key (the label exists) or !key (the label does not exist).
+func matchesSelector(selector string, labels map[string]string) bool {
+	for _, requirement := range strings.Split(selector, ",") {
+		requirement = strings.TrimSpace(requirement)
+
+		switch {
+		case strings.Contains(requirement, "!="):
+			s := strings.SplitN(requirement, "!=", 2)
+			if labels[strings.TrimSpace(s[0])] == strings.TrimSpace(s[1]) {
+				return false
+			}
+		case strings.Contains(requirement, "="):
+			s := strings.SplitN(requirement, "=", 2)
+			value, ok := labels[strings.TrimSpace(s[0])]
+			if !ok || value != strings.TrimSpace(s[1]) {
+				return false
+			}
+		case strings.HasPrefix(requirement, "!"):
+			if _, ok := labels[strings.TrimPrefix(requirement, "!")]; ok {
+				return false
+			}
+		default:
+			if _, ok := labels[requirement]; !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
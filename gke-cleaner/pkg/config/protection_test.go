@@ -0,0 +1,120 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtects(t *testing.T) {
+	protection := Protection{
+		NameGlobs:      []string{"prod-*", "shared-?"},
+		NameRegexes:    []string{"^ci-[0-9]+-keep$"},
+		LabelSelectors: []string{"env=prod", "team=data,!temporary", "owner, tier!=dev"},
+	}
+
+	tests := map[string]struct {
+		name      string
+		labels    map[string]string
+		protected bool
+		reason    string
+	}{
+		"unprotected": {
+			name: "dev-1", labels: map[string]string{"env": "dev"},
+		},
+		"protect label": {
+			name: "dev-1", labels: map[string]string{ProtectLabel: "true"},
+			protected: true, reason: "labelled gke-cleaner-protect=true",
+		},
+		"protect label not true": {
+			name: "dev-1", labels: map[string]string{ProtectLabel: "false"},
+		},
+		"glob": {
+			name: "prod-eu", protected: true, reason: `name matches "prod-*"`,
+		},
+		"single character glob": {
+			name: "shared-1", protected: true, reason: `name matches "shared-?"`,
+		},
+		"glob must match the whole name": {
+			name: "shared-12",
+		},
+		"regex": {
+			name: "ci-42-keep", protected: true, reason: "name matches /^ci-[0-9]+-keep$/",
+		},
+		"regex not matching": {
+			name: "ci-42-keep-not",
+		},
+		"selector": {
+			name: "dev-1", labels: map[string]string{"env": "prod"},
+			protected: true, reason: `labels match "env=prod"`,
+		},
+		"selector with a missing label": {
+			name: "dev-1", labels: map[string]string{"team": "data"},
+			protected: true, reason: `labels match "team=data,!temporary"`,
+		},
+		"selector with a present label that must be missing": {
+			name: "dev-1", labels: map[string]string{"team": "data", "temporary": "yes"},
+		},
+		"selector with an existing label and a different value": {
+			name: "dev-1", labels: map[string]string{"owner": "jane", "tier": "prod"},
+			protected: true, reason: `labels match "owner, tier!=dev"`,
+		},
+		"selector with an excluded value": {
+			name: "dev-1", labels: map[string]string{"owner": "jane", "tier": "dev"},
+		},
+	}
+
+	validated := protection
+	if problems := validated.validate(); len(problems) != 0 {
+		t.Fatalf("expected a valid protection, got %q", problems)
+	}
+	if len(validated.nameRegexps) != len(protection.NameRegexes) {
+		t.Fatalf("expected validate to compile the name regexes, got %v", validated.nameRegexps)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, p := range []Protection{validated, protection} {
+				protected, reason := p.Protects(test.name, test.labels)
+				if protected != test.protected || reason != test.reason {
+					t.Errorf("expected protected=%t with reason %q, got protected=%t with reason %q", test.protected, test.reason, protected, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestProtectionEqual(t *testing.T) {
+	protection := Protection{NameRegexes: []string{"^ci-"}}
+	validated := protection
+	validated.validate()
+
+	if !validated.Equal(protection) {
+		t.Error("expected compiling the regexes not to change the protection")
+	}
+	if validated.Equal(Protection{NameRegexes: []string{"^ci-[0-9]+"}}) {
+		t.Error("expected a different regex to change the protection")
+	}
+
+	changes := Policy{Protection: protection}.Diff(Policy{Protection: validated})
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %q", changes)
+	}
+}
+
+func TestProtectionValidate(t *testing.T) {
+	protection := Protection{
+		NameGlobs:      []string{"prod-[", "ok-*"},
+		NameRegexes:    []string{"ci-(", "^ok$"},
+		LabelSelectors: []string{"env=prod,", "!", "ok"},
+	}
+
+	expected := []string{
+		`protected name glob "prod-[" is invalid: syntax error in pattern`,
+		"protected name regex \"ci-(\" is invalid: error parsing regexp: missing closing ): `ci-(`",
+		`protected label selector "env=prod," has an empty requirement`,
+		`protected label selector "!" has an empty requirement`,
+	}
+	if problems := protection.validate(); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected problems %q, got %q", expected, problems)
+	}
+}
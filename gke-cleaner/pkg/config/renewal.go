@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RenewalLimits cap how often and how far a cluster can be renewed. Renewing
+// beyond them takes an admin or a justification. A zero value disables the
+// limit.
+type RenewalLimits struct {
+	MaxRenewals int
+	MaxAge      time.Duration
+}
+
+type fileRenewalLimits struct {
+	MaxRenewals int    `yaml:"max_renewals,omitempty"`
+	MaxAge      string `yaml:"max_age,omitempty"`
+}
+
+// Exceeded describes the limit a renewal exceeds, given the cluster's number
+// of renewals and age at its new expiration including this renewal, or is
+// empty.
+func (r RenewalLimits) Exceeded(renewals int, age time.Duration) string {
+	switch {
+	case r.MaxRenewals > 0 && renewals > r.MaxRenewals:
+		return fmt.Sprintf("would be renewal %d of at most %d", renewals, r.MaxRenewals)
+	case r.MaxAge > 0 && age > r.MaxAge:
+		return fmt.Sprintf("would make it %s old, over the maximum age of %s", age.Round(time.Minute), r.MaxAge)
+	}
+
+	return ""
+}
+
+// Reached reports whether a cluster with the number of renewals and age at
+// expiration can't be renewed any further without an admin or a
+// justification.
+func (r RenewalLimits) Reached(renewals int, age time.Duration) bool {
+	return r.MaxRenewals > 0 && renewals >= r.MaxRenewals || r.MaxAge > 0 && age >= r.MaxAge
+}
+
+func (r RenewalLimits) validate() []string {
+	var problems []string
+	if r.MaxRenewals < 0 {
+		problems = append(problems, "max renewals must not be negative")
+	}
+	if r.MaxAge < 0 {
+		problems = append(problems, "max cluster age must not be negative")
+	}
+
+	return problems
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenewalLimits(t *testing.T) {
+	limits := RenewalLimits{MaxRenewals: 3, MaxAge: 72 * time.Hour}
+
+	tests := []struct {
+		name     string
+		limits   RenewalLimits
+		renewals int
+		age      time.Duration
+		exceeded bool
+		reached  bool
+	}{
+		{name: "below the limits", limits: limits, renewals: 2, age: 48 * time.Hour},
+		{name: "at the maximum renewals", limits: limits, renewals: 3, age: 48 * time.Hour, reached: true},
+		{name: "beyond the maximum renewals", limits: limits, renewals: 4, age: 48 * time.Hour, exceeded: true, reached: true},
+		{name: "at the maximum age", limits: limits, renewals: 1, age: 72 * time.Hour, reached: true},
+		{name: "beyond the maximum age", limits: limits, renewals: 1, age: 73 * time.Hour, exceeded: true, reached: true},
+		{name: "without limits", renewals: 100, age: 1000 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if exceeded := test.limits.Exceeded(test.renewals, test.age); (exceeded != "") != test.exceeded {
+				t.Errorf("expected exceeded %t, got %q", test.exceeded, exceeded)
+			}
+			if reached := test.limits.Reached(test.renewals, test.age); reached != test.reached {
+				t.Errorf("expected reached %t, got %t", test.reached, reached)
+			}
+		})
+	}
+}
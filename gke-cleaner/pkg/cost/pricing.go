@@ -0,0 +1,102 @@
+package cost
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// hoursPerMonth converts the monthly price of disks into an hourly one.
+const hoursPerMonth = 730
+
+const (
+	defaultDiskType   = "pd-standard"
+	defaultDiskSizeGB = 100
+)
+
+// Pricing is a table of prices, loaded from a file so it can follow the list
+// prices or the discounts of an account.
+type Pricing struct {
+	Currency            string                      `yaml:"currency"`
+	ClusterPerHour      float64                     `yaml:"cluster_per_hour"`
+	MachineTypes        map[string]MachineTypePrice `yaml:"machine_types"`
+	DiskTypesPerGBMonth map[string]float64          `yaml:"disk_types_per_gb_month"`
+}
+
+type MachineTypePrice struct {
+	PerHour            float64 `yaml:"per_hour"`
+	PreemptiblePerHour float64 `yaml:"preemptible_per_hour"`
+}
+
+// NodePool is what the cost of a node pool depends on. NodeCount is the
+// number of nodes across all of its zones.
+type NodePool struct {
+	Name        string
+	MachineType string
+	NodeCount   int
+	Preemptible bool
+	DiskType    string
+	DiskSizeGB  int
+}
+
+func LoadPricing(path string) (*Pricing, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file: %s", err)
+	}
+
+	var pricing Pricing
+	err = yaml.UnmarshalStrict(contents, &pricing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pricing file %s: %s", path, err)
+	}
+
+	if pricing.Currency == "" {
+		pricing.Currency = "USD"
+	}
+
+	return &pricing, nil
+}
+
+// HourlyCost estimates the cost of running a cluster with the node pools for
+// an hour. Disks without a type or size are priced as GKE's default 100GB
+// pd-standard boot disk.
+func (p *Pricing) HourlyCost(nodePools []NodePool) (float64, error) {
+	total := p.ClusterPerHour
+	for _, nodePool := range nodePools {
+		if nodePool.NodeCount == 0 {
+			continue
+		}
+
+		price, found := p.MachineTypes[nodePool.MachineType]
+		if !found {
+			return 0, fmt.Errorf("no price for machine type %s of node pool %s", nodePool.MachineType, nodePool.Name)
+		}
+
+		nodePrice := price.PerHour
+		if nodePool.Preemptible {
+			if price.PreemptiblePerHour == 0 {
+				return 0, fmt.Errorf("no preemptible price for machine type %s of node pool %s", nodePool.MachineType, nodePool.Name)
+			}
+			nodePrice = price.PreemptiblePerHour
+		}
+
+		diskType := nodePool.DiskType
+		if diskType == "" {
+			diskType = defaultDiskType
+		}
+		diskSizeGB := nodePool.DiskSizeGB
+		if diskSizeGB == 0 {
+			diskSizeGB = defaultDiskSizeGB
+		}
+		diskPrice, found := p.DiskTypesPerGBMonth[diskType]
+		if !found {
+			return 0, fmt.Errorf("no price for disk type %s of node pool %s", diskType, nodePool.Name)
+		}
+
+		total += float64(nodePool.NodeCount) * (nodePrice + float64(diskSizeGB)*diskPrice/hoursPerMonth)
+	}
+
+	return total, nil
+}
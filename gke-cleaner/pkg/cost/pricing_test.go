@@ -0,0 +1,115 @@
+package cost
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testPricing = &Pricing{
+	Currency:       "USD",
+	ClusterPerHour: 0.1,
+	MachineTypes: map[string]MachineTypePrice{
+		"e2-standard-4": {PerHour: 0.134, PreemptiblePerHour: 0.04},
+		"n2-standard-8": {PerHour: 0.388},
+	},
+	DiskTypesPerGBMonth: map[string]float64{
+		"pd-standard": 0.04,
+		"pd-ssd":      0.17,
+	},
+}
+
+func TestHourlyCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodePools []NodePool
+		expected  float64
+	}{
+		{
+			name:     "a cluster without node pools",
+			expected: 0.1,
+		},
+		{
+			name:      "a node pool with the default disk",
+			nodePools: []NodePool{{Name: "default", MachineType: "e2-standard-4", NodeCount: 3}},
+			expected:  0.1 + 3*(0.134+100*0.04/730),
+		},
+		{
+			name:      "a preemptible node pool with an ssd",
+			nodePools: []NodePool{{Name: "spot", MachineType: "e2-standard-4", NodeCount: 2, Preemptible: true, DiskType: "pd-ssd", DiskSizeGB: 50}},
+			expected:  0.1 + 2*(0.04+50*0.17/730),
+		},
+		{
+			name: "several node pools, one scaled to zero",
+			nodePools: []NodePool{
+				{Name: "default", MachineType: "e2-standard-4", NodeCount: 1},
+				{Name: "big", MachineType: "n2-standard-8", NodeCount: 0},
+				{Name: "unpriced", MachineType: "a2-highgpu-1g", NodeCount: 0},
+			},
+			expected: 0.1 + 0.134 + 100*0.04/730,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hourlyCost, err := testPricing.HourlyCost(test.nodePools)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(hourlyCost-test.expected) > 1e-9 {
+				t.Errorf("expected %f, got %f", test.expected, hourlyCost)
+			}
+		})
+	}
+}
+
+func TestHourlyCostWithoutAPrice(t *testing.T) {
+	for _, nodePool := range []NodePool{
+		{Name: "unpriced", MachineType: "a2-highgpu-1g", NodeCount: 1},
+		{Name: "preemptible", MachineType: "n2-standard-8", NodeCount: 1, Preemptible: true},
+		{Name: "balanced", MachineType: "e2-standard-4", NodeCount: 1, DiskType: "pd-balanced"},
+	} {
+		_, err := testPricing.HourlyCost([]NodePool{nodePool})
+		if err == nil {
+			t.Errorf("expected an error pricing node pool %s", nodePool.Name)
+		}
+	}
+}
+
+func TestLoadPricing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-pricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pricing.yml")
+	err = ioutil.WriteFile(path, []byte(`
+cluster_per_hour: 0.1
+machine_types:
+  e2-standard-4: {per_hour: 0.134}
+disk_types_per_gb_month: {pd-standard: 0.04}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pricing, err := LoadPricing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pricing.Currency != "USD" || pricing.MachineTypes["e2-standard-4"].PerHour != 0.134 {
+		t.Errorf("expected the pricing in USD, got %+v", pricing)
+	}
+
+	err = ioutil.WriteFile(path, []byte("machine_type: {}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadPricing(path)
+	if err == nil {
+		t.Error("expected an error loading a pricing file with an unknown field")
+	}
+}
@@ -0,0 +1,126 @@
+package digest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+// expiringWithin is how soon a cluster must expire to be listed as expiring.
+const expiringWithin = 24 * time.Hour
+
+// FailureEvents are the events a digest lists as failures.
+var FailureEvents = []string{
+	store.EventDeleteFailed,
+	store.EventBreakerTripped,
+	store.EventConfigReloadFailed,
+}
+
+// Digest summarises the tracked resources for the period from From up to To.
+type Digest struct {
+	From        time.Time
+	To          time.Time
+	IgnoredDays int
+
+	// Expiring are the resources that expire within a day of To and are
+	// neither ignored nor already actioned, soonest first.
+	Expiring []store.ClusterRecord
+
+	// Ignored are the resources ignored for longer than IgnoredDays, longest
+	// first. Resources ignored before the ignore date was recorded have no
+	// IgnoreDate and come first.
+	Ignored []store.ClusterRecord
+
+	// Oldest are the oldest resources, oldest first.
+	Oldest []store.ClusterRecord
+
+	// Capped are the resources that reached the renewal limits, most renewed
+	// first.
+	Capped []store.ClusterRecord
+
+	Deletions []store.DeletionRecord
+	Failures  []store.EventRecord
+}
+
+type Builder struct {
+	ClusterStore  *store.Cluster
+	DeletionStore *store.Deletion
+	EventStore    *store.Event
+	Policy        *policy.Store
+
+	IgnoredDays int
+	Oldest      int
+}
+
+func (b *Builder) Build(ctx context.Context, from time.Time, to time.Time) (Digest, error) {
+	records, err := b.ClusterStore.List(ctx)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	deletions, err := b.DeletionStore.ListBetween(ctx, from, to)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	failures, err := b.EventStore.ListBetween(ctx, FailureEvents, from, to)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	digest := Digest{
+		From:        from,
+		To:          to,
+		IgnoredDays: b.IgnoredDays,
+		Expiring:    []store.ClusterRecord{},
+		Ignored:     []store.ClusterRecord{},
+		Oldest:      []store.ClusterRecord{},
+		Capped:      []store.ClusterRecord{},
+		Deletions:   deletions,
+		Failures:    failures,
+	}
+
+	limits := b.Policy.Get().RenewalLimits
+	ignoredBefore := to.AddDate(0, 0, -b.IgnoredDays)
+	created := []store.ClusterRecord{}
+	for _, record := range records {
+		if !record.CreateDate.IsZero() {
+			created = append(created, record)
+		}
+		if record.ActionTaken == "" && limits.Reached(record.RenewalCount, record.AgeAtExpiration()) {
+			digest.Capped = append(digest.Capped, record)
+		}
+
+		switch {
+		case record.IsIgnored(to):
+			if record.IgnoreDate.Before(ignoredBefore) {
+				digest.Ignored = append(digest.Ignored, record)
+			}
+		case record.ActionTaken == "" && !record.ExpirationDate.Before(to) && record.ExpirationDate.Before(to.Add(expiringWithin)):
+			digest.Expiring = append(digest.Expiring, record)
+		}
+	}
+
+	sort.Slice(digest.Expiring, func(i, j int) bool {
+		return digest.Expiring[i].ExpirationDate.Before(digest.Expiring[j].ExpirationDate)
+	})
+	sort.Slice(digest.Ignored, func(i, j int) bool {
+		return digest.Ignored[i].IgnoreDate.Before(digest.Ignored[j].IgnoreDate)
+	})
+
+	sort.Slice(digest.Capped, func(i, j int) bool {
+		return digest.Capped[i].RenewalCount > digest.Capped[j].RenewalCount
+	})
+
+	sort.Slice(created, func(i, j int) bool {
+		return created[i].CreateDate.Before(created[j].CreateDate)
+	})
+	for i := 0; i < len(created) && i < b.Oldest; i++ {
+		digest.Oldest = append(digest.Oldest, created[i])
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,282 @@
+package digest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+var testLog = zapr.NewLogger(zap.NewNop())
+
+func newTestDB(t *testing.T) *store.DB {
+	dir, err := ioutil.TempDir("", "gke-cleaner-digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = (&migrate.DB{Log: testLog, DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func newTestBuilder(t *testing.T) *Builder {
+	db := newTestDB(t)
+
+	return &Builder{
+		ClusterStore:  &store.Cluster{DB: db},
+		DeletionStore: &store.Deletion{DB: db},
+		EventStore:    &store.Event{DB: db},
+		Policy:        policy.NewStore(config.Policy{RenewalLimits: config.RenewalLimits{MaxRenewals: 3}}),
+		IgnoredDays:   7,
+		Oldest:        2,
+	}
+}
+
+func names(records []store.ClusterRecord) string {
+	n := []string{}
+	for _, record := range records {
+		n = append(n, record.Name)
+	}
+
+	return strings.Join(n, ",")
+}
+
+func TestBuild(t *testing.T) {
+	builder := newTestBuilder(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, record := range []store.ClusterRecord{
+		{Name: "expires-later", CreateDate: now.Add(-72 * time.Hour), ExpirationDate: now.Add(20 * time.Hour)},
+		{Name: "expires-soon", CreateDate: now.Add(-48 * time.Hour), ExpirationDate: now.Add(time.Hour)},
+		{Name: "expires-next-week", CreateDate: now.Add(-time.Hour), ExpirationDate: now.AddDate(0, 0, 7)},
+		{Name: "already-notified", ExpirationDate: now.Add(time.Hour), ActionTaken: "notified"},
+		{Name: "expired", ExpirationDate: now.Add(-time.Hour)},
+		{Name: "ignored-long-ago", CreateDate: now.AddDate(0, 0, -30), ExpirationDate: now.Add(time.Hour), Ignore: true, IgnoreDate: now.AddDate(0, 0, -20)},
+		{Name: "ignored-a-while-ago", ExpirationDate: now.Add(time.Hour), Ignore: true, IgnoreDate: now.AddDate(0, 0, -8)},
+		{Name: "ignored-yesterday", ExpirationDate: now.Add(time.Hour), Ignore: true, IgnoreDate: now.AddDate(0, 0, -1)},
+		{Name: "ignore-ended", ExpirationDate: now.Add(2 * time.Hour), Ignore: true, IgnoreUntil: now.Add(-time.Hour), IgnoreDate: now.AddDate(0, 0, -20)},
+		{Name: "renewed-to-the-limit", ExpirationDate: now.AddDate(0, 0, 7), RenewalCount: 3},
+		{Name: "renewed-beyond-the-limit", ExpirationDate: now.AddDate(0, 0, 7), RenewalCount: 5},
+		{Name: "renewed-once", ExpirationDate: now.AddDate(0, 0, 7), RenewalCount: 1},
+		{Name: "deleted-after-renewals", ExpirationDate: now.Add(-time.Hour), RenewalCount: 4, ActionTaken: "deleted"},
+	} {
+		record.Kind = "gke-cluster"
+		err := builder.ClusterStore.Insert(ctx, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := builder.DeletionStore.Insert(ctx, store.DeletionRecord{ClusterName: "deleted", Kind: "gke-cluster", Owner: "jane", HourlyCost: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = builder.EventStore.Insert(ctx, "gke-cluster", "stuck", store.EventDeleteFailed, "failed to delete cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = builder.EventStore.Insert(ctx, "gke-cluster", "stuck", store.EventUpdated, "cluster updated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := builder.Build(ctx, now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(digest.Expiring); got != "expires-soon,ignore-ended,expires-later" {
+		t.Errorf("expected the clusters expiring within a day, soonest first, got %s", got)
+	}
+	if got := names(digest.Ignored); got != "ignored-long-ago,ignored-a-while-ago" {
+		t.Errorf("expected the clusters ignored for over a week, longest first, got %s", got)
+	}
+	if got := names(digest.Oldest); got != "ignored-long-ago,expires-later" {
+		t.Errorf("expected the two oldest clusters, got %s", got)
+	}
+	if got := names(digest.Capped); got != "renewed-beyond-the-limit,renewed-to-the-limit" {
+		t.Errorf("expected the clusters at the renewal limits, most renewed first, got %s", got)
+	}
+	if len(digest.Deletions) != 1 || digest.Deletions[0].ClusterName != "deleted" {
+		t.Errorf("expected the deletion, got %+v", digest.Deletions)
+	}
+	if len(digest.Failures) != 1 || digest.Failures[0].Type != store.EventDeleteFailed {
+		t.Errorf("expected only the failure event, got %+v", digest.Failures)
+	}
+
+	digest, err = builder.Build(ctx, now.Add(time.Hour), now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digest.Deletions) != 0 || len(digest.Failures) != 0 {
+		t.Errorf("expected no deletions or failures after the period, got %+v and %+v", digest.Deletions, digest.Failures)
+	}
+}
+
+func TestRender(t *testing.T) {
+	renderer, err := NewRenderer(config.DigestFormatMarkdown, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	digest := Digest{
+		From:        now.Add(-24 * time.Hour),
+		To:          now,
+		IgnoredDays: 7,
+		Expiring:    []store.ClusterRecord{{Name: "expires-soon", ExpirationDate: now.Add(time.Hour), Owner: "jane"}},
+		Ignored:     []store.ClusterRecord{{Name: "<ignored>", IgnoreReason: "demo", IgnoreDate: now.AddDate(0, 0, -8)}},
+		Oldest:      []store.ClusterRecord{},
+		Capped:      []store.ClusterRecord{{Name: "renewed-often", RenewalCount: 5}},
+		Deletions:   []store.DeletionRecord{{ClusterName: "deleted", DeleteDate: now}},
+	}
+
+	for format, expected := range map[string][]string{
+		config.DigestFormatMarkdown: {"expires-soon", "jane", "<ignored>", "renewed-often", "deleted", "None."},
+		config.DigestFormatHTML:     {"expires-soon", "jane", "&lt;ignored&gt;", "renewed-often", "deleted", "None."},
+	} {
+		body, err := renderer.Render(format, digest)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		for _, s := range expected {
+			if !strings.Contains(string(body), s) {
+				t.Errorf("%s: expected the digest to contain %q, got %s", format, s, body)
+			}
+		}
+	}
+
+	_, err = renderer.Render("text", digest)
+	if err == nil {
+		t.Error("expected an error rendering an unknown format")
+	}
+}
+
+func TestRenderTemplateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "digest.tmpl")
+	err = ioutil.WriteFile(path, []byte("{{len .Expiring}} expiring"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, err := NewRenderer(config.DigestFormatMarkdown, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := renderer.Render(config.DigestFormatMarkdown, Digest{Expiring: []store.ClusterRecord{{Name: "dev"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "1 expiring" {
+		t.Errorf("expected the template file to be used, got %q", body)
+	}
+
+	err = ioutil.WriteFile(path, []byte("{{.Expiring"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewRenderer(config.DigestFormatMarkdown, path)
+	if err == nil {
+		t.Error("expected an error parsing an invalid template")
+	}
+}
+
+type recordingSink struct {
+	mutex         sync.Mutex
+	notifications []notify.Notification
+}
+
+func (s *recordingSink) Send(ctx context.Context, notification notify.Notification) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.notifications = append(s.notifications, notification)
+
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.notifications)
+}
+
+func TestSendDue(t *testing.T) {
+	builder := newTestBuilder(t)
+	renderer, err := NewRenderer(config.DigestFormatMarkdown, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier, err := notify.NewNotifier(testLog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{}
+	notifier.AddSink(sink, notify.TypeDigest)
+
+	scheduler := &Scheduler{
+		Log:        testLog,
+		Builder:    builder,
+		Renderer:   renderer,
+		Notifier:   notifier,
+		EventStore: builder.EventStore,
+		Format:     config.DigestFormatMarkdown,
+		Period:     24 * time.Hour,
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	err = scheduler.sendDue(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.count() != 1 {
+		t.Fatalf("expected the first digest to be sent on the first check, got %d", sink.count())
+	}
+
+	err = scheduler.sendDue(ctx, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.count() != 1 {
+		t.Errorf("expected no digest before the period has passed, got %d", sink.count())
+	}
+
+	err = scheduler.sendDue(ctx, now.Add(25*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.count() != 2 {
+		t.Errorf("expected a digest once the period has passed, got %d", sink.count())
+	}
+}
@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/christianang/gke-cleaner/pkg/ticker"
+	"github.com/go-logr/logr"
+)
+
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
+// Scheduler sends a digest through the notification sinks once the last one
+// is Period old. The first digest is sent on the first check. Each digest
+// covers the time since the last one, so none is lost to downtime or a change
+// of leader.
+type Scheduler struct {
+	Log        logr.Logger
+	Builder    *Builder
+	Renderer   *Renderer
+	Notifier   *notify.Notifier
+	EventStore *store.Event
+	Leader     LeaderChecker
+
+	Format        string
+	Period        time.Duration
+	CheckInterval time.Duration
+}
+
+func (s *Scheduler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := ticker.New(s.CheckInterval, 0)
+	defer t.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if ctx.Err() != nil || !s.Leader.IsLeader() {
+					continue
+				}
+
+				err := s.sendDue(ctx, time.Now())
+				if err != nil && ctx.Err() == nil {
+					s.Log.Error(err, "Failed to send digest")
+				}
+			}
+		}
+	}()
+
+	close(ready)
+	<-signals
+	cancel()
+	<-done
+
+	return nil
+}
+
+func (s *Scheduler) sendDue(ctx context.Context, now time.Time) error {
+	from := now.Add(-s.Period)
+	last, err := s.EventStore.Latest(ctx, "", "", store.EventDigestSent)
+	switch {
+	case err == nil:
+		if now.Sub(last.CreateDate) < s.Period {
+			return nil
+		}
+		from = last.CreateDate
+	case !errors.Is(err, store.ErrNotFound):
+		return err
+	}
+
+	digest, err := s.Builder.Build(ctx, from, now)
+	if err != nil {
+		return err
+	}
+
+	body, err := s.Renderer.Render(s.Format, digest)
+	if err != nil {
+		return err
+	}
+
+	s.Log.Info("Sending digest", "from", from, "to", now)
+	s.Notifier.NotifyDigest(ctx, s.Format, string(body))
+
+	return s.EventStore.Insert(ctx, "", "", store.EventDigestSent, fmt.Sprintf("%s digest from %s", s.Format, from.Format(time.RFC3339)))
+}
@@ -0,0 +1,200 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+)
+
+const markdownTemplate = `# gke-cleaner digest
+
+{{ formatTime .From }} to {{ formatTime .To }}
+
+## Expiring in the next 24 hours
+{{ range .Expiring }}
+- **{{ .Name }}** ({{ .Kind }}) expires at {{ formatTime .ExpirationDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}
+{{- else }}
+None.
+{{- end }}
+
+## Ignored for more than {{ .IgnoredDays }} days
+{{ range .Ignored }}
+- **{{ .Name }}** ({{ .Kind }}) ignored since {{ formatTime .IgnoreDate }} by {{ or .IgnoredBy "unknown" }}: {{ or .IgnoreReason "no reason given" }}
+{{- else }}
+None.
+{{- end }}
+
+## Oldest
+{{ range .Oldest }}
+- **{{ .Name }}** ({{ .Kind }}) created {{ age .CreateDate $.To }} ago{{ with .Owner }}, owned by {{ . }}{{ end }}
+{{- else }}
+None.
+{{- end }}
+
+## Renewal limit reached
+{{ range .Capped }}
+- **{{ .Name }}** ({{ .Kind }}) renewed {{ .RenewalCount }} times, expires at {{ formatTime .ExpirationDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}
+{{- else }}
+None.
+{{- end }}
+
+## Deleted
+{{ range .Deletions }}
+- **{{ .ClusterName }}** ({{ .Kind }}) at {{ formatTime .DeleteDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}
+{{- else }}
+None.
+{{- end }}
+
+## Failures
+{{ range .Failures }}
+- {{ formatTime .CreateDate }} {{ .Type }}{{ with .ClusterName }} **{{ . }}**{{ end }}: {{ .Message }}
+{{- else }}
+None.
+{{- end }}
+`
+
+const htmlTemplate = `<h1>gke-cleaner digest</h1>
+<p>{{ formatTime .From }} to {{ formatTime .To }}</p>
+
+<h2>Expiring in the next 24 hours</h2>
+{{ if .Expiring }}<ul>
+{{- range .Expiring }}
+<li><b>{{ .Name }}</b> ({{ .Kind }}) expires at {{ formatTime .ExpirationDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+
+<h2>Ignored for more than {{ .IgnoredDays }} days</h2>
+{{ if .Ignored }}<ul>
+{{- range .Ignored }}
+<li><b>{{ .Name }}</b> ({{ .Kind }}) ignored since {{ formatTime .IgnoreDate }} by {{ or .IgnoredBy "unknown" }}: {{ or .IgnoreReason "no reason given" }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+
+<h2>Oldest</h2>
+{{ if .Oldest }}<ul>
+{{- range .Oldest }}
+<li><b>{{ .Name }}</b> ({{ .Kind }}) created {{ age .CreateDate $.To }} ago{{ with .Owner }}, owned by {{ . }}{{ end }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+
+<h2>Renewal limit reached</h2>
+{{ if .Capped }}<ul>
+{{- range .Capped }}
+<li><b>{{ .Name }}</b> ({{ .Kind }}) renewed {{ .RenewalCount }} times, expires at {{ formatTime .ExpirationDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+
+<h2>Deleted</h2>
+{{ if .Deletions }}<ul>
+{{- range .Deletions }}
+<li><b>{{ .ClusterName }}</b> ({{ .Kind }}) at {{ formatTime .DeleteDate }}{{ with .Owner }}, owned by {{ . }}{{ end }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+
+<h2>Failures</h2>
+{{ if .Failures }}<ul>
+{{- range .Failures }}
+<li>{{ formatTime .CreateDate }} {{ .Type }}{{ with .ClusterName }} <b>{{ . }}</b>{{ end }}: {{ .Message }}</li>
+{{- end }}
+</ul>{{ else }}<p>None.</p>{{ end }}
+`
+
+var funcs = map[string]interface{}{
+	"formatTime": formatTime,
+	"age":        age,
+}
+
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Renderer renders digests as markdown or html. A template file replaces the
+// built-in template of one format.
+type Renderer struct {
+	templates map[string]executor
+}
+
+func NewRenderer(format string, templateFile string) (*Renderer, error) {
+	markdown, err := template.New(config.DigestFormatMarkdown).Funcs(funcs).Parse(markdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New(config.DigestFormatHTML).Funcs(funcs).Parse(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := &Renderer{templates: map[string]executor{
+		config.DigestFormatMarkdown: markdown,
+		config.DigestFormatHTML:     html,
+	}}
+
+	if templateFile != "" {
+		contents, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read digest template: %s", err)
+		}
+
+		var custom executor
+		switch format {
+		case config.DigestFormatHTML:
+			custom, err = htmltemplate.New(format).Funcs(funcs).Parse(string(contents))
+		default:
+			custom, err = template.New(format).Funcs(funcs).Parse(string(contents))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse digest template %s: %s", templateFile, err)
+		}
+		renderer.templates[format] = custom
+	}
+
+	return renderer, nil
+}
+
+func (r *Renderer) Render(format string, digest Digest) ([]byte, error) {
+	t, found := r.templates[format]
+	if !found {
+		return nil, fmt.Errorf("unknown digest format %q: must be markdown or html", format)
+	}
+
+	var b bytes.Buffer
+	err := t.Execute(&b, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest: %s", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// ContentType is the media type of a digest in the format.
+func ContentType(format string) string {
+	if format == config.DigestFormatHTML {
+		return "text/html; charset=utf-8"
+	}
+
+	return "text/markdown; charset=utf-8"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// age is how long before now t was, in days or, under a day, hours.
+func age(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+
+	return fmt.Sprintf("%dh", int(d.Hours()))
+}
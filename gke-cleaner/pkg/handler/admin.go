@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/snapshot"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+type Reloader interface {
+	Reload(ctx context.Context, source string) ([]string, error)
+}
+
+type Admin struct {
+	Log          logr.Logger
+	Reloader     Reloader
+	ClusterStore *store.Cluster
+	BreakerStore *store.Breaker
+	EventStore   *store.Event
+	Notifier     *notify.Notifier
+}
+
+func (a *Admin) Reload(w http.ResponseWriter, req *http.Request) {
+	changes, err := a.Reloader.Reload(req.Context(), "admin endpoint")
+	if err != nil {
+		a.Log.Error(err, "failed to reload config")
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("failed to reload config: %s", err))
+		return
+	}
+
+	writeJSON(a.Log, w, api.ReloadResponse{Changes: changes})
+}
+
+func (a *Admin) Breaker(w http.ResponseWriter, req *http.Request) {
+	breaker, err := a.BreakerStore.Get(req.Context(), store.DeletionBreaker)
+	if err != nil {
+		a.Log.Error(err, "failed to get deletion circuit breaker")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(a.Log, w, toAPIBreaker(breaker))
+}
+
+func (a *Admin) Arm(w http.ResponseWriter, req *http.Request) {
+	var request api.ArmRequest
+	if !decodeBody(w, req, &request) {
+		return
+	}
+
+	breaker, err := a.BreakerStore.Get(req.Context(), store.DeletionBreaker)
+	if err != nil {
+		a.Log.Error(err, "failed to get deletion circuit breaker")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !breaker.Tripped {
+		writeError(w, http.StatusConflict, "the deletion circuit breaker is not tripped")
+		return
+	}
+
+	err = a.BreakerStore.Arm(req.Context(), store.DeletionBreaker, request.Reason)
+	if err != nil {
+		a.Log.Error(err, "failed to arm deletion circuit breaker")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	message := "armed by an admin"
+	if request.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, request.Reason)
+	}
+	a.recordEvent(store.EventBreakerArmed, message)
+
+	breaker, err = a.BreakerStore.Get(req.Context(), store.DeletionBreaker)
+	if err != nil {
+		a.Log.Error(err, "failed to get deletion circuit breaker")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(a.Log, w, toAPIBreaker(breaker))
+}
+
+func (a *Admin) Import(w http.ResponseWriter, req *http.Request) {
+	strategy := req.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = snapshot.StrategySkip
+	}
+	err := snapshot.ValidateStrategy(strategy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = snapshot.FormatJSON
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "text/csv") {
+			format = snapshot.FormatCSV
+		}
+	}
+	err = snapshot.ValidateFormat(format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, err := snapshot.Read(req.Body, format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := snapshot.Import(req.Context(), a.ClusterStore, records, strategy)
+	if errors.Is(err, snapshot.ErrConflict) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		a.Log.Error(err, "failed to import clusters")
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to import clusters: %s", err))
+		return
+	}
+
+	a.recordEvent(store.EventImported, fmt.Sprintf("%d inserted, %d updated, %d skipped with the %s strategy", len(response.Inserted), len(response.Updated), len(response.Skipped), strategy))
+
+	writeJSON(a.Log, w, response)
+}
+
+// recordEvent outlives the request, since what it records has already
+// happened.
+func (a *Admin) recordEvent(eventType string, message string) {
+	err := a.EventStore.Insert(context.Background(), "", "", eventType, message)
+	if err != nil {
+		a.Log.Error(err, "failed to record event", "type", eventType)
+	}
+
+	a.Notifier.Notify(context.Background(), "", eventType, message)
+}
+
+func toAPIBreaker(breaker store.BreakerRecord) api.Breaker {
+	return api.Breaker{
+		Tripped:   breaker.Tripped,
+		Reason:    breaker.Reason,
+		UpdatedAt: breaker.UpdatedAt,
+	}
+}
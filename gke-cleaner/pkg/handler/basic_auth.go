@@ -1,23 +1,48 @@
 package handler
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 )
 
+type adminKey struct{}
+
 type BasicAuth struct {
-	Username string
-	Password string
+	Username    string
+	Password    string
+	BearerToken string
+
+	// AdminBearerToken authenticates admins, who may renew clusters beyond
+	// the renewal limits and use the admin endpoints.
+	AdminBearerToken string
 }
 
 func (b BasicAuth) Handle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (b.BearerToken != "" || b.AdminBearerToken != "") && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if b.AdminBearerToken != "" && secureCompare(token, b.AdminBearerToken) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminKey{}, true)))
+				return
+			}
+			if b.BearerToken == "" || !secureCompare(token, b.BearerToken) {
+				writeUnauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		username, password, ok := r.BasicAuth()
 		if !ok {
 			writeUnauthorized(w)
 			return
 		}
 
-		if username != b.Username && password != b.Password {
+		if !secureCompare(username, b.Username) || !secureCompare(password, b.Password) {
 			writeUnauthorized(w)
 			return
 		}
@@ -26,7 +51,47 @@ func (b BasicAuth) Handle(next http.Handler) http.Handler {
 	})
 }
 
+// requester names who sent an authenticated request: the basic auth username,
+// admin or bearer-token.
+func requester(r *http.Request) string {
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	if isAdmin(r) {
+		return "admin"
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return "bearer-token"
+	}
+
+	return ""
+}
+
+// isAdmin reports whether the request was authenticated with the admin bearer
+// token.
+func isAdmin(r *http.Request) bool {
+	admin, _ := r.Context().Value(adminKey{}).(bool)
+	return admin
+}
+
+// requireAdmin responds with 403 unless the request was authenticated with the
+// admin bearer token.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			writeError(w, http.StatusForbidden, "this endpoint requires the admin bearer token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func secureCompare(given string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
 func writeUnauthorized(w http.ResponseWriter) {
 	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-	w.WriteHeader(http.StatusUnauthorized)
+	writeError(w, http.StatusUnauthorized, "unauthorized")
 }
@@ -1,74 +1,522 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/poller"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/snapshot"
 	"github.com/christianang/gke-cleaner/pkg/store"
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
 )
 
+type Syncer interface {
+	Sync(ctx context.Context) error
+}
+
+type Restorer interface {
+	Restore(ctx context.Context, name string, expirationDate time.Time) error
+}
+
 type Cluster struct {
-	Log              logr.Logger
-	ClusterStore     *store.Cluster
-	LifetimeDuration time.Duration
+	Log          logr.Logger
+	ClusterStore *store.Cluster
+	EventStore   *store.Event
+	Notifier     *notify.Notifier
+	Syncer       Syncer
+	Restorer     Restorer
+	Policy       *policy.Store
 }
 
 func (c *Cluster) List(w http.ResponseWriter, req *http.Request) {
-	knownClusters, err := c.ClusterStore.List(context.Background())
+	knownClusters, err := c.ClusterStore.List(req.Context())
 	if err != nil {
 		c.Log.Error(err, "failed to list clusters")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	body, err := json.Marshal(knownClusters)
+	kind := req.URL.Query().Get("kind")
+	owner := req.URL.Query().Get("owner")
+
+	clusters := []api.Cluster{}
+	for _, cluster := range knownClusters {
+		if kind != "" && cluster.Kind != kind {
+			continue
+		}
+		if owner != "" && !strings.EqualFold(cluster.Owner, owner) {
+			continue
+		}
+		clusters = append(clusters, toAPICluster(cluster, c.Policy.Get(), time.Now()))
+	}
+
+	c.writeJSON(w, clusters)
+}
+
+func (c *Cluster) Export(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = snapshot.FormatJSON
+	}
+	err := snapshot.ValidateFormat(format)
 	if err != nil {
-		c.Log.Error(err, "failed to marshal clusters")
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	clusters, err := c.ClusterStore.List(req.Context())
+	if err != nil {
+		c.Log.Error(err, "failed to list clusters")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	_, err = w.Write(body)
+	var body bytes.Buffer
+	err = snapshot.Write(&body, format, clusters)
+	if err != nil {
+		c.Log.Error(err, "failed to export clusters")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/json"
+	if format == snapshot.FormatCSV {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=clusters.%s", format))
+	_, err = w.Write(body.Bytes())
 	if err != nil {
 		c.Log.Error(err, "failed to write to response body")
+	}
+}
+
+func (c *Cluster) Get(w http.ResponseWriter, req *http.Request) {
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	c.writeJSON(w, toAPICluster(cluster, c.Policy.Get(), time.Now()))
+}
+
+func (c *Cluster) History(w http.ResponseWriter, req *http.Request) {
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	events, err := c.EventStore.ListByCluster(req.Context(), cluster.Kind, cluster.Name)
+	if err != nil {
+		c.Log.Error(err, "failed to list events")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	apiEvents := []api.Event{}
+	for _, event := range events {
+		apiEvents = append(apiEvents, toAPIEvent(event))
+	}
+
+	c.writeJSON(w, apiEvents)
+}
+
+func (c *Cluster) Sync(w http.ResponseWriter, req *http.Request) {
+	err := c.Syncer.Sync(req.Context())
+	if errors.Is(err, poller.ErrNotLeader) {
+		writeError(w, http.StatusConflict, "this instance is not the leader, retry the sync against the leader")
+		return
+	}
+	if err != nil {
+		c.Log.Error(err, "failed to sync clusters")
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to sync clusters: %s", err))
+		return
+	}
 }
 
 func (c *Cluster) Renew(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
+	renewRequest, expirationDate, ok := c.decodeRenewRequest(w, req)
+	if !ok {
+		return
+	}
+
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	renewed := cluster.Renewed(expirationDate, time.Now())
+
+	justification := strings.TrimSpace(renewRequest.Justification)
+	exceeded := c.Policy.Get().RenewalLimits.Exceeded(renewed.RenewalCount, renewed.AgeAtExpiration())
+	if exceeded != "" && justification == "" && !isAdmin(req) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("renewing cluster %q %s: renew it as an admin or with a justification, or ignore it", cluster.Name, exceeded))
+		return
+	}
 
-	err := c.ClusterStore.UpdateExpirationDate(context.Background(), vars["name"], time.Now().Add(c.LifetimeDuration))
+	err := c.ClusterStore.UpdateRenewal(req.Context(), cluster.Kind, cluster.Name, expirationDate, renewed.RenewalCount, renewed.RenewalExtension)
 	if err != nil {
-		c.Log.Error(err, "failed to update ignore")
+		c.Log.Error(err, "failed to update expiration date")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// A notify-only cluster is notified again the next time it expires. A
+	// cluster that was scaled to zero stays scaled down until it is restored.
+	if cluster.ActionTaken == config.ActionNotifyOnly {
+		err = c.ClusterStore.UpdateActionTaken(req.Context(), cluster.Kind, cluster.Name, "")
+		if err != nil {
+			c.Log.Error(err, "failed to clear action taken")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	message := fmt.Sprintf("expires at %s, renewal %d", expirationDate.Format(time.RFC3339), renewed.RenewalCount)
+	switch {
+	case exceeded != "" && justification != "":
+		message = fmt.Sprintf("%s, beyond the renewal limits: %s", message, justification)
+	case exceeded != "":
+		message = fmt.Sprintf("%s, beyond the renewal limits by an admin", message)
+	}
+	c.recordEvent(cluster, store.EventRenewed, message)
+}
+
+func (c *Cluster) Restore(w http.ResponseWriter, req *http.Request) {
+	_, expirationDate, ok := c.decodeRenewRequest(w, req)
+	if !ok {
+		return
+	}
+
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	if cluster.Kind != provider.KindGKECluster || cluster.ActionTaken != config.ActionScaleToZero || c.Restorer == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("cluster %q was not scaled to zero", cluster.Name))
+		return
+	}
+
+	err := c.Restorer.Restore(req.Context(), cluster.Name, expirationDate)
+	if errors.Is(err, poller.ErrNotScaledDown) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("cluster %q was not scaled to zero", cluster.Name))
+		return
+	}
+	if errors.Is(err, poller.ErrRestoreInProgress) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("cluster %q is already being restored", cluster.Name))
+		return
+	}
+	if errors.Is(err, poller.ErrNotLeader) {
+		writeError(w, http.StatusConflict, "this instance is not the leader, retry the restore against the leader")
+		return
+	}
+	if err != nil {
+		c.Log.Error(err, "failed to restore cluster", "cluster", cluster.Name)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to restore cluster: %s", err))
+		return
+	}
+}
+
+func (c *Cluster) SetAction(w http.ResponseWriter, req *http.Request) {
+	var actionRequest api.ActionRequest
+	if !decodeBody(w, req, &actionRequest) {
+		return
+	}
+
+	if actionRequest.Action != "" && !config.ValidExpiryAction(actionRequest.Action) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid action: %q", actionRequest.Action))
+		return
+	}
+
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	err := c.ClusterStore.UpdateExpiryAction(req.Context(), cluster.Kind, cluster.Name, actionRequest.Action)
+	if err != nil {
+		c.Log.Error(err, "failed to update expiry action")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	message := fmt.Sprintf("expiry action set to %s", actionRequest.Action)
+	if actionRequest.Action == "" {
+		message = "expiry action reset to the policy default"
+	}
+	c.recordEvent(cluster, store.EventAction, message)
+}
+
+// decodeRenewRequest returns the expiration date requested by a renew request
+// body, defaulting to the cluster lifetime from now.
+func (c *Cluster) decodeRenewRequest(w http.ResponseWriter, req *http.Request) (api.RenewRequest, time.Time, bool) {
+	var renewRequest api.RenewRequest
+	if !decodeBody(w, req, &renewRequest) {
+		return api.RenewRequest{}, time.Time{}, false
+	}
+
+	expirationDate := time.Now().Add(c.Policy.Get().ClusterLifetimeDuration)
+	switch {
+	case renewRequest.Duration != "" && !renewRequest.Until.IsZero():
+		writeError(w, http.StatusBadRequest, "only one of Duration or Until may be set")
+		return api.RenewRequest{}, time.Time{}, false
+	case renewRequest.Duration != "":
+		duration, err := time.ParseDuration(renewRequest.Duration)
+		if err != nil || duration <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid duration: %q", renewRequest.Duration))
+			return api.RenewRequest{}, time.Time{}, false
+		}
+		expirationDate = time.Now().Add(duration)
+	case !renewRequest.Until.IsZero():
+		if renewRequest.Until.Before(time.Now()) {
+			writeError(w, http.StatusBadRequest, "until must be in the future")
+			return api.RenewRequest{}, time.Time{}, false
+		}
+		expirationDate = renewRequest.Until
+	}
+
+	return renewRequest, expirationDate, true
 }
 
 func (c *Cluster) Ignore(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
+	var ignoreRequest api.IgnoreRequest
+	if !decodeBody(w, req, &ignoreRequest) {
+		return
+	}
+
+	if !ignoreRequest.Until.IsZero() && ignoreRequest.Until.Before(time.Now()) {
+		writeError(w, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
 
-	err := c.ClusterStore.UpdateIgnore(context.Background(), vars["name"], true)
+	// Ignoring is the way past the renewal limits, so it must be justified
+	// like a renewal beyond them.
+	ignoreRequest.Reason = strings.TrimSpace(ignoreRequest.Reason)
+	limitReached := c.Policy.Get().RenewalLimits.Reached(cluster.RenewalCount, cluster.AgeAtExpiration())
+	if limitReached && ignoreRequest.Reason == "" && !isAdmin(req) {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("cluster %q reached the renewal limits: ignore it as an admin or with a reason", cluster.Name))
+		return
+	}
+
+	// Only an admin can ignore a cluster on behalf of someone else.
+	ignoredBy := requester(req)
+	if ignoreRequest.By != "" && ignoreRequest.By != ignoredBy {
+		if !isAdmin(req) {
+			writeError(w, http.StatusForbidden, "only an admin can ignore a cluster on behalf of someone else")
+			return
+		}
+		ignoredBy = ignoreRequest.By
+	}
+
+	err := c.ClusterStore.UpdateIgnore(req.Context(), cluster.Kind, cluster.Name, true, ignoreRequest.Until, ignoreRequest.Reason, ignoredBy, cluster.IgnoreStart(time.Now()))
 	if err != nil {
 		c.Log.Error(err, "failed to update ignore")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	message := ignoreRequest.Reason
+	if !ignoreRequest.Until.IsZero() {
+		message = fmt.Sprintf("until %s: %s", ignoreRequest.Until.Format(time.RFC3339), ignoreRequest.Reason)
+	}
+	c.recordEvent(cluster, store.EventIgnored, message)
 }
 
 func (c *Cluster) Unignore(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
+	cluster, ok := c.getCluster(w, req)
+	if !ok {
+		return
+	}
+
+	if protected, reason := c.Policy.Get().Protection.Protects(cluster.Name, cluster.Labels); protected {
+		writeError(w, http.StatusConflict, fmt.Sprintf("cluster %q is protected and cannot be unignored: %s", cluster.Name, reason))
+		return
+	}
 
-	err := c.ClusterStore.UpdateIgnore(context.Background(), vars["name"], false)
+	err := c.ClusterStore.UpdateIgnore(req.Context(), cluster.Kind, cluster.Name, false, time.Time{}, "", "", time.Time{})
 	if err != nil {
 		c.Log.Error(err, "failed to update ignore")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	c.recordEvent(cluster, store.EventUnignored, "")
+}
+
+// getCluster gets the cluster named in the path, of the kind in the query if
+// there is one. Without a kind the name must be unique across kinds.
+func (c *Cluster) getCluster(w http.ResponseWriter, req *http.Request) (store.ClusterRecord, bool) {
+	name := mux.Vars(req)["name"]
+	kind := req.URL.Query().Get("kind")
+
+	var clusters []store.ClusterRecord
+	if kind != "" {
+		cluster, err := c.ClusterStore.Get(req.Context(), kind, name)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			c.Log.Error(err, "failed to get cluster", "cluster", name, "kind", kind)
+			w.WriteHeader(http.StatusInternalServerError)
+			return store.ClusterRecord{}, false
+		}
+		if err == nil {
+			clusters = append(clusters, cluster)
+		}
+	} else {
+		var err error
+		clusters, err = c.ClusterStore.ListByName(req.Context(), name)
+		if err != nil {
+			c.Log.Error(err, "failed to get cluster", "cluster", name)
+			w.WriteHeader(http.StatusInternalServerError)
+			return store.ClusterRecord{}, false
+		}
+	}
+
+	switch len(clusters) {
+	case 0:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("cluster %q not found", name))
+		return store.ClusterRecord{}, false
+	case 1:
+		return clusters[0], true
+	default:
+		kinds := []string{}
+		for _, cluster := range clusters {
+			kinds = append(kinds, cluster.Kind)
+		}
+		writeError(w, http.StatusConflict, fmt.Sprintf("%q names resources of more than one kind, pass one of %s as kind", name, strings.Join(kinds, ", ")))
+		return store.ClusterRecord{}, false
+	}
+}
+
+// recordEvent records a change that was already made, so it isn't cancelled
+// if the client goes away.
+func (c *Cluster) recordEvent(cluster store.ClusterRecord, eventType string, message string) {
+	err := c.EventStore.Insert(context.Background(), cluster.Kind, cluster.Name, eventType, message)
+	if err != nil {
+		c.Log.Error(err, "failed to record event", "cluster", cluster.Name, "kind", cluster.Kind, "type", eventType)
+	}
+
+	c.Notifier.Notify(context.Background(), cluster.Name, eventType, message)
+}
+
+func (c *Cluster) writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSON(c.Log, w, v)
+}
+
+func writeJSON(log logr.Logger, w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Error(err, "failed to marshal response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	if err != nil {
+		log.Error(err, "failed to write to response body")
+		return
+	}
+}
+
+func decodeBody(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	err := json.NewDecoder(req.Body).Decode(v)
+	if err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode request body: %s", err))
+		return false
+	}
+
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(api.ErrorResponse{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func toAPICluster(cluster store.ClusterRecord, policy config.Policy, now time.Time) api.Cluster {
+	protected, protectedReason := policy.Protection.Protects(cluster.Name, cluster.Labels)
+
+	return api.Cluster{
+		ID:              cluster.ID,
+		Kind:            cluster.Kind,
+		Name:            cluster.Name,
+		Project:         cluster.Project,
+		Location:        cluster.Location,
+		CreateDate:      cluster.CreateDate,
+		ExpirationDate:  cluster.ExpirationDate,
+		DeletionDate:    deletionDate(cluster, protected, policy, now),
+		Ignore:          cluster.Ignore,
+		IgnoreUntil:     cluster.IgnoreUntil,
+		IgnoreReason:    cluster.IgnoreReason,
+		IgnoredBy:       cluster.IgnoredBy,
+		IgnoreDate:      cluster.IgnoreDate,
+		Status:          cluster.Status,
+		Labels:          cluster.Labels,
+		Protected:       protected,
+		ProtectedReason: protectedReason,
+		ExpiryAction:    policy.ExpiryActionFor(cluster.ExpiryAction, cluster.Labels),
+		ActionTaken:     cluster.ActionTaken,
+		Owner:           cluster.Owner,
+		OwnerSource:     cluster.OwnerSource,
+		Idle:            cluster.IsIdle(),
+		IdleSince:       cluster.IdleSince,
+		HourlyCost:      cluster.HourlyCost,
+
+		RenewalCount:        cluster.RenewalCount,
+		RenewalExtension:    cluster.RenewalExtension.String(),
+		RenewalLimitReached: policy.RenewalLimits.Reached(cluster.RenewalCount, cluster.AgeAtExpiration()),
+	}
+}
+
+// deletionDate is when the cluster will actually be deleted once its
+// expiration, any ignore and the deletion schedule are taken into account. It
+// is zero if the cluster will not be deleted.
+func deletionDate(cluster store.ClusterRecord, protected bool, policy config.Policy, now time.Time) time.Time {
+	if protected || cluster.IsIgnored(now) && cluster.IgnoreUntil.IsZero() {
+		return time.Time{}
+	}
+
+	if policy.ExpiryActionFor(cluster.ExpiryAction, cluster.Labels) != config.ActionDelete {
+		return time.Time{}
+	}
+
+	earliest := now
+	if cluster.ExpirationDate.After(earliest) {
+		earliest = cluster.ExpirationDate
+	}
+	if cluster.IsIgnored(now) && cluster.IgnoreUntil.After(earliest) {
+		earliest = cluster.IgnoreUntil
+	}
+
+	return policy.Schedule.Next(earliest)
+}
+
+func toAPIEvent(event store.EventRecord) api.Event {
+	return api.Event{
+		ID:          event.ID,
+		Kind:        event.Kind,
+		ClusterName: event.ClusterName,
+		Type:        event.Type,
+		Message:     event.Message,
+		CreateDate:  event.CreateDate,
+	}
 }
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func newTestCluster(t *testing.T, limits config.RenewalLimits) *Cluster {
+	dir, err := ioutil.TempDir("", "gke-cleaner-handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	log := zapr.NewLogger(zap.NewNop())
+	err = (&migrate.DB{Log: log, DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, err := notify.NewNotifier(log, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Cluster{
+		Log:          log,
+		ClusterStore: &store.Cluster{DB: db},
+		EventStore:   &store.Event{DB: db},
+		Notifier:     notifier,
+		Policy:       policy.NewStore(config.Policy{ClusterLifetimeDuration: time.Hour, RenewalLimits: limits}),
+	}
+}
+
+func TestIgnoreRecordsTheRequester(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		admin     bool
+		status    int
+		ignoredBy string
+	}{
+		{name: "without by", body: `{}`, status: http.StatusOK, ignoredBy: "jane"},
+		{name: "by the requester", body: `{"By": "jane"}`, status: http.StatusOK, ignoredBy: "jane"},
+		{name: "by someone else", body: `{"By": "john"}`, status: http.StatusForbidden},
+		{name: "by someone else as an admin", body: `{"By": "john"}`, admin: true, status: http.StatusOK, ignoredBy: "john"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCluster(t, config.RenewalLimits{})
+			ctx := context.Background()
+
+			err := c.ClusterStore.Insert(ctx, store.ClusterRecord{
+				Kind:           "gke-cluster",
+				Name:           "dev",
+				CreateDate:     time.Now().Add(-time.Hour),
+				ExpirationDate: time.Now().Add(time.Hour),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/clusters/ignore/dev", strings.NewReader(test.body))
+			req = mux.SetURLVars(req, map[string]string{"name": "dev"})
+			if test.admin {
+				req = req.WithContext(context.WithValue(req.Context(), adminKey{}, true))
+			} else {
+				req.SetBasicAuth("jane", "secret")
+			}
+			w := httptest.NewRecorder()
+			c.Ignore(w, req)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			record, err := c.ClusterStore.Get(ctx, "gke-cluster", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.IgnoredBy != test.ignoredBy {
+				t.Errorf("expected the cluster to be ignored by %q, got %q", test.ignoredBy, record.IgnoredBy)
+			}
+		})
+	}
+}
+
+func TestIgnoreRequiresAReasonOnceTheRenewalLimitsAreReached(t *testing.T) {
+	tests := []struct {
+		name         string
+		renewalCount int
+		body         string
+		admin        bool
+		status       int
+	}{
+		{name: "below the limits without a reason", renewalCount: 1, body: `{}`, status: http.StatusOK},
+		{name: "at the limits without a reason", renewalCount: 2, body: `{}`, status: http.StatusForbidden},
+		{name: "at the limits with a blank reason", renewalCount: 2, body: `{"Reason": "  "}`, status: http.StatusForbidden},
+		{name: "at the limits with a reason", renewalCount: 2, body: `{"Reason": "release testing"}`, status: http.StatusOK},
+		{name: "at the limits as an admin", renewalCount: 2, body: `{}`, admin: true, status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCluster(t, config.RenewalLimits{MaxRenewals: 2})
+			ctx := context.Background()
+
+			err := c.ClusterStore.Insert(ctx, store.ClusterRecord{
+				Kind:           "gke-cluster",
+				Name:           "dev",
+				CreateDate:     time.Now().Add(-time.Hour),
+				ExpirationDate: time.Now().Add(time.Hour),
+				RenewalCount:   test.renewalCount,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/clusters/ignore/dev", strings.NewReader(test.body))
+			req = mux.SetURLVars(req, map[string]string{"name": "dev"})
+			if test.admin {
+				req = req.WithContext(context.WithValue(req.Context(), adminKey{}, true))
+			}
+			w := httptest.NewRecorder()
+			c.Ignore(w, req)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			record, err := c.ClusterStore.Get(ctx, "gke-cluster", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ignored := test.status == http.StatusOK; record.Ignore != ignored {
+				t.Errorf("expected the cluster to be ignored %t, got %t", ignored, record.Ignore)
+			}
+		})
+	}
+}
+
+func TestRenewBeyondTheRenewalLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		renewalCount int
+		body         string
+		admin        bool
+		status       int
+		message      string
+	}{
+		{name: "within the limits", renewalCount: 1, body: `{}`, status: http.StatusOK, message: "renewal 2"},
+		{name: "beyond the limits", renewalCount: 2, body: `{}`, status: http.StatusForbidden},
+		{name: "beyond the limits with a blank justification", renewalCount: 2, body: `{"Justification": " "}`, status: http.StatusForbidden},
+		{name: "beyond the limits with a justification", renewalCount: 2, body: `{"Justification": "demo on friday"}`, status: http.StatusOK, message: "beyond the renewal limits: demo on friday"},
+		{name: "beyond the limits as an admin", renewalCount: 2, body: `{}`, admin: true, status: http.StatusOK, message: "beyond the renewal limits by an admin"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCluster(t, config.RenewalLimits{MaxRenewals: 2})
+			ctx := context.Background()
+
+			expirationDate := time.Now().Add(time.Minute).Truncate(time.Second)
+			err := c.ClusterStore.Insert(ctx, store.ClusterRecord{
+				Kind:           "gke-cluster",
+				Name:           "dev",
+				CreateDate:     time.Now().Add(-time.Hour),
+				ExpirationDate: expirationDate,
+				RenewalCount:   test.renewalCount,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/clusters/renew/dev", strings.NewReader(test.body))
+			req = mux.SetURLVars(req, map[string]string{"name": "dev"})
+			if test.admin {
+				req = req.WithContext(context.WithValue(req.Context(), adminKey{}, true))
+			}
+			w := httptest.NewRecorder()
+			c.Renew(w, req)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+
+			record, err := c.ClusterStore.Get(ctx, "gke-cluster", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.status != http.StatusOK {
+				if record.RenewalCount != test.renewalCount || !record.ExpirationDate.Equal(expirationDate) {
+					t.Errorf("expected a refused renewal to leave the cluster as it was, got %+v", record)
+				}
+				return
+			}
+			if record.RenewalCount != test.renewalCount+1 || record.RenewalExtension < 59*time.Minute {
+				t.Errorf("expected the renewal to be counted, got %d renewals extending it by %s", record.RenewalCount, record.RenewalExtension)
+			}
+
+			events, err := c.EventStore.ListByCluster(ctx, "gke-cluster", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 || !strings.Contains(events[0].Message, test.message) {
+				t.Errorf("expected a renewed event containing %q, got %+v", test.message, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/leader"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+type Health struct {
+	Log     logr.Logger
+	DB      *store.DB
+	Elector *leader.Elector
+}
+
+func (h *Health) Get(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	response := api.HealthResponse{
+		Status:   "ok",
+		Instance: h.Elector.Identity,
+		IsLeader: h.Elector.IsLeader(),
+	}
+
+	err := h.DB.PingContext(ctx)
+	if err != nil {
+		h.Log.Error(err, "failed to ping database")
+		response.Status = "database unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeJSON(h.Log, w, response)
+		return
+	}
+
+	status, err := h.Elector.Status(ctx)
+	if err != nil {
+		h.Log.Error(err, "failed to get leader status")
+	} else {
+		response.Leader = status.Leader
+		response.LeaderExpiresAt = status.ExpiresAt
+	}
+
+	writeJSON(h.Log, w, response)
+}
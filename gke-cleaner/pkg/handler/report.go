@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/digest"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+// defaultReportRange is how far back a report goes when it is not given a
+// start.
+const defaultReportRange = 30 * 24 * time.Hour
+
+type Report struct {
+	Log           logr.Logger
+	DeletionStore *store.Deletion
+	Currency      string
+
+	DigestBuilder  *digest.Builder
+	DigestRenderer *digest.Renderer
+	DigestFormat   string
+	DigestPeriod   time.Duration
+}
+
+// Savings reports what the deletions between ?from= and ?to= saved. A
+// deletion saves its hourly cost for every hour from the deletion until the
+// end of the range, or until now if that is earlier.
+func (r *Report) Savings(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	from, to, err := reportRange(req, now, defaultReportRange)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deletions, err := r.DeletionStore.ListBetween(req.Context(), from, to)
+	if err != nil {
+		r.Log.Error(err, "failed to list deletions")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	end := to
+	if now.Before(end) {
+		end = now
+	}
+
+	report := api.SavingsReport{
+		From:      from,
+		To:        to,
+		Currency:  r.Currency,
+		Deletions: []api.Saving{},
+	}
+	for _, deletion := range deletions {
+		hours := end.Sub(deletion.DeleteDate).Hours()
+		if hours < 0 {
+			hours = 0
+		}
+
+		saving := api.Saving{
+			ClusterName: deletion.ClusterName,
+			Kind:        deletion.Kind,
+			Owner:       deletion.Owner,
+			HourlyCost:  deletion.HourlyCost,
+			DeleteDate:  deletion.DeleteDate,
+			Hours:       hours,
+			Saved:       deletion.HourlyCost * hours,
+		}
+		report.Saved += saving.Saved
+		report.Deletions = append(report.Deletions, saving)
+	}
+
+	writeJSON(r.Log, w, report)
+}
+
+// Digest renders the digest of ?from= up to ?to= as ?format=, markdown or
+// html. It defaults to the digest that would be sent now.
+func (r *Report) Digest(w http.ResponseWriter, req *http.Request) {
+	from, to, err := reportRange(req, time.Now(), r.DigestPeriod)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = r.DigestFormat
+	}
+	if format != config.DigestFormatMarkdown && format != config.DigestFormatHTML {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q: must be markdown or html", format))
+		return
+	}
+
+	report, err := r.DigestBuilder.Build(req.Context(), from, to)
+	if err != nil {
+		r.Log.Error(err, "failed to build digest")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := r.DigestRenderer.Render(format, report)
+	if err != nil {
+		r.Log.Error(err, "failed to render digest")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", digest.ContentType(format))
+	w.Write(body)
+}
+
+// reportRange reads the ?from= and ?to= times of a report. to defaults to now
+// and from to defaultRange before to.
+func reportRange(req *http.Request, now time.Time, defaultRange time.Duration) (time.Time, time.Time, error) {
+	to := now
+	if value := req.URL.Query().Get("to"); value != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %s", err)
+		}
+	}
+
+	from := to.Add(-defaultRange)
+	if value := req.URL.Query().Get("from"); value != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %s", err)
+		}
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/gorilla/mux"
+)
+
+func NewRouter(clusterHandler *Cluster, adminHandler *Admin, reportHandler *Report, healthHandler *Health, basicAuthHandler *BasicAuth) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc(api.HealthPath, healthHandler.Get).Methods("GET")
+
+	authenticated := router.PathPrefix("/").Subrouter()
+	authenticated.HandleFunc(api.ClustersPath, clusterHandler.List)
+	authenticated.HandleFunc(api.ClustersExportPath, clusterHandler.Export).Methods("GET")
+	authenticated.HandleFunc(api.ClustersImportPath, requireAdmin(adminHandler.Import)).Methods("POST")
+	authenticated.HandleFunc(api.SyncPath, clusterHandler.Sync).Methods("POST")
+	authenticated.HandleFunc(api.ClusterHistoryPath, clusterHandler.History).Methods("GET")
+	authenticated.HandleFunc(api.ClusterPath, clusterHandler.Get).Methods("GET")
+	authenticated.HandleFunc(api.ClusterRenewPath, clusterHandler.Renew).Methods("POST")
+	authenticated.HandleFunc(api.ClusterIgnorePath, clusterHandler.Ignore).Methods("POST")
+	authenticated.HandleFunc(api.ClusterUnignorePath, clusterHandler.Unignore).Methods("POST")
+	authenticated.HandleFunc(api.ClusterRestorePath, clusterHandler.Restore).Methods("POST")
+	authenticated.HandleFunc(api.ClusterActionPath, clusterHandler.SetAction).Methods("POST")
+	authenticated.HandleFunc(api.AdminReloadPath, requireAdmin(adminHandler.Reload)).Methods("POST")
+	authenticated.HandleFunc(api.AdminBreakerPath, adminHandler.Breaker).Methods("GET")
+	authenticated.HandleFunc(api.AdminBreakerArmPath, requireAdmin(adminHandler.Arm)).Methods("POST")
+	authenticated.HandleFunc(api.ReportsSavingsPath, reportHandler.Savings).Methods("GET")
+	authenticated.HandleFunc(api.ReportsDigestPath, reportHandler.Digest).Methods("GET")
+	authenticated.Use(basicAuthHandler.Handle)
+
+	return router
+}
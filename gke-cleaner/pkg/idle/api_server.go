@@ -0,0 +1,43 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	logging "google.golang.org/api/logging/v2"
+)
+
+// APIServer looks for requests to the cluster's Kubernetes API in the
+// project's admin activity audit log. Requests made by Kubernetes itself, by
+// system: users, do not count. It needs the roles/logging.viewer role.
+type APIServer struct {
+	Service *logging.Service
+}
+
+func (a *APIServer) Name() string {
+	return config.IdleSignalAPIServer
+}
+
+func (a *APIServer) Active(ctx context.Context, cluster Cluster, since time.Time) (bool, error) {
+	filter := fmt.Sprintf(`logName="projects/%s/logs/cloudaudit.googleapis.com%%2Factivity"`+
+		` AND resource.type="k8s_cluster"`+
+		` AND resource.labels.location="%s"`+
+		` AND resource.labels.cluster_name="%s"`+
+		` AND NOT protoPayload.authenticationInfo.principalEmail:"system:"`+
+		` AND timestamp>="%s"`,
+		cluster.Project, cluster.Location, cluster.Name, since.UTC().Format(time.RFC3339))
+
+	response, err := a.Service.Entries.List(&logging.ListLogEntriesRequest{
+		ResourceNames: []string{fmt.Sprintf("projects/%s", cluster.Project)},
+		Filter:        filter,
+		OrderBy:       "timestamp desc",
+		PageSize:      1,
+	}).Context(ctx).Do()
+	if err != nil {
+		return false, err
+	}
+
+	return len(response.Entries) > 0, nil
+}
@@ -0,0 +1,54 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// CPU reads the cpu utilization of the cluster's nodes from Cloud Monitoring.
+// A cluster is active if any node used more than Threshold of its allocatable
+// cpu in a five minute window. It needs the roles/monitoring.viewer role.
+type CPU struct {
+	Service   *monitoring.Service
+	Threshold float64
+}
+
+func (c *CPU) Name() string {
+	return config.IdleSignalCPU
+}
+
+func (c *CPU) Active(ctx context.Context, cluster Cluster, since time.Time) (bool, error) {
+	filter := fmt.Sprintf(`metric.type="kubernetes.io/node/cpu/allocatable_utilization"`+
+		` AND resource.type="k8s_node"`+
+		` AND resource.labels.project_id="%s"`+
+		` AND resource.labels.location="%s"`+
+		` AND resource.labels.cluster_name="%s"`,
+		cluster.Project, cluster.Location, cluster.Name)
+
+	active := false
+	err := c.Service.Projects.TimeSeries.List(fmt.Sprintf("projects/%s", cluster.Project)).
+		Filter(filter).
+		IntervalStartTime(since.UTC().Format(time.RFC3339)).
+		IntervalEndTime(time.Now().UTC().Format(time.RFC3339)).
+		AggregationAlignmentPeriod("300s").
+		AggregationPerSeriesAligner("ALIGN_MAX").
+		Pages(ctx, func(response *monitoring.ListTimeSeriesResponse) error {
+			for _, series := range response.TimeSeries {
+				for _, point := range series.Points {
+					if point.Value != nil && point.Value.DoubleValue != nil && *point.Value.DoubleValue > c.Threshold {
+						active = true
+					}
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
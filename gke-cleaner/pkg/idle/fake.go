@@ -0,0 +1,54 @@
+package idle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FakeSignal is an in-memory Signal. The clusters named in ActiveClusters are
+// active, every other cluster is idle. Err is returned by every call when
+// set.
+type FakeSignal struct {
+	SignalName     string
+	ActiveClusters map[string]bool
+	Err            error
+
+	mutex sync.Mutex
+	calls []Cluster
+}
+
+func (f *FakeSignal) Name() string {
+	return f.SignalName
+}
+
+func (f *FakeSignal) Active(ctx context.Context, cluster Cluster, since time.Time) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, cluster)
+	if f.Err != nil {
+		return false, f.Err
+	}
+
+	return f.ActiveClusters[cluster.Name], nil
+}
+
+// SetActive marks a cluster as active or idle.
+func (f *FakeSignal) SetActive(name string, active bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.ActiveClusters == nil {
+		f.ActiveClusters = map[string]bool{}
+	}
+	f.ActiveClusters[name] = active
+}
+
+// Calls returns every cluster the signal was read for so far.
+func (f *FakeSignal) Calls() []Cluster {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Cluster{}, f.calls...)
+}
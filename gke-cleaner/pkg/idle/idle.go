@@ -0,0 +1,99 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"golang.org/x/oauth2/google"
+
+	logging "google.golang.org/api/logging/v2"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// Cluster is what the signals need to know about a cluster to tell whether
+// it is in use.
+type Cluster struct {
+	Project    string
+	Location   string
+	Name       string
+	CreateTime time.Time
+
+	// Endpoint and CACertificate, base64 encoded, reach the cluster's
+	// Kubernetes API.
+	Endpoint      string
+	CACertificate string
+}
+
+// Signal tells whether a cluster was in use since a given time.
+type Signal interface {
+	Name() string
+	Active(ctx context.Context, cluster Cluster, since time.Time) (bool, error)
+}
+
+// Detector finds clusters that were not in use for Period according to every
+// signal. Lifetime is how long an idle cluster is kept before it expires.
+type Detector struct {
+	Signals  []Signal
+	Period   time.Duration
+	Lifetime time.Duration
+}
+
+// NewDetector builds a detector with a signal for each configured signal
+// name, authenticated with the application default credentials.
+func NewDetector(ctx context.Context, cfg config.IdleDetection) (*Detector, error) {
+	detector := &Detector{
+		Period:   cfg.Period,
+		Lifetime: cfg.Lifetime,
+	}
+
+	for _, name := range cfg.Signals {
+		switch name {
+		case config.IdleSignalCPU:
+			service, err := monitoring.NewService(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create monitoring client: %s", err)
+			}
+			detector.Signals = append(detector.Signals, &CPU{Service: service, Threshold: cfg.CPUThreshold})
+		case config.IdleSignalAPIServer:
+			service, err := logging.NewService(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create logging client: %s", err)
+			}
+			detector.Signals = append(detector.Signals, &APIServer{Service: service})
+		case config.IdleSignalWorkloads:
+			tokenSource, err := google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")
+			if err != nil {
+				return nil, fmt.Errorf("failed to find default credentials: %s", err)
+			}
+			detector.Signals = append(detector.Signals, &Workloads{TokenSource: tokenSource})
+		default:
+			return nil, fmt.Errorf("unknown idle signal: %s", name)
+		}
+	}
+
+	return detector, nil
+}
+
+// Idle reports whether the cluster was idle for the whole period, or else the
+// first signal that saw it in use. A cluster younger than the period is never
+// idle.
+func (d *Detector) Idle(ctx context.Context, cluster Cluster, now time.Time) (bool, string, error) {
+	since := now.Add(-d.Period)
+	if cluster.CreateTime.After(since) {
+		return false, "", nil
+	}
+
+	for _, signal := range d.Signals {
+		active, err := signal.Active(ctx, cluster, since)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to read the %s signal of %s: %s", signal.Name(), cluster.Name, err)
+		}
+		if active {
+			return false, signal.Name(), nil
+		}
+	}
+
+	return true, "", nil
+}
@@ -0,0 +1,120 @@
+package idle
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"golang.org/x/oauth2"
+)
+
+// systemNamespaces run the pods every GKE cluster has.
+var systemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease", "gke-*", "gmp-*"}
+
+// Workloads lists the pods of the cluster through its Kubernetes API. A
+// cluster is active while it runs a pod outside of the system namespaces.
+// Only the current pods are seen, since is not used. The token source's
+// account needs the roles/container.viewer role.
+type Workloads struct {
+	TokenSource oauth2.TokenSource
+}
+
+func (w *Workloads) Name() string {
+	return config.IdleSignalWorkloads
+}
+
+func (w *Workloads) Active(ctx context.Context, cluster Cluster, since time.Time) (bool, error) {
+	httpClient, err := w.httpClient(cluster)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/api/v1/pods", cluster.Endpoint), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("kubernetes api returned status %d", resp.StatusCode)
+	}
+
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&pods)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode pods: %s", err)
+	}
+
+	for _, pod := range pods.Items {
+		if isSystemNamespace(pod.Metadata.Namespace) {
+			continue
+		}
+		if pod.Status.Phase == "Running" || pod.Status.Phase == "Pending" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (w *Workloads) httpClient(cluster Cluster) (*http.Client, error) {
+	if cluster.Endpoint == "" {
+		return nil, fmt.Errorf("cluster has no endpoint")
+	}
+
+	ca, err := base64.StdEncoding.DecodeString(cluster.CACertificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cluster ca certificate: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("cluster ca certificate is not valid")
+	}
+
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: w.TokenSource,
+			Base: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+func isSystemNamespace(namespace string) bool {
+	for _, system := range systemNamespaces {
+		if strings.HasSuffix(system, "*") && strings.HasPrefix(namespace, strings.TrimSuffix(system, "*")) {
+			return true
+		}
+		if namespace == system {
+			return true
+		}
+	}
+
+	return false
+}
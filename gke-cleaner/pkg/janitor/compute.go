@@ -0,0 +1,254 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/nodepool"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+const operationTimeout = 10 * time.Minute
+
+// Compute finds and deletes leftover resources with the Compute Engine API.
+type Compute struct {
+	Service *compute.Service
+}
+
+// Tags reads the network tags from the instance templates of the cluster's
+// instance groups. Only the tag GKE gives the cluster's nodes is kept, other
+// tags set in the node config can be shared with other instances.
+func (c *Compute) Tags(ctx context.Context, target Target) ([]string, error) {
+	pattern := nodeTagPattern(target.ClusterName)
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, url := range target.InstanceGroups {
+		instanceGroup, err := nodepool.ParseInstanceGroupURL(url)
+		if err != nil {
+			return nil, err
+		}
+
+		manager, err := c.Service.InstanceGroupManagers.Get(instanceGroup.Project, instanceGroup.Zone, instanceGroup.Name).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instance group %s: %s", instanceGroup.Name, err)
+		}
+
+		template, err := c.Service.InstanceTemplates.Get(instanceGroup.Project, lastSegment(manager.InstanceTemplate)).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instance template %s: %s", lastSegment(manager.InstanceTemplate), err)
+		}
+
+		if template.Properties == nil || template.Properties.Tags == nil {
+			continue
+		}
+
+		for _, tag := range template.Properties.Tags.Items {
+			if !seen[tag] && pattern.MatchString(tag) {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func (c *Compute) Find(ctx context.Context, target Target) ([]Resource, error) {
+	region := Region(target.Location)
+
+	targetPools := []Resource{}
+	targetPoolLinks := map[string]bool{}
+	err := c.Service.TargetPools.List(target.Project, region).Pages(ctx, func(list *compute.TargetPoolList) error {
+		for _, targetPool := range list.Items {
+			for _, instance := range targetPool.Instances {
+				if hasInstancePrefix(instance, target.InstancePrefixes) {
+					targetPoolLinks[targetPool.SelfLink] = true
+					targetPools = append(targetPools, Resource{
+						Kind:     KindTargetPool,
+						Name:     targetPool.Name,
+						Project:  target.Project,
+						Region:   region,
+						SelfLink: targetPool.SelfLink,
+						Reason:   fmt.Sprintf("targets node %s", lastSegment(instance)),
+					})
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target pools: %s", err)
+	}
+
+	forwardingRules := []Resource{}
+	forwardingRuleLinks := map[string]bool{}
+	err = c.Service.ForwardingRules.List(target.Project, region).Pages(ctx, func(list *compute.ForwardingRuleList) error {
+		for _, forwardingRule := range list.Items {
+			if targetPoolLinks[forwardingRule.Target] {
+				forwardingRuleLinks[forwardingRule.SelfLink] = true
+				forwardingRules = append(forwardingRules, Resource{
+					Kind:     KindForwardingRule,
+					Name:     forwardingRule.Name,
+					Project:  target.Project,
+					Region:   region,
+					SelfLink: forwardingRule.SelfLink,
+					Reason:   fmt.Sprintf("forwards to target pool %s", lastSegment(forwardingRule.Target)),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list forwarding rules: %s", err)
+	}
+
+	addresses := []Resource{}
+	err = c.Service.Addresses.List(target.Project, region).Pages(ctx, func(list *compute.AddressList) error {
+		for _, address := range list.Items {
+			for _, user := range address.Users {
+				if forwardingRuleLinks[user] {
+					addresses = append(addresses, Resource{
+						Kind:     KindAddress,
+						Name:     address.Name,
+						Project:  target.Project,
+						Region:   region,
+						SelfLink: address.SelfLink,
+						Reason:   fmt.Sprintf("used by forwarding rule %s", lastSegment(user)),
+					})
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses: %s", err)
+	}
+
+	firewalls := []Resource{}
+	namePattern := firewallPattern(target.ClusterName, target.NetworkTags)
+	err = c.Service.Firewalls.List(target.Project).Pages(ctx, func(list *compute.FirewallList) error {
+		for _, firewall := range list.Items {
+			if target.Network != "" && lastSegment(firewall.Network) != target.Network {
+				continue
+			}
+
+			reason := ""
+			if tag, found := containsAny(firewall.TargetTags, target.NetworkTags); found {
+				reason = fmt.Sprintf("targets network tag %s", tag)
+			} else if namePattern != nil && namePattern.MatchString(firewall.Name) {
+				reason = "named after the cluster"
+			} else {
+				continue
+			}
+
+			firewalls = append(firewalls, Resource{
+				Kind:     KindFirewall,
+				Name:     firewall.Name,
+				Project:  target.Project,
+				SelfLink: firewall.SelfLink,
+				Reason:   reason,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list firewall rules: %s", err)
+	}
+
+	disks := []Resource{}
+	diskPattern := clusterResourcePattern(target.ClusterName, "(dynamic-)?pvc-")
+	zones := map[string]bool{}
+	for _, zone := range diskZones(target) {
+		zones[zone] = true
+	}
+	err = c.Service.Disks.AggregatedList(target.Project).Pages(ctx, func(list *compute.DiskAggregatedList) error {
+		for scope, scopedList := range list.Items {
+			zone := strings.TrimPrefix(scope, "zones/")
+			if !zones[zone] {
+				continue
+			}
+
+			for _, disk := range scopedList.Disks {
+				if len(disk.Users) == 0 && diskPattern.MatchString(disk.Name) {
+					disks = append(disks, Resource{
+						Kind:     KindDisk,
+						Name:     disk.Name,
+						Project:  target.Project,
+						Zone:     zone,
+						SelfLink: disk.SelfLink,
+						Reason:   "unattached persistent volume disk named after the cluster",
+					})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disks: %s", err)
+	}
+
+	resources := append(forwardingRules, targetPools...)
+	resources = append(resources, addresses...)
+	resources = append(resources, firewalls...)
+	resources = append(resources, disks...)
+
+	return resources, nil
+}
+
+func (c *Compute) Delete(ctx context.Context, resource Resource) error {
+	var op *compute.Operation
+	var err error
+
+	switch resource.Kind {
+	case KindForwardingRule:
+		op, err = c.Service.ForwardingRules.Delete(resource.Project, resource.Region, resource.Name).Context(ctx).Do()
+	case KindTargetPool:
+		op, err = c.Service.TargetPools.Delete(resource.Project, resource.Region, resource.Name).Context(ctx).Do()
+	case KindAddress:
+		op, err = c.Service.Addresses.Delete(resource.Project, resource.Region, resource.Name).Context(ctx).Do()
+	case KindFirewall:
+		op, err = c.Service.Firewalls.Delete(resource.Project, resource.Name).Context(ctx).Do()
+	case KindDisk:
+		op, err = c.Service.Disks.Delete(resource.Project, resource.Zone, resource.Name).Context(ctx).Do()
+	default:
+		return fmt.Errorf("unknown resource kind %q", resource.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %s", resource, err)
+	}
+
+	return c.wait(ctx, resource, op)
+}
+
+func (c *Compute) wait(ctx context.Context, resource Resource, op *compute.Operation) error {
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	name := op.Name
+	var err error
+	for op.Status != "DONE" {
+		switch {
+		case resource.Zone != "":
+			op, err = c.Service.ZoneOperations.Wait(resource.Project, resource.Zone, name).Context(ctx).Do()
+		case resource.Region != "":
+			op, err = c.Service.RegionOperations.Wait(resource.Project, resource.Region, name).Context(ctx).Do()
+		default:
+			op, err = c.Service.GlobalOperations.Wait(resource.Project, name).Context(ctx).Do()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to wait for operation %s: %s", name, err)
+		}
+	}
+
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("operation %s failed: %s", name, op.Error.Errors[0].Message)
+	}
+
+	return nil
+}
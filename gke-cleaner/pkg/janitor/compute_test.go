@@ -0,0 +1,122 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// newTestCompute serves the given API responses by path, e.g.
+// /project/global/firewalls.
+func newTestCompute(t *testing.T, responses map[string]interface{}) *Compute {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		response, ok := responses[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := compute.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = server.URL + "/"
+
+	return &Compute{Service: service}
+}
+
+func resourceNames(resources []Resource) []string {
+	names := []string{}
+	for _, resource := range resources {
+		names = append(names, resource.Kind+" "+resource.Name)
+	}
+
+	return names
+}
+
+// A zonal cluster pr is deleted while a regional cluster pr lives on in the
+// same project, with nodes in the other zones of the region.
+func TestComputeLeavesLiveClusterOfTheSameNameAlone(t *testing.T) {
+	c := newTestCompute(t, map[string]interface{}{
+		"/project/zones/us-central1-a/instanceGroupManagers/gke-pr-default-pool-1a2b3c4d-grp": compute.InstanceGroupManager{
+			InstanceTemplate: "https://www.googleapis.com/compute/v1/projects/project/global/instanceTemplates/gke-pr-default-pool-1a2b3c4d",
+		},
+		"/project/global/instanceTemplates/gke-pr-default-pool-1a2b3c4d": compute.InstanceTemplate{
+			Properties: &compute.InstanceProperties{
+				Tags: &compute.Tags{Items: []string{"gke-pr-1a2b3c4d-node", "http-server"}},
+			},
+		},
+		"/project/regions/us-central1/targetPools":     compute.TargetPoolList{},
+		"/project/regions/us-central1/forwardingRules": compute.ForwardingRuleList{},
+		"/project/regions/us-central1/addresses":       compute.AddressList{},
+		"/project/global/firewalls": compute.FirewallList{
+			Items: []*compute.Firewall{
+				{Name: "gke-pr-1a2b3c4d-all", TargetTags: []string{"gke-pr-1a2b3c4d-node"}},
+				{Name: "gke-pr-5e6f7a8b-all", TargetTags: []string{"gke-pr-5e6f7a8b-node"}},
+				{Name: "allow-http", TargetTags: []string{"http-server"}},
+			},
+		},
+		"/project/aggregated/disks": compute.DiskAggregatedList{
+			Items: map[string]compute.DisksScopedList{
+				"zones/us-central1-a": {Disks: []*compute.Disk{{Name: "gke-pr-1a2b-pvc-1"}}},
+				"zones/us-central1-b": {Disks: []*compute.Disk{{Name: "gke-pr-5e6f-pvc-2"}}},
+			},
+		},
+	})
+
+	ctx := context.Background()
+	target := Target{
+		ClusterName:    "pr",
+		Project:        "project",
+		Location:       "us-central1-a",
+		Zones:          []string{"us-central1-a"},
+		InstanceGroups: []string{"https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instanceGroupManagers/gke-pr-default-pool-1a2b3c4d-grp"},
+	}
+
+	tags, err := c.Tags(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"gke-pr-1a2b3c4d-node"}) {
+		t.Errorf("expected only the node tag of the deleted cluster, got %v", tags)
+	}
+
+	target.NetworkTags = tags
+	resources, err := c.Find(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"firewall gke-pr-1a2b3c4d-all", "disk gke-pr-1a2b-pvc-1"}
+	if names := resourceNames(resources); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestDiskZones(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		zones  []string
+	}{
+		{name: "recorded zones", target: Target{Location: "us-central1", Zones: []string{"us-central1-a", "us-central1-b"}}, zones: []string{"us-central1-a", "us-central1-b"}},
+		{name: "zonal cluster without zones", target: Target{Location: "us-central1-a"}, zones: []string{"us-central1-a"}},
+		{name: "regional cluster without zones", target: Target{Location: "us-central1"}, zones: []string{}},
+	}
+
+	for _, test := range tests {
+		if zones := diskZones(test.target); !reflect.DeepEqual(zones, test.zones) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.zones, zones)
+		}
+	}
+}
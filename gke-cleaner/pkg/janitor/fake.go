@@ -0,0 +1,64 @@
+package janitor
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is an in-memory Janitor. NodeTags and Resources are keyed by cluster
+// name and deleted resources are no longer found.
+type Fake struct {
+	NodeTags  map[string][]string
+	Resources map[string][]Resource
+
+	mutex   sync.Mutex
+	deleted []Resource
+}
+
+func (f *Fake) Tags(ctx context.Context, target Target) ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.NodeTags[target.ClusterName], nil
+}
+
+func (f *Fake) Find(ctx context.Context, target Target) ([]Resource, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	resources := []Resource{}
+	for _, resource := range f.Resources[target.ClusterName] {
+		if !f.isDeleted(resource) {
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources, nil
+}
+
+func (f *Fake) Delete(ctx context.Context, resource Resource) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.deleted = append(f.deleted, resource)
+
+	return nil
+}
+
+// Deleted returns every resource deleted so far.
+func (f *Fake) Deleted() []Resource {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Resource{}, f.deleted...)
+}
+
+func (f *Fake) isDeleted(resource Resource) bool {
+	for _, deleted := range f.deleted {
+		if deleted == resource {
+			return true
+		}
+	}
+
+	return false
+}
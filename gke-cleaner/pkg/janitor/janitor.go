@@ -0,0 +1,151 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	KindForwardingRule = "forwarding-rule"
+	KindTargetPool     = "target-pool"
+	KindAddress        = "address"
+	KindFirewall       = "firewall"
+	KindDisk           = "disk"
+)
+
+// Target identifies a cluster's compute resources after the cluster itself is
+// gone. It is recorded before the cluster is deleted.
+type Target struct {
+	ClusterName string
+	Project     string
+	Location    string
+	Network     string
+
+	// NetworkTags are the tags GKE gives the cluster's nodes, which its
+	// firewall rules and load balancers target.
+	NetworkTags []string
+
+	// InstancePrefixes are the names of the node pools' instance groups, every
+	// node's name starts with one of them.
+	InstancePrefixes []string
+
+	// Zones are the zones of the cluster's nodes, its persistent disks are
+	// only looked for there.
+	Zones []string
+
+	// InstanceGroups are the URLs of the node pools' instance groups, the
+	// network tags are read from their instance templates. They are only
+	// needed by Tags.
+	InstanceGroups []string
+}
+
+type Resource struct {
+	Kind     string
+	Name     string
+	Project  string
+	Region   string
+	Zone     string
+	SelfLink string
+	Reason   string
+}
+
+func (r Resource) String() string {
+	return fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.Reason)
+}
+
+type Janitor interface {
+	// Tags returns the network tags GKE gives the cluster's nodes. It is
+	// called while the cluster still exists.
+	Tags(ctx context.Context, target Target) ([]string, error)
+
+	// Find returns the resources left behind by the deleted cluster, in the
+	// order they can be deleted.
+	Find(ctx context.Context, target Target) ([]Resource, error)
+
+	Delete(ctx context.Context, resource Resource) error
+}
+
+// Region returns the region of a zonal or regional location.
+func Region(location string) string {
+	if strings.Count(location, "-") == 2 {
+		return location[:strings.LastIndex(location, "-")]
+	}
+
+	return location
+}
+
+// clusterResourcePattern matches the names GKE gives a cluster's persistent
+// disks: gke-<cluster>-<hash>-<suffix>. The suffix keeps a cluster named foo
+// from matching the disks of foo-1234.
+func clusterResourcePattern(clusterName string, suffix string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("^gke-%s-[0-9a-f]{4,8}-%s", regexp.QuoteMeta(clusterName), suffix))
+}
+
+// diskZones returns the zones to look for the cluster's disks in. Sweeps
+// recorded without zones fall back to a zonal cluster's location, the disks of
+// a regional cluster could be those of another cluster in the region.
+func diskZones(target Target) []string {
+	if len(target.Zones) > 0 {
+		return target.Zones
+	}
+
+	if Region(target.Location) != target.Location {
+		return []string{target.Location}
+	}
+
+	return []string{}
+}
+
+func nodeTagPattern(clusterName string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("^gke-%s-([0-9a-f]{8})-node$", regexp.QuoteMeta(clusterName)))
+}
+
+// firewallPattern matches the names GKE gives a cluster's firewall rules,
+// gke-<cluster>-<hash>-..., with the hash of the cluster's node tags. Any hash
+// would also match the rules of a live cluster named e.g. <cluster>-1234, so
+// without node tags it is nil and rules are only found by their target tags.
+func firewallPattern(clusterName string, networkTags []string) *regexp.Regexp {
+	tagPattern := nodeTagPattern(clusterName)
+
+	hashes := []string{}
+	for _, tag := range networkTags {
+		if match := tagPattern.FindStringSubmatch(tag); match != nil {
+			hashes = append(hashes, match[1])
+		}
+	}
+
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	return regexp.MustCompile(fmt.Sprintf("^gke-%s-(%s)-", regexp.QuoteMeta(clusterName), strings.Join(hashes, "|")))
+}
+
+func hasInstancePrefix(instance string, prefixes []string) bool {
+	name := instance[strings.LastIndex(instance, "/")+1:]
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(values []string, wanted []string) (string, bool) {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return value, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func lastSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
@@ -0,0 +1,31 @@
+package janitor
+
+import "testing"
+
+func TestFirewallPattern(t *testing.T) {
+	tags := []string{"gke-pr-1a2b3c4d-node"}
+
+	tests := []struct {
+		name     string
+		firewall string
+		matches  bool
+	}{
+		{name: "rule of the cluster", firewall: "gke-pr-1a2b3c4d-all", matches: true},
+		{name: "rule of another cluster with the same prefix", firewall: "gke-pr-1234-5e6f7a8b-all", matches: false},
+		{name: "rule of another cluster with a hash-like suffix", firewall: "gke-pr-abcd1234-all", matches: false},
+		{name: "unrelated rule", firewall: "default-allow-ssh", matches: false},
+	}
+
+	pattern := firewallPattern("pr", tags)
+	for _, test := range tests {
+		if got := pattern.MatchString(test.firewall); got != test.matches {
+			t.Errorf("%s: matching %q = %t, want %t", test.name, test.firewall, got, test.matches)
+		}
+	}
+}
+
+func TestFirewallPatternWithoutNodeTags(t *testing.T) {
+	if pattern := firewallPattern("pr", []string{"gke-pr-1234-5e6f7a8b-node", "http-server"}); pattern != nil {
+		t.Errorf("expected no pattern without node tags of the cluster, got %s", pattern)
+	}
+}
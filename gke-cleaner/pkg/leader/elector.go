@@ -0,0 +1,117 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+const LeaseName = "gke-poller"
+
+type Elector struct {
+	Log        logr.Logger
+	LeaseStore *store.Lease
+	EventStore *store.Event
+
+	Identity      string
+	LeaseDuration time.Duration
+
+	mutex    sync.RWMutex
+	isLeader bool
+}
+
+type Status struct {
+	Identity  string
+	IsLeader  bool
+	Leader    string
+	ExpiresAt time.Time
+}
+
+func (e *Elector) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	e.tryAcquire()
+	close(ready)
+
+	ticker := time.NewTicker(e.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			e.setLeader(false)
+			err := e.LeaseStore.Release(context.Background(), LeaseName, e.Identity)
+			if err != nil {
+				e.Log.Error(err, "Failed to release lease")
+			}
+			return nil
+		case <-ticker.C:
+			e.tryAcquire()
+		}
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.isLeader
+}
+
+func (e *Elector) Status(ctx context.Context) (Status, error) {
+	lease, err := e.LeaseStore.Get(ctx, LeaseName)
+	if err != nil {
+		return Status{}, err
+	}
+
+	leader := lease.Holder
+	if lease.ExpiresAt.IsZero() || lease.ExpiresAt.Before(time.Now()) {
+		leader = ""
+	}
+
+	return Status{
+		Identity:  e.Identity,
+		IsLeader:  e.IsLeader(),
+		Leader:    leader,
+		ExpiresAt: lease.ExpiresAt,
+	}, nil
+}
+
+func (e *Elector) tryAcquire() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.LeaseDuration/3)
+	defer cancel()
+
+	acquired, err := e.LeaseStore.Acquire(ctx, LeaseName, e.Identity, e.LeaseDuration)
+	if err != nil {
+		e.Log.Error(err, "Failed to acquire lease")
+		acquired = false
+	}
+
+	wasLeader := e.IsLeader()
+	e.setLeader(acquired)
+
+	switch {
+	case acquired && !wasLeader:
+		e.Log.Info("Became leader", "identity", e.Identity)
+		e.recordEvent(ctx, store.EventLeaderElected, fmt.Sprintf("%s became leader", e.Identity))
+	case !acquired && wasLeader:
+		e.Log.Info("Lost leadership", "identity", e.Identity)
+	}
+}
+
+func (e *Elector) setLeader(isLeader bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.isLeader = isLeader
+}
+
+func (e *Elector) recordEvent(ctx context.Context, eventType string, message string) {
+	err := e.EventStore.Insert(ctx, "", "", eventType, message)
+	if err != nil {
+		e.Log.Error(err, "Failed to record event", "type", eventType)
+	}
+}
@@ -0,0 +1,133 @@
+package leader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"github.com/tedsuo/ifrit"
+	"go.uber.org/zap"
+)
+
+var testLog = zapr.NewLogger(zap.NewNop())
+
+func newTestDB(t *testing.T) *store.DB {
+	dir, err := ioutil.TempDir("", "gke-cleaner-leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = (&migrate.DB{Log: testLog, DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func newTestElector(db *store.DB, identity string) *Elector {
+	return &Elector{
+		Log:           testLog,
+		LeaseStore:    &store.Lease{DB: db},
+		EventStore:    &store.Event{DB: db},
+		Identity:      identity,
+		LeaseDuration: time.Minute,
+	}
+}
+
+func TestElectorTakesTurns(t *testing.T) {
+	db := newTestDB(t)
+	first := newTestElector(db, "first")
+	second := newTestElector(db, "second")
+
+	first.tryAcquire()
+	second.tryAcquire()
+	if !first.IsLeader() || second.IsLeader() {
+		t.Fatalf("expected only the first instance to lead, got first=%t second=%t", first.IsLeader(), second.IsLeader())
+	}
+
+	first.tryAcquire()
+	if !first.IsLeader() {
+		t.Errorf("expected the leader to renew its lease")
+	}
+
+	status, err := second.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Identity != "second" || status.IsLeader || status.Leader != "first" || status.ExpiresAt.IsZero() {
+		t.Errorf("expected the second instance to report first as leader, got %+v", status)
+	}
+
+	events, err := (&store.Event{DB: db}).ListByCluster(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != store.EventLeaderElected || events[0].Message != "first became leader" {
+		t.Errorf("expected one leader-elected event, got %+v", events)
+	}
+}
+
+func TestElectorTakesOverExpiredLease(t *testing.T) {
+	db := newTestDB(t)
+	first := newTestElector(db, "first")
+	first.LeaseDuration = -time.Second
+	second := newTestElector(db, "second")
+
+	first.tryAcquire()
+	second.tryAcquire()
+	if !second.IsLeader() {
+		t.Fatalf("expected the second instance to take over the expired lease")
+	}
+
+	first.tryAcquire()
+	if first.IsLeader() {
+		t.Errorf("expected the first instance to lose leadership")
+	}
+}
+
+func TestElectorReleasesLeaseOnStop(t *testing.T) {
+	db := newTestDB(t)
+	first := newTestElector(db, "first")
+	second := newTestElector(db, "second")
+
+	process := ifrit.Invoke(first)
+	if !first.IsLeader() {
+		t.Fatalf("expected the first instance to lead once ready")
+	}
+
+	process.Signal(os.Interrupt)
+	err := <-process.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.IsLeader() {
+		t.Errorf("expected a stopped instance to stop leading")
+	}
+
+	status, err := second.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Leader != "" {
+		t.Errorf("expected no leader after the lease is released, got %q", status.Leader)
+	}
+
+	second.tryAcquire()
+	if !second.IsLeader() {
+		t.Errorf("expected the second instance to take the released lease straight away")
+	}
+}
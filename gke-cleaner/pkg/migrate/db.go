@@ -1,30 +1,142 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/christianang/gke-cleaner/pkg/store"
 	"github.com/go-logr/logr"
 )
 
+const (
+	// migrationLockName and migrationLockID name the migration lock in MySQL
+	// and postgres.
+	migrationLockName = "gke-cleaner-migrations"
+	migrationLockID   = 7036521839
+
+	migrationLockTimeout = 10 * time.Minute
+)
+
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS Clusters (
+		ID {{id}},
+		Name TEXT NOT NULL,
+		CreateDate {{datetime}},
+		ExpirationDate {{datetime}},
+		IgnoreMe BOOLEAN
+	)`,
+	`ALTER TABLE Clusters ADD COLUMN IgnoreUntil {{datetime}}`,
+	`ALTER TABLE Clusters ADD COLUMN IgnoreReason TEXT`,
+	`CREATE TABLE IF NOT EXISTS Events (
+		ID {{id}},
+		ClusterName TEXT NOT NULL,
+		Type VARCHAR(64) NOT NULL,
+		Message TEXT,
+		CreateDate {{datetime}}
+	)`,
+	`CREATE TABLE IF NOT EXISTS Leases (
+		Name VARCHAR(64) NOT NULL PRIMARY KEY,
+		Holder VARCHAR(255) NOT NULL,
+		ExpiresAt {{datetime}}
+	)`,
+	`INSERT INTO Leases (Name, Holder, ExpiresAt) VALUES ('gke-poller', '', NULL)`,
+	`ALTER TABLE Clusters ADD COLUMN Status VARCHAR(32)`,
+	`CREATE TABLE IF NOT EXISTS Breakers (
+		Name VARCHAR(64) NOT NULL PRIMARY KEY,
+		Tripped BOOLEAN NOT NULL,
+		Reason TEXT,
+		UpdatedAt {{datetime}},
+		ArmedAt {{datetime}}
+	)`,
+	`INSERT INTO Breakers (Name, Tripped, Reason, UpdatedAt) VALUES ('deletions', FALSE, '', NULL)`,
+	`ALTER TABLE Clusters ADD COLUMN Labels TEXT`,
+	`ALTER TABLE Clusters ADD COLUMN SelfLink TEXT`,
+	`ALTER TABLE Clusters ADD COLUMN ExpiryAction VARCHAR(32)`,
+	`ALTER TABLE Clusters ADD COLUMN ActionTaken VARCHAR(32)`,
+	`CREATE TABLE IF NOT EXISTS NodePools (
+		ID {{id}},
+		ClusterName VARCHAR(255) NOT NULL,
+		Name VARCHAR(255) NOT NULL,
+		NodeCount INT NOT NULL,
+		Autoscaling BOOLEAN NOT NULL,
+		MinNodeCount INT NOT NULL,
+		MaxNodeCount INT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS Sweeps (
+		ID {{id}},
+		ClusterName VARCHAR(255) NOT NULL,
+		Project VARCHAR(255) NOT NULL,
+		Location VARCHAR(64) NOT NULL,
+		Network VARCHAR(255),
+		NetworkTags TEXT,
+		InstancePrefixes TEXT,
+		Operation VARCHAR(255),
+		CreateDate {{datetime}}
+	)`,
+	`ALTER TABLE Clusters ADD COLUMN Kind VARCHAR(64)`,
+	`UPDATE Clusters SET Kind = 'gke-cluster'`,
+	`ALTER TABLE Events ADD COLUMN Kind VARCHAR(64)`,
+	`UPDATE Events SET Kind = '' WHERE ClusterName = ''`,
+	`UPDATE Events SET Kind = (SELECT MIN(Clusters.Kind) FROM Clusters WHERE Clusters.Name = Events.ClusterName) WHERE Kind IS NULL`,
+	`ALTER TABLE NodePools ADD COLUMN Kind VARCHAR(64)`,
+	`UPDATE NodePools SET Kind = 'gke-cluster'`,
+	`ALTER TABLE Sweeps ADD COLUMN Kind VARCHAR(64)`,
+	`UPDATE Sweeps SET Kind = 'gke-cluster'`,
+	`DELETE FROM Clusters WHERE ID NOT IN (SELECT ID FROM (SELECT MIN(ID) AS ID FROM Clusters GROUP BY Kind, Name) AS Oldest)`,
+	`{{varchar cluster name}}`,
+	`CREATE UNIQUE INDEX ClustersKindName ON Clusters (Kind, Name)`,
+	`ALTER TABLE Clusters ADD COLUMN Owner VARCHAR(255)`,
+	`ALTER TABLE Clusters ADD COLUMN OwnerSource VARCHAR(32)`,
+	`ALTER TABLE Clusters ADD COLUMN IdleSince {{datetime}}`,
+	`ALTER TABLE Clusters ADD COLUMN HourlyCost DOUBLE PRECISION`,
+	`CREATE TABLE IF NOT EXISTS Deletions (
+		ID {{id}},
+		ClusterName VARCHAR(255) NOT NULL,
+		Kind VARCHAR(64),
+		Owner VARCHAR(255),
+		HourlyCost DOUBLE PRECISION,
+		DeleteDate {{datetime}}
+	)`,
+	`ALTER TABLE Clusters ADD COLUMN IgnoredBy VARCHAR(255)`,
+	`ALTER TABLE Clusters ADD COLUMN IgnoreDate {{datetime}}`,
+	`ALTER TABLE Clusters ADD COLUMN RenewalCount INT`,
+	`ALTER TABLE Clusters ADD COLUMN RenewalExtension BIGINT`,
+	`ALTER TABLE Clusters ADD COLUMN Project VARCHAR(255)`,
+	`ALTER TABLE Clusters ADD COLUMN Location VARCHAR(64)`,
+	`ALTER TABLE Sweeps ADD COLUMN Zones TEXT`,
+	`ALTER TABLE Clusters ADD COLUMN ExpirationBeforeIdle {{datetime}}`,
+}
+
+var dialects = map[string]*strings.Replacer{
+	store.DriverMySQL: strings.NewReplacer(
+		"{{id}}", "INT NOT NULL AUTO_INCREMENT PRIMARY KEY",
+		"{{datetime}}", "DATETIME",
+		"{{varchar cluster name}}", "ALTER TABLE Clusters MODIFY Name VARCHAR(255) NOT NULL",
+	),
+	store.DriverSQLite: strings.NewReplacer(
+		"{{id}}", "INTEGER PRIMARY KEY AUTOINCREMENT",
+		"{{datetime}}", "DATETIME",
+		// SQLite indexes TEXT columns as they are.
+		"{{varchar cluster name}}", "SELECT 1",
+	),
+	store.DriverPostgres: strings.NewReplacer(
+		"{{id}}", "SERIAL PRIMARY KEY",
+		"{{datetime}}", "TIMESTAMP",
+		"{{varchar cluster name}}", "ALTER TABLE Clusters ALTER COLUMN Name TYPE VARCHAR(255)",
+	),
+}
+
 type DB struct {
 	Log logr.Logger
-	DB  *sql.DB
+	DB  *store.DB
 }
 
 func (d *DB) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
-	statement, err := d.DB.Prepare(`CREATE TABLE IF NOT EXISTS Clusters (
-		ID INT NOT NULL AUTO_INCREMENT,
-		Name TEXT NOT NULL,
-		CreateDate DATETIME,
-		ExpirationDate DATETIME,
-		IgnoreMe BOOLEAN,
-		PRIMARY KEY (ID)
-	)`)
-	if err != nil {
-		return err
-	}
-	_, err = statement.Exec()
+	err := d.Migrate()
 	if err != nil {
 		return err
 	}
@@ -37,3 +149,91 @@ func (d *DB) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		return nil
 	}
 }
+
+// Migrate applies the migrations the database is missing. Instances started
+// together take turns, so each migration is applied once.
+func (d *DB) Migrate() error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = d.DB.Exec(`CREATE TABLE IF NOT EXISTS SchemaMigrations (
+		Version INT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema migrations table: %s", err)
+	}
+
+	var version int
+	err = d.DB.QueryRow(`SELECT COALESCE(MAX(Version), 0) FROM SchemaMigrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %s", err)
+	}
+
+	dialect, ok := dialects[d.DB.Driver]
+	if !ok {
+		return fmt.Errorf("unsupported database driver: %s", d.DB.Driver)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		d.Log.Info("Applying migration", "version", i+1)
+		_, err = d.DB.Exec(dialect.Replace(migrations[i]))
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %s", i+1, err)
+		}
+
+		_, err = d.DB.Exec(`INSERT INTO SchemaMigrations (Version) VALUES (?)`, i+1)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// lock takes a lock on migrating the database, held until the returned
+// function is called. MySQL and postgres locks belong to a session, so they
+// are taken on a connection of their own that is kept until then. Sqlite is
+// opened with a single connection and needs no lock.
+func (d *DB) lock() (func(), error) {
+	var acquire, release string
+	switch d.DB.Driver {
+	case store.DriverMySQL:
+		acquire = fmt.Sprintf(`SELECT GET_LOCK(CONCAT(DATABASE(), '.%s'), %d)`, migrationLockName, int(migrationLockTimeout.Seconds()))
+		release = fmt.Sprintf(`SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.%s'))`, migrationLockName)
+	case store.DriverPostgres:
+		acquire = fmt.Sprintf(`SELECT 1 FROM pg_advisory_lock(%d)`, migrationLockID)
+		release = fmt.Sprintf(`SELECT pg_advisory_unlock(%d)`, migrationLockID)
+	default:
+		return func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationLockTimeout)
+	defer cancel()
+
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a connection for the migration lock: %s", err)
+	}
+
+	d.Log.Info("Taking the migration lock")
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, acquire).Scan(&acquired)
+	if err == nil && acquired.Int64 != 1 {
+		err = fmt.Errorf("timed out after %s", migrationLockTimeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take the migration lock: %s", err)
+	}
+
+	return func() {
+		_, err := conn.ExecContext(context.Background(), release)
+		if err != nil {
+			d.Log.Error(err, "Failed to release the migration lock")
+		}
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrateDB := &DB{Log: zapr.NewLogger(zap.NewNop()), DB: db}
+	for i := 0; i < 2; i++ {
+		err = migrateDB.Migrate()
+		if err != nil {
+			t.Fatalf("migration %d failed: %s", i, err)
+		}
+	}
+
+	var version, leases int
+	err = db.QueryRow(`SELECT MAX(Version) FROM SchemaMigrations`).Scan(&version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("expected schema version %d, got %d", len(migrations), version)
+	}
+
+	err = db.QueryRow(`SELECT COUNT(*) FROM Leases`).Scan(&leases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leases != 1 {
+		t.Errorf("expected the lease row to be inserted once, got %d", leases)
+	}
+}
+
+func TestMigrateKeysClustersByKind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = (&DB{Log: zapr.NewLogger(zap.NewNop()), DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	clusterStore := &store.Cluster{DB: db}
+	for _, kind := range []string{"gke-cluster", "compute-instance"} {
+		err = clusterStore.Insert(ctx, store.ClusterRecord{Kind: kind, Name: "dev"})
+		if err != nil {
+			t.Fatalf("expected a %s named dev to be inserted, got %s", kind, err)
+		}
+	}
+
+	err = clusterStore.Insert(ctx, store.ClusterRecord{Kind: "gke-cluster", Name: "dev"})
+	if err == nil {
+		t.Errorf("expected a second gke-cluster named dev to be rejected")
+	}
+
+	clusters, err := clusterStore.ListByName(ctx, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || clusters[0].Kind != "compute-instance" || clusters[1].Kind != "gke-cluster" {
+		t.Errorf("expected a compute-instance and a gke-cluster named dev, got %+v", clusters)
+	}
+}
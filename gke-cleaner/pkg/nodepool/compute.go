@@ -0,0 +1,38 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Compute reads the target size of a node pool's instance groups with the
+// Compute Engine API.
+type Compute struct {
+	Service *compute.Service
+}
+
+// Size returns the largest target size of the instance groups. A zone being
+// resized can lag behind the others, the largest is what the node pool was
+// sized to.
+func (c *Compute) Size(ctx context.Context, instanceGroupURLs []string) (int, error) {
+	size := 0
+	for _, url := range instanceGroupURLs {
+		instanceGroup, err := ParseInstanceGroupURL(url)
+		if err != nil {
+			return 0, err
+		}
+
+		manager, err := c.Service.InstanceGroupManagers.Get(instanceGroup.Project, instanceGroup.Zone, instanceGroup.Name).Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get instance group %s: %s", instanceGroup.Name, err)
+		}
+
+		if int(manager.TargetSize) > size {
+			size = int(manager.TargetSize)
+		}
+	}
+
+	return size, nil
+}
@@ -0,0 +1,33 @@
+package nodepool
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is an in-memory Sizer. Sizes are keyed by instance group url, Err is
+// returned by every call when set.
+type Fake struct {
+	Sizes map[string]int
+	Err   error
+
+	mutex sync.Mutex
+}
+
+func (f *Fake) Size(ctx context.Context, instanceGroupURLs []string) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.Err != nil {
+		return 0, f.Err
+	}
+
+	size := 0
+	for _, url := range instanceGroupURLs {
+		if f.Sizes[url] > size {
+			size = f.Sizes[url]
+		}
+	}
+
+	return size, nil
+}
@@ -0,0 +1,40 @@
+package nodepool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Sizer reads the current size of a node pool from its instance groups, one
+// per zone.
+type Sizer interface {
+	// Size returns the node count per zone of the node pool, the count GKE
+	// sizes a node pool by.
+	Size(ctx context.Context, instanceGroupURLs []string) (int, error)
+}
+
+// InstanceGroup identifies the instance group manager of one zone of a node
+// pool.
+type InstanceGroup struct {
+	Project string
+	Zone    string
+	Name    string
+}
+
+// ParseInstanceGroupURL parses the URL of a node pool's instance group, e.g.
+// https://www.googleapis.com/compute/v1/projects/p/zones/z/instanceGroupManagers/name.
+func ParseInstanceGroupURL(url string) (InstanceGroup, error) {
+	segments := strings.Split(url, "/")
+	for i := 0; i+5 < len(segments); i++ {
+		if segments[i] == "projects" && segments[i+2] == "zones" && segments[i+4] == "instanceGroupManagers" {
+			return InstanceGroup{
+				Project: segments[i+1],
+				Zone:    segments[i+3],
+				Name:    segments[i+5],
+			}, nil
+		}
+	}
+
+	return InstanceGroup{}, fmt.Errorf("invalid instance group url %q", url)
+}
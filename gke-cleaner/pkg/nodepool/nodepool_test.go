@@ -0,0 +1,20 @@
+package nodepool
+
+import "testing"
+
+func TestParseInstanceGroupURL(t *testing.T) {
+	instanceGroup, err := ParseInstanceGroupURL("https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a/instanceGroupManagers/gke-dev-default-pool-1234-grp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := InstanceGroup{Project: "project", Zone: "us-central1-a", Name: "gke-dev-default-pool-1234-grp"}
+	if instanceGroup != expected {
+		t.Errorf("expected %+v, got %+v", expected, instanceGroup)
+	}
+
+	_, err = ParseInstanceGroupURL("https://www.googleapis.com/compute/v1/projects/project/zones/us-central1-a")
+	if err == nil {
+		t.Error("expected an error for a url without an instance group manager")
+	}
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/go-logr/logr"
+)
+
+// TypeDigest is the type of digest notifications, whose message is the
+// rendered digest.
+const TypeDigest = "digest"
+
+type Notification struct {
+	ClusterName string
+	Owner       string `json:",omitempty"`
+	Type        string
+	Message     string
+	Format      string `json:",omitempty"`
+	Time        time.Time
+}
+
+type Sink interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+type filteredSink struct {
+	sink   Sink
+	events map[string]bool
+}
+
+type Notifier struct {
+	Log logr.Logger
+
+	mutex sync.RWMutex
+	sinks []filteredSink
+}
+
+func NewNotifier(log logr.Logger, sinkConfigs []config.NotificationSink) (*Notifier, error) {
+	notifier := &Notifier{Log: log}
+
+	sinks, err := buildSinks(sinkConfigs)
+	if err != nil {
+		return nil, err
+	}
+	notifier.sinks = sinks
+
+	return notifier, nil
+}
+
+// Configure replaces every sink with the given sinks. If any sink is invalid
+// the current sinks are kept.
+func (n *Notifier) Configure(sinkConfigs []config.NotificationSink) error {
+	if n == nil {
+		return nil
+	}
+
+	sinks, err := buildSinks(sinkConfigs)
+	if err != nil {
+		n.Log.Error(err, "Failed to configure notification sinks")
+		return err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.sinks = sinks
+
+	return nil
+}
+
+func (n *Notifier) AddSink(sink Sink, events ...string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.sinks = append(n.sinks, newFilteredSink(sink, events))
+}
+
+func buildSinks(sinkConfigs []config.NotificationSink) ([]filteredSink, error) {
+	sinks := []filteredSink{}
+	for _, sinkConfig := range sinkConfigs {
+		var sink Sink
+		switch sinkConfig.Type {
+		case "webhook":
+			sink = &Webhook{URL: sinkConfig.URL}
+		case "slack":
+			sink = &Slack{URL: sinkConfig.URL}
+		default:
+			return nil, fmt.Errorf("unknown notification sink type: %s", sinkConfig.Type)
+		}
+
+		sinks = append(sinks, newFilteredSink(sink, sinkConfig.Events))
+	}
+
+	return sinks, nil
+}
+
+func newFilteredSink(sink Sink, events []string) filteredSink {
+	filtered := filteredSink{sink: sink}
+	if len(events) > 0 {
+		filtered.events = map[string]bool{}
+		for _, event := range events {
+			filtered.events[event] = true
+		}
+	}
+
+	return filtered
+}
+
+func (n *Notifier) Notify(ctx context.Context, clusterName string, eventType string, message string) {
+	n.NotifyOwner(ctx, clusterName, "", eventType, message)
+}
+
+// NotifyOwner sends a notification addressed to the cluster's owner, so a
+// sink can pass it on to them.
+func (n *Notifier) NotifyOwner(ctx context.Context, clusterName string, owner string, eventType string, message string) {
+	n.send(ctx, Notification{
+		ClusterName: clusterName,
+		Owner:       owner,
+		Type:        eventType,
+		Message:     message,
+		Time:        time.Now(),
+	})
+}
+
+// NotifyDigest sends a digest rendered in the format.
+func (n *Notifier) NotifyDigest(ctx context.Context, format string, digest string) {
+	n.send(ctx, Notification{
+		Type:    TypeDigest,
+		Message: digest,
+		Format:  format,
+		Time:    time.Now(),
+	})
+}
+
+func (n *Notifier) send(ctx context.Context, notification Notification) {
+	if n == nil {
+		return
+	}
+
+	n.mutex.RLock()
+	sinks := n.sinks
+	n.mutex.RUnlock()
+
+	for _, s := range sinks {
+		if s.events != nil && !s.events[notification.Type] {
+			continue
+		}
+
+		err := s.sink.Send(ctx, notification)
+		if err != nil {
+			n.Log.Error(err, "Failed to send notification", "cluster", notification.ClusterName, "type", notification.Type)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+// receiver collects the json bodies posted to it.
+type receiver struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.bodies = append(r.bodies, body)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *receiver) received() []map[string]interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]map[string]interface{}{}, r.bodies...)
+}
+
+func TestNotifierFiltersEvents(t *testing.T) {
+	all := newReceiver(t)
+	deletions := newReceiver(t)
+
+	notifier, err := NewNotifier(zapr.NewLogger(zap.NewNop()), []config.NotificationSink{
+		{Type: "webhook", URL: all.URL},
+		{Type: "webhook", URL: deletions.URL, Events: []string{"deleted"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Notify(context.Background(), "dev", "renewed", "expires at 2030-01-01T00:00:00Z")
+	notifier.Notify(context.Background(), "dev", "deleted", "expired")
+
+	if got := len(all.received()); got != 2 {
+		t.Errorf("expected the unfiltered sink to get both notifications, got %d", got)
+	}
+
+	received := deletions.received()
+	if len(received) != 1 || received[0]["Type"] != "deleted" || received[0]["ClusterName"] != "dev" {
+		t.Errorf("expected the filtered sink to only get the deletion, got %v", received)
+	}
+}
+
+func TestSlackMessage(t *testing.T) {
+	r := newReceiver(t)
+	slack := &Slack{URL: r.URL}
+
+	err := slack.Send(context.Background(), Notification{ClusterName: "dev", Type: "deleted", Message: "expired"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := r.received()
+	expected := "gke-cleaner: cluster *dev* deleted: expired"
+	if len(received) != 1 || received[0]["text"] != expected {
+		t.Errorf("expected %q, got %v", expected, received)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := (&Webhook{URL: server.URL}).Send(context.Background(), Notification{ClusterName: "dev", Type: "deleted"})
+	if err == nil {
+		t.Error("expected an error when the endpoint fails")
+	}
+}
+
+func TestNewNotifierRejectsUnknownSinks(t *testing.T) {
+	_, err := NewNotifier(zapr.NewLogger(zap.NewNop()), []config.NotificationSink{{Type: "email", URL: "mailto:ops@example.com"}})
+	if err == nil {
+		t.Error("expected an unknown sink type to be rejected")
+	}
+}
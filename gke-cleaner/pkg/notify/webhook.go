@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Webhook struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, w.HTTPClient, w.URL, notification)
+}
+
+type Slack struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+func (s *Slack) Send(ctx context.Context, notification Notification) error {
+	if notification.Type == TypeDigest {
+		return postJSON(ctx, s.HTTPClient, s.URL, map[string]string{"text": notification.Message})
+	}
+
+	text := fmt.Sprintf("gke-cleaner: cluster *%s* %s", notification.ClusterName, notification.Type)
+	if notification.Message != "" {
+		text = fmt.Sprintf("%s: %s", text, notification.Message)
+	}
+	if notification.Owner != "" {
+		text = fmt.Sprintf("%s (owner %s)", text, notification.Owner)
+	}
+
+	return postJSON(ctx, s.HTTPClient, s.URL, map[string]string{"text": text})
+}
+
+func postJSON(ctx context.Context, httpClient *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification endpoint returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
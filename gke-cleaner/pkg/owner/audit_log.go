@@ -0,0 +1,64 @@
+package owner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	logging "google.golang.org/api/logging/v2"
+)
+
+// auditLogWindow is how far around the cluster's create time the
+// CreateCluster call is looked for. The call is logged when the operation
+// starts, a few minutes before the cluster reports it was created.
+const auditLogWindow = time.Hour
+
+// CloudLogging reads the principal that called CreateCluster from the admin
+// activity audit log of the cluster's project. It needs the
+// roles/logging.viewer role.
+type CloudLogging struct {
+	Service *logging.Service
+}
+
+func (c *CloudLogging) Creator(ctx context.Context, cluster Cluster) (string, error) {
+	filter := fmt.Sprintf(`logName="projects/%s/logs/cloudaudit.googleapis.com%%2Factivity"`+
+		` AND resource.type="gke_cluster"`+
+		` AND resource.labels.cluster_name="%s"`+
+		` AND protoPayload.methodName:"CreateCluster"`,
+		cluster.Project, cluster.Name)
+	if cluster.Location != "" {
+		filter += fmt.Sprintf(` AND resource.labels.location="%s"`, cluster.Location)
+	}
+	if !cluster.CreateTime.IsZero() {
+		filter += fmt.Sprintf(` AND timestamp>="%s" AND timestamp<="%s"`,
+			cluster.CreateTime.Add(-auditLogWindow).UTC().Format(time.RFC3339),
+			cluster.CreateTime.Add(auditLogWindow).UTC().Format(time.RFC3339))
+	}
+
+	response, err := c.Service.Entries.List(&logging.ListLogEntriesRequest{
+		ResourceNames: []string{fmt.Sprintf("projects/%s", cluster.Project)},
+		Filter:        filter,
+		OrderBy:       "timestamp desc",
+		PageSize:      1,
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to list audit log entries for %s: %s", cluster.Name, err)
+	}
+
+	if len(response.Entries) == 0 {
+		return "", nil
+	}
+
+	var payload struct {
+		AuthenticationInfo struct {
+			PrincipalEmail string `json:"principalEmail"`
+		} `json:"authenticationInfo"`
+	}
+	err = json.Unmarshal(response.Entries[0].ProtoPayload, &payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse audit log entry for %s: %s", cluster.Name, err)
+	}
+
+	return payload.AuthenticationInfo.PrincipalEmail, nil
+}
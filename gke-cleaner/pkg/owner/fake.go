@@ -0,0 +1,36 @@
+package owner
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeAuditLog is an in-memory AuditLogReader with the creators of clusters
+// by name. Err is returned by every call when set.
+type FakeAuditLog struct {
+	Creators map[string]string
+	Err      error
+
+	mutex sync.Mutex
+	calls []Cluster
+}
+
+func (f *FakeAuditLog) Creator(ctx context.Context, cluster Cluster) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, cluster)
+	if f.Err != nil {
+		return "", f.Err
+	}
+
+	return f.Creators[cluster.Name], nil
+}
+
+// Calls returns every cluster the audit log was read for so far.
+func (f *FakeAuditLog) Calls() []Cluster {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Cluster{}, f.calls...)
+}
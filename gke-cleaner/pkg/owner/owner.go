@@ -0,0 +1,76 @@
+package owner
+
+import (
+	"context"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+)
+
+// Where an owner was found. SourceNone means every source was tried and
+// none knew the owner, so it is not looked up again.
+const (
+	SourceLabel    = "label"
+	SourceMapping  = "mapping"
+	SourceAuditLog = "audit-log"
+	SourceNone     = "none"
+)
+
+// Cluster is what the sources need to know about a cluster to find its owner.
+type Cluster struct {
+	Project    string
+	Location   string
+	Name       string
+	Labels     map[string]string
+	CreateTime time.Time
+}
+
+// AuditLogReader finds who created a cluster, it returns an empty string if
+// the audit log does not say.
+type AuditLogReader interface {
+	Creator(ctx context.Context, cluster Cluster) (string, error)
+}
+
+// Resolver finds the owner of a cluster from the first of its labels, the
+// label to owner mappings and the audit log that knows it. A nil AuditLog
+// skips the audit log.
+type Resolver struct {
+	Labels   []string
+	Mappings []config.OwnerMapping
+	AuditLog AuditLogReader
+}
+
+// FromLabels finds the owner from the cluster's labels and the mappings,
+// without calling out to the audit log.
+func (r *Resolver) FromLabels(labels map[string]string) (string, string) {
+	for _, label := range r.Labels {
+		if owner := labels[label]; owner != "" {
+			return owner, SourceLabel
+		}
+	}
+
+	for _, mapping := range r.Mappings {
+		if mapping.Matches(labels) {
+			return mapping.Email, SourceMapping
+		}
+	}
+
+	return "", SourceNone
+}
+
+func (r *Resolver) Resolve(ctx context.Context, cluster Cluster) (string, string, error) {
+	owner, source := r.FromLabels(cluster.Labels)
+	if source != SourceNone || r.AuditLog == nil {
+		return owner, source, nil
+	}
+
+	owner, err := r.AuditLog.Creator(ctx, cluster)
+	if err != nil {
+		return "", "", err
+	}
+	if owner == "" {
+		return "", SourceNone, nil
+	}
+
+	return owner, SourceAuditLog, nil
+}
@@ -0,0 +1,92 @@
+package owner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+)
+
+func TestResolveOrder(t *testing.T) {
+	resolver := &Resolver{
+		Labels: []string{"owner", "team-owner"},
+		Mappings: []config.OwnerMapping{
+			{Labels: map[string]string{"team": "data"}, Email: "data@example.com"},
+			{Labels: map[string]string{"team": "data", "env": "dev"}, Email: "data-dev@example.com"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		owner     string
+		source    string
+		auditLogs int
+	}{
+		{
+			name:   "the first owner label wins over later ones and the mappings",
+			labels: map[string]string{"owner": "alice", "team-owner": "bob", "team": "data"},
+			owner:  "alice",
+			source: SourceLabel,
+		},
+		{
+			name:   "an empty owner label is skipped",
+			labels: map[string]string{"owner": "", "team-owner": "bob"},
+			owner:  "bob",
+			source: SourceLabel,
+		},
+		{
+			name:   "the first matching mapping wins over the audit log",
+			labels: map[string]string{"team": "data", "env": "dev"},
+			owner:  "data@example.com",
+			source: SourceMapping,
+		},
+		{
+			name:      "the audit log is read last",
+			labels:    map[string]string{"team": "web"},
+			owner:     "carol@example.com",
+			source:    SourceAuditLog,
+			auditLogs: 1,
+		},
+	}
+
+	for _, test := range tests {
+		auditLog := &FakeAuditLog{Creators: map[string]string{"dev": "carol@example.com"}}
+		resolver.AuditLog = auditLog
+
+		owner, source, err := resolver.Resolve(context.Background(), Cluster{Name: "dev", Labels: test.labels})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if owner != test.owner || source != test.source {
+			t.Errorf("%s: expected %q from %s, got %q from %s", test.name, test.owner, test.source, owner, source)
+		}
+		if calls := len(auditLog.Calls()); calls != test.auditLogs {
+			t.Errorf("%s: expected the audit log to be read %d times, got %d", test.name, test.auditLogs, calls)
+		}
+	}
+}
+
+func TestResolveWithoutOwner(t *testing.T) {
+	cluster := Cluster{Name: "dev", Labels: map[string]string{"team": "web"}}
+
+	owner, source, err := (&Resolver{Labels: []string{"owner"}}).Resolve(context.Background(), cluster)
+	if err != nil || owner != "" || source != SourceNone {
+		t.Errorf("without an audit log: expected no owner from %s, got %q from %q, %v", SourceNone, owner, source, err)
+	}
+
+	resolver := &Resolver{Labels: []string{"owner"}, AuditLog: &FakeAuditLog{}}
+	owner, source, err = resolver.Resolve(context.Background(), cluster)
+	if err != nil || owner != "" || source != SourceNone {
+		t.Errorf("with an audit log that doesn't know: expected no owner from %s, got %q from %q, %v", SourceNone, owner, source, err)
+	}
+
+	auditLogErr := errors.New("permission denied")
+	resolver = &Resolver{Labels: []string{"owner"}, AuditLog: &FakeAuditLog{Err: auditLogErr}}
+	owner, source, err = resolver.Resolve(context.Background(), cluster)
+	if err != auditLogErr || owner != "" || source != "" {
+		t.Errorf("with a failing audit log: expected no source and %v, got %q from %q, %v", auditLogErr, owner, source, err)
+	}
+}
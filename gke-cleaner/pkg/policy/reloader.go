@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+type Reloader struct {
+	Log        logr.Logger
+	Policy     *Store
+	EventStore *store.Event
+
+	ConfigPath    string
+	WatchInterval time.Duration
+
+	// Hangups receives SIGHUP. It is registered before the process starts
+	// its members, a SIGHUP would otherwise stop the process until the
+	// reloader runs.
+	Hangups <-chan os.Signal
+
+	mutex   sync.Mutex
+	modTime time.Time
+}
+
+func (r *Reloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if r.ConfigPath != "" {
+		r.modTime = r.configModTime()
+	}
+
+	var watch <-chan time.Time
+	if r.ConfigPath != "" && r.WatchInterval > 0 {
+		ticker := time.NewTicker(r.WatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	close(ready)
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-r.Hangups:
+			r.reloadAndLog("SIGHUP")
+		case <-watch:
+			modTime := r.configModTime()
+			if modTime.Equal(r.modTime) {
+				continue
+			}
+			r.modTime = modTime
+			r.reloadAndLog("config file change")
+		}
+	}
+}
+
+func (r *Reloader) reloadAndLog(source string) {
+	_, err := r.Reload(context.Background(), source)
+	if err != nil {
+		r.Log.Error(err, "Failed to reload config", "source", source)
+	}
+}
+
+// Reload loads and validates the whole config and then replaces the current
+// policy with the new one. An invalid config leaves the current policy as is.
+func (r *Reloader) Reload(ctx context.Context, source string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cfg, err := config.Load(r.Log.WithName("config.Load"), r.ConfigPath)
+	if err != nil {
+		r.recordEvent(ctx, store.EventConfigReloadFailed, fmt.Sprintf("%s: %s", source, err))
+		return nil, err
+	}
+
+	changes := r.Policy.Set(cfg.Policy())
+	if len(changes) == 0 {
+		r.Log.Info("Reloaded config without changes", "source", source)
+		return changes, nil
+	}
+
+	r.Log.Info("Reloaded config", "source", source, "changes", changes)
+	r.recordEvent(ctx, store.EventConfigReloaded, fmt.Sprintf("%s: %s", source, strings.Join(changes, "; ")))
+
+	return changes, nil
+}
+
+func (r *Reloader) recordEvent(ctx context.Context, eventType string, message string) {
+	err := r.EventStore.Insert(ctx, "", "", eventType, message)
+	if err != nil {
+		r.Log.Error(err, "Failed to record event", "type", eventType)
+	}
+}
+
+func (r *Reloader) configModTime() time.Time {
+	info, err := os.Stat(r.ConfigPath)
+	if err != nil {
+		r.Log.Error(err, "Failed to stat config file", "path", r.ConfigPath)
+		return r.modTime
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"github.com/tedsuo/ifrit"
+	"go.uber.org/zap"
+)
+
+var testLog = zapr.NewLogger(zap.NewNop())
+
+const testConfig = `
+port: 8080
+projects: [project-a]
+cluster_lifetime_duration: 8h
+database:
+  uri: user:pass@tcp(localhost:3306)/gke_cleaner
+auth:
+  username: admin
+  password: secret
+`
+
+func newTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gke-cleaner-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func newTestEventStore(t *testing.T) *store.Event {
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(newTestDir(t), "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = (&migrate.DB{Log: testLog, DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &store.Event{DB: db}
+}
+
+// newTestReloader returns a reloader for a config file holding testConfig and
+// the path of that file.
+func newTestReloader(t *testing.T) (*Reloader, string) {
+	path := filepath.Join(newTestDir(t), "config.yml")
+	writeConfig(t, path, testConfig)
+
+	cfg, err := config.Load(testLog, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Reloader{
+		Log:        testLog,
+		Policy:     NewStore(cfg.Policy()),
+		EventStore: newTestEventStore(t),
+		ConfigPath: path,
+	}, path
+}
+
+func writeConfig(t *testing.T, path string, contents string) {
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func events(t *testing.T, eventStore *store.Event) []store.EventRecord {
+	events, err := eventStore.ListByCluster(context.Background(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestReload(t *testing.T) {
+	reloader, path := newTestReloader(t)
+	_, version := reloader.Policy.GetVersioned()
+
+	writeConfig(t, path, strings.Replace(testConfig, "8h", "24h", 1)+"dry_run: true\n")
+
+	changes, err := reloader.Reload(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"cluster_lifetime_duration: 8h0m0s -> 24h0m0s", "dry_run: false -> true"}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected changes %q, got %q", expected, changes)
+	}
+
+	policy, newVersion := reloader.Policy.GetVersioned()
+	if policy.ClusterLifetimeDuration != 24*time.Hour || !policy.DryRun {
+		t.Errorf("expected the new policy to be set, got %+v", policy)
+	}
+	if newVersion == version {
+		t.Errorf("expected the policy version to change")
+	}
+
+	recorded := events(t, reloader.EventStore)
+	if len(recorded) != 1 || recorded[0].Type != store.EventConfigReloaded || recorded[0].Message != "test: "+strings.Join(expected, "; ") {
+		t.Errorf("expected a config-reloaded event with the diff, got %+v", recorded)
+	}
+}
+
+func TestReloadWithoutChanges(t *testing.T) {
+	reloader, _ := newTestReloader(t)
+	_, version := reloader.Policy.GetVersioned()
+
+	changes, err := reloader.Reload(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %q", changes)
+	}
+	if _, newVersion := reloader.Policy.GetVersioned(); newVersion != version {
+		t.Errorf("expected the policy version to stay the same")
+	}
+	if recorded := events(t, reloader.EventStore); len(recorded) != 0 {
+		t.Errorf("expected no events, got %+v", recorded)
+	}
+}
+
+func TestReloadKeepsPolicyWhenInvalid(t *testing.T) {
+	reloader, path := newTestReloader(t)
+	before := reloader.Policy.Get()
+
+	writeConfig(t, path, strings.Replace(testConfig, "8h", "24h", 1)+"lifetime_rules: [{labels: {}, lifetime: 1h}]\n")
+
+	_, err := reloader.Reload(context.Background(), "test")
+	if err == nil {
+		t.Fatal("expected the invalid config to be rejected")
+	}
+
+	if after := reloader.Policy.Get(); after.ClusterLifetimeDuration != before.ClusterLifetimeDuration || len(after.LifetimeRules) != 0 {
+		t.Errorf("expected the old policy to be kept, got %+v", after)
+	}
+
+	recorded := events(t, reloader.EventStore)
+	if len(recorded) != 1 || recorded[0].Type != store.EventConfigReloadFailed || !strings.Contains(recorded[0].Message, "must have at least one label") {
+		t.Errorf("expected a config-reload-failed event, got %+v", recorded)
+	}
+}
+
+// The SIGHUP is sent before the reloader runs, as it can be while the
+// process is still starting, and must not be lost.
+func TestRunReloadsOnSIGHUP(t *testing.T) {
+	reloader, path := newTestReloader(t)
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	t.Cleanup(func() { signal.Stop(hangups) })
+	reloader.Hangups = hangups
+
+	writeConfig(t, path, strings.Replace(testConfig, "8h", "24h", 1))
+	err := syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	process := ifrit.Invoke(reloader)
+	t.Cleanup(func() {
+		process.Signal(os.Interrupt)
+		<-process.Wait()
+	})
+
+	waitForLifetime(t, reloader, 24*time.Hour)
+}
+
+func TestRunReloadsOnConfigFileChange(t *testing.T) {
+	reloader, path := newTestReloader(t)
+	reloader.WatchInterval = 10 * time.Millisecond
+
+	process := ifrit.Invoke(reloader)
+	t.Cleanup(func() {
+		process.Signal(os.Interrupt)
+		<-process.Wait()
+	})
+
+	writeConfig(t, path, strings.Replace(testConfig, "8h", "24h", 1))
+	modTime := time.Now().Add(time.Minute)
+	err := os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForLifetime(t, reloader, 24*time.Hour)
+}
+
+func waitForLifetime(t *testing.T, reloader *Reloader, lifetime time.Duration) {
+	deadline := time.Now().Add(5 * time.Second)
+	for reloader.Policy.Get().ClusterLifetimeDuration != lifetime {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the cluster lifetime to be reloaded as %s, got %s", lifetime, reloader.Policy.Get().ClusterLifetimeDuration)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
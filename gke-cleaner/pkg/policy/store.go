@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"sync"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+)
+
+type Store struct {
+	mutex   sync.RWMutex
+	current config.Policy
+	version int
+}
+
+func NewStore(policy config.Policy) *Store {
+	return &Store{current: policy, version: 1}
+}
+
+func (s *Store) Get() config.Policy {
+	policy, _ := s.GetVersioned()
+	return policy
+}
+
+// GetVersioned returns the current policy and a version that changes every
+// time a new policy is set.
+func (s *Store) GetVersioned() (config.Policy, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.current, s.version
+}
+
+func (s *Store) Set(policy config.Policy) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	changes := s.current.Diff(policy)
+	if len(changes) == 0 {
+		return changes
+	}
+
+	s.current = policy
+	s.version++
+
+	return changes
+}
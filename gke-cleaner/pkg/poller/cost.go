@@ -0,0 +1,138 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/cost"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// spotLabel is the node label of GKE spot node pools. The container API in
+// use does not report whether a node pool is spot, spot nodes are priced as
+// preemptible when their node config carries it.
+const spotLabel = "cloud.google.com/gke-spot"
+
+// estimateCosts stores the hourly cost of each tracked cluster estimated from
+// its node pools. A cluster that was scaled to zero only costs its cluster
+// fee.
+func (g *GKE) estimateCosts(ctx context.Context, clusters []gkeCluster) error {
+	if g.Pricing == nil {
+		return nil
+	}
+
+	records, err := g.ClusterStore.ListByKind(ctx, provider.KindGKECluster)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		cluster, err := g.getCluster(clusters, record)
+		if err != nil || !sameCreateTime(&record, cluster) {
+			continue
+		}
+
+		nodePools := []cost.NodePool{}
+		if record.ActionTaken != config.ActionScaleToZero {
+			nodePools = g.costNodePools(ctx, cluster)
+		}
+
+		hourlyCost, err := g.Pricing.HourlyCost(nodePools)
+		if err != nil {
+			g.Log.Info("Failed to estimate cost", "cluster", record.Name, "reason", err.Error())
+			continue
+		}
+		if math.Abs(hourlyCost-record.HourlyCost) < 0.00005 {
+			continue
+		}
+
+		g.Log.V(1).Info("Estimated cost", "cluster", record.Name, "hourlyCost", hourlyCost)
+		err = g.ClusterStore.UpdateHourlyCost(ctx, provider.KindGKECluster, record.Name, hourlyCost)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// costNodePools counts the nodes of each node pool from the current size of
+// its instance groups, one per zone. A node pool whose size can't be read is
+// counted at its initial size.
+func (g *GKE) costNodePools(ctx context.Context, cluster gkeCluster) []cost.NodePool {
+	nodePools := []cost.NodePool{}
+	for _, nodePool := range cluster.NodePools {
+		nodeCount, err := g.nodeCount(ctx, nodePool)
+		if err != nil {
+			g.Log.Info("Failed to get node pool size, estimating cost from its initial size", "cluster", cluster.Name, "nodePool", nodePool.Name, "reason", err.Error())
+			nodeCount = initialNodeCount(cluster, nodePool)
+		}
+
+		costNodePool := cost.NodePool{
+			Name:      nodePool.Name,
+			NodeCount: nodeCount,
+		}
+		if nodePool.Config != nil {
+			costNodePool.MachineType = nodePool.Config.MachineType
+			costNodePool.Preemptible = nodePool.Config.Preemptible || nodePool.Config.Labels[spotLabel] == "true"
+			costNodePool.DiskType = nodePool.Config.DiskType
+			costNodePool.DiskSizeGB = int(nodePool.Config.DiskSizeGb)
+		}
+
+		nodePools = append(nodePools, costNodePool)
+	}
+
+	return nodePools
+}
+
+// nodeCount adds up the current sizes of the node pool's instance groups.
+func (g *GKE) nodeCount(ctx context.Context, nodePool *containerpb.NodePool) (int, error) {
+	if len(nodePool.InstanceGroupUrls) == 0 {
+		return 0, errors.New("node pool has no instance groups")
+	}
+
+	nodeCount := 0
+	for _, url := range nodePool.InstanceGroupUrls {
+		callCtx, cancelCall := g.callContext(ctx)
+		size, err := g.NodePools.Size(callCtx, []string{url})
+		cancelCall()
+		if err != nil {
+			return 0, err
+		}
+		nodeCount += size
+	}
+
+	return nodeCount, nil
+}
+
+// initialNodeCount is the node pool's initial size in each of its zones.
+func initialNodeCount(cluster gkeCluster, nodePool *containerpb.NodePool) int {
+	zones := len(nodePool.InstanceGroupUrls)
+	if zones == 0 {
+		zones = len(cluster.Locations)
+	}
+	if zones == 0 {
+		zones = 1
+	}
+
+	return int(nodePool.InitialNodeCount) * zones
+}
+
+// recordDeletion keeps the cost of a deleted resource for the savings report.
+func recordDeletion(ctx context.Context, log logr.Logger, deletionStore *store.Deletion, record store.ClusterRecord) {
+	err := deletionStore.Insert(ctx, store.DeletionRecord{
+		ClusterName: record.Name,
+		Kind:        record.Kind,
+		Owner:       record.Owner,
+		HourlyCost:  record.HourlyCost,
+	})
+	if err != nil {
+		log.Error(err, "Failed to record deletion", "cluster", record.Name)
+	}
+}
@@ -0,0 +1,67 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/cost"
+	"github.com/christianang/gke-cleaner/pkg/nodepool"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+func TestEstimateCostsFromTheCurrentNodePoolSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		sizes      *nodepool.Fake
+		hourlyCost float64
+	}{
+		{
+			name:       "current size",
+			sizes:      &nodepool.Fake{Sizes: map[string]int{zoneAGroup: 3, zoneBGroup: 2}},
+			hourlyCost: 0.1 + 5*0.2,
+		},
+		{
+			name:       "initial size when the size can't be read",
+			sizes:      &nodepool.Fake{Err: errors.New("permission denied")},
+			hourlyCost: 0.1 + 2*0.2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gke, fake := newTestGKE(t, config.Policy{
+				ClusterLifetimeDuration: 8 * time.Hour,
+				LabelFilters:            []string{"env=dev"},
+			})
+			gke.NodePools = test.sizes
+			gke.Pricing = &cost.Pricing{
+				ClusterPerHour:      0.1,
+				MachineTypes:        map[string]cost.MachineTypePrice{"e2-standard-4": {PerHour: 0.2}},
+				DiskTypesPerGBMonth: map[string]float64{"pd-standard": 0},
+			}
+
+			cluster := testCluster("dev", time.Now().Add(-time.Hour), containerpb.Cluster_RUNNING)
+			cluster.NodePools = []*containerpb.NodePool{{
+				Name:              "default-pool",
+				InitialNodeCount:  1,
+				InstanceGroupUrls: []string{zoneAGroup, zoneBGroup},
+				Config:            &containerpb.NodeConfig{MachineType: "e2-standard-4"},
+			}}
+			fake.put("project-a", cluster)
+
+			err := gke.Sync(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if record := getRecord(t, gke, "dev"); math.Abs(record.HourlyCost-test.hourlyCost) > 0.0001 {
+				t.Errorf("expected an hourly cost of %.2f, got %.2f", test.hourlyCost, record.HourlyCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/christianang/gke-cleaner/pkg/subscriber"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+type ClusterSyncer interface {
+	SyncCluster(ctx context.Context, event subscriber.ClusterEvent) error
+}
+
+// Events applies cluster events from a subscriber as they arrive, so a new
+// cluster's lifetime starts when it is created rather than on the next poll.
+// The poll's full sync stays the fallback for missed events. Only the leader
+// receives events.
+type Events struct {
+	Log        logr.Logger
+	Subscriber subscriber.Subscriber
+	Syncer     ClusterSyncer
+	Leader     LeaderChecker
+
+	// RetryInterval is how often leadership is checked and how long to wait
+	// before receiving again after the subscriber failed.
+	RetryInterval time.Duration
+}
+
+func (e *Events) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.receive(ctx)
+		close(done)
+	}()
+
+	<-signals
+	cancel()
+	<-done
+
+	return nil
+}
+
+func (e *Events) receive(ctx context.Context) {
+	for {
+		if e.Leader.IsLeader() {
+			receiveCtx, stop := context.WithCancel(ctx)
+			go e.stopOnLostLeadership(receiveCtx, stop)
+
+			e.Log.Info("Receiving cluster events")
+			err := e.Subscriber.Receive(receiveCtx, e.handle)
+			stop()
+			if err != nil && ctx.Err() == nil {
+				e.Log.Error(err, "Failed to receive cluster events")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.RetryInterval):
+		}
+	}
+}
+
+func (e *Events) stopOnLostLeadership(ctx context.Context, stop context.CancelFunc) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.RetryInterval):
+			if !e.Leader.IsLeader() {
+				e.Log.Info("No longer the leader, stopped receiving cluster events")
+				stop()
+				return
+			}
+		}
+	}
+}
+
+// handle syncs the cluster a message is about. The message is redelivered if
+// the sync fails.
+func (e *Events) handle(ctx context.Context, message subscriber.Message) error {
+	event, ok := subscriber.Parse(message)
+	if !ok {
+		e.Log.V(1).Info("Ignoring message", "id", message.ID)
+		return nil
+	}
+
+	e.Log.Info("Cluster event", "cluster", event.Name, "project", event.Project, "location", event.Location, "source", event.Source)
+	err := e.Syncer.SyncCluster(ctx, event)
+	if err != nil {
+		e.Log.Error(err, "Failed to sync cluster", "cluster", event.Name)
+		return err
+	}
+
+	return nil
+}
+
+// SyncCluster brings the stored record of the cluster an event is about up to
+// date with gke: it is added if it was created, removed if it no longer
+// exists and updated if it was recreated. Status and label changes are left
+// to the poll.
+func (g *GKE) SyncCluster(ctx context.Context, event subscriber.ClusterEvent) error {
+	if !g.Leader.IsLeader() {
+		return ErrNotLeader
+	}
+
+	if !containsString(g.Projects, event.Project) {
+		g.Log.V(1).Info("Ignoring event for an unwatched project", "cluster", event.Name, "project", event.Project)
+		return nil
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	policy := g.applyPolicy()
+
+	if event.Location == "" {
+		return g.syncGKEClusters(ctx, policy)
+	}
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, event.Name)
+	known := err == nil
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if known && !sameProjectAndLocation(record, event.Project, event.Location) {
+		g.Log.Info("Ignoring event for a cluster with the name of a tracked cluster elsewhere", "cluster", event.Name, "project", event.Project, "location", event.Location, "trackedProject", record.Project, "trackedLocation", record.Location, "selfLink", record.SelfLink)
+		return nil
+	}
+
+	callCtx, cancelCall := g.callContext(ctx)
+	cluster, err := g.Client.GetCluster(callCtx, &containerpb.GetClusterRequest{
+		Name: fmt.Sprintf("projects/%s/locations/%s/clusters/%s", event.Project, event.Location, event.Name),
+	})
+	cancelCall()
+	if status.Code(err) == codes.NotFound {
+		if !known {
+			return nil
+		}
+
+		return g.removeCluster(ctx, event.Name, fmt.Sprintf("no longer found in gke after %s", event.Source))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cluster %s: %s", event.Name, err)
+	}
+
+	live := gkeCluster{Cluster: cluster, Project: event.Project}
+	switch {
+	case !matchesFilters(live.ResourceLabels, policy.LabelFilters):
+		if known {
+			return g.removeCluster(ctx, event.Name, "no longer matches the label filters")
+		}
+		return nil
+	case !known:
+		return g.addCluster(ctx, policy, live)
+	case !sameCreateTime(&record, live):
+		return g.recreateCluster(ctx, policy, live)
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameProjectAndLocation is whether the record's cluster is in the project
+// and location, going by its self link for a record stored before its
+// project and location were. A record without either is assumed to be.
+func sameProjectAndLocation(record store.ClusterRecord, project string, location string) bool {
+	if record.Project != "" {
+		return record.Project == project && record.Location == location
+	}
+	if record.SelfLink == "" {
+		return true
+	}
+
+	segments := strings.Split(record.SelfLink, "/")
+	linkProject, linkLocation := "", ""
+	for i := 0; i+1 < len(segments); i++ {
+		switch segments[i] {
+		case "projects":
+			linkProject = segments[i+1]
+		case "locations", "zones":
+			linkLocation = segments[i+1]
+		}
+	}
+
+	return linkProject == project && linkLocation == location
+}
@@ -0,0 +1,177 @@
+package poller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/christianang/gke-cleaner/pkg/subscriber"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// newEventsTest returns a GKE poller of a fake GKE watching project-a, and
+// the events of a fake subscriber synced into it until the test ends.
+func newEventsTest(t *testing.T) (*GKE, *fakeClusterManager, *subscriber.Fake) {
+	g, fakeGKE := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 4 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+
+	fakeSubscriber := &subscriber.Fake{}
+	events := &Events{
+		Log:           testLog,
+		Subscriber:    fakeSubscriber,
+		Syncer:        g,
+		Leader:        alwaysLeader{},
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		events.receive(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return g, fakeGKE, fakeSubscriber
+}
+
+// publish publishes a cluster notification and waits for it to be acked.
+func publish(t *testing.T, fakeSubscriber *subscriber.Fake, location string, name string) {
+	fakeSubscriber.Publish(subscriber.Message{
+		ID: name,
+		Attributes: map[string]string{
+			"project_id":       "project-a",
+			"cluster_location": location,
+			"cluster_name":     name,
+			"type_url":         "type.googleapis.com/google.container.v1beta1.UpgradeEvent",
+		},
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fakeSubscriber.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the event of %s to be acked, nacked %d times", name, len(fakeSubscriber.Nacked()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func devCluster(location string, name string, createTime time.Time) *containerpb.Cluster {
+	return &containerpb.Cluster{
+		Name:           name,
+		Location:       location,
+		CreateTime:     createTime.UTC().Format(time.RFC3339),
+		Status:         containerpb.Cluster_RUNNING,
+		ResourceLabels: map[string]string{"env": "dev"},
+	}
+}
+
+func TestSyncClusterAddsRecreatesAndRemoves(t *testing.T) {
+	g, fakeGKE, fakeSubscriber := newEventsTest(t)
+	ctx := context.Background()
+
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fakeGKE.put("project-a", devCluster("us-central1", "dev", createTime))
+	publish(t, fakeSubscriber, "us-central1", "dev")
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatalf("expected the created cluster to be added: %s", err)
+	}
+	if !record.CreateDate.Equal(createTime) || !record.ExpirationDate.Equal(createTime.Add(4*time.Hour)) {
+		t.Errorf("expected the cluster to expire 4h after %s, got created %s, expires %s", createTime, record.CreateDate, record.ExpirationDate)
+	}
+
+	err = g.ClusterStore.UpdateIgnore(ctx, provider.KindGKECluster, "dev", true, time.Time{}, "testing", "user", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recreateTime := createTime.Add(30 * time.Minute)
+	fakeGKE.put("project-a", devCluster("us-central1", "dev", recreateTime))
+	publish(t, fakeSubscriber, "us-central1", "dev")
+
+	record, err = g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatalf("expected the recreated cluster to be kept: %s", err)
+	}
+	if record.ID != 1 || !record.CreateDate.Equal(recreateTime) || !record.ExpirationDate.Equal(recreateTime.Add(4*time.Hour)) {
+		t.Errorf("expected record 1 to start over at %s, got record %d created %s, expires %s", recreateTime, record.ID, record.CreateDate, record.ExpirationDate)
+	}
+	if !record.Ignore || record.IgnoreReason != "testing" {
+		t.Errorf("expected the ignore to carry over the recreation, got %t %q", record.Ignore, record.IgnoreReason)
+	}
+
+	fakeGKE.remove("project-a", "us-central1", "dev")
+	publish(t, fakeSubscriber, "us-central1", "dev")
+
+	_, err = g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != store.ErrNotFound {
+		t.Errorf("expected the deleted cluster to be removed, got %v", err)
+	}
+
+	events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev")
+	expected := []string{store.EventDiscovered, store.EventUpdated, store.EventRemoved}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+}
+
+func TestSyncClusterIgnoresClustersThatDontMatch(t *testing.T) {
+	g, fakeGKE, fakeSubscriber := newEventsTest(t)
+	ctx := context.Background()
+
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fakeGKE.put("project-a", devCluster("us-central1", "dev", createTime))
+	publish(t, fakeSubscriber, "us-central1", "dev")
+
+	t.Run("a cluster of the same name in another location", func(t *testing.T) {
+		fakeGKE.put("project-a", devCluster("europe-west1", "dev", createTime.Add(time.Minute)))
+		publish(t, fakeSubscriber, "europe-west1", "dev")
+
+		fakeGKE.remove("project-a", "europe-west1", "dev")
+		publish(t, fakeSubscriber, "europe-west1", "dev")
+
+		record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+		if err != nil {
+			t.Fatalf("expected the tracked cluster to be kept: %s", err)
+		}
+		if !record.CreateDate.Equal(createTime) {
+			t.Errorf("expected the tracked cluster to be left alone, got created %s", record.CreateDate)
+		}
+	})
+
+	t.Run("a cluster that doesn't match the label filters", func(t *testing.T) {
+		cluster := devCluster("us-central1", "prod", createTime)
+		cluster.ResourceLabels = map[string]string{"env": "prod"}
+		fakeGKE.put("project-a", cluster)
+		publish(t, fakeSubscriber, "us-central1", "prod")
+
+		_, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "prod")
+		if err != store.ErrNotFound {
+			t.Errorf("expected the cluster not to be added, got %v", err)
+		}
+	})
+
+	t.Run("a cluster that no longer matches the label filters", func(t *testing.T) {
+		cluster := devCluster("us-central1", "dev", createTime)
+		cluster.ResourceLabels = map[string]string{"env": "prod"}
+		fakeGKE.put("project-a", cluster)
+		publish(t, fakeSubscriber, "us-central1", "dev")
+
+		_, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+		if err != store.ErrNotFound {
+			t.Errorf("expected the cluster to be removed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	container "cloud.google.com/go/container/apiv1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// fakeClusterManager is an in-memory GKE API. Clusters are keyed by their
+// projects/*/locations/*/clusters/* name and the node pools resized by their
+// projects/*/locations/*/clusters/*/nodePools/* name. Operations are done
+// straight away.
+type fakeClusterManager struct {
+	containerpb.UnimplementedClusterManagerServer
+
+	mutex       sync.Mutex
+	clusters    map[string]*containerpb.Cluster
+	changed     map[string]*containerpb.Cluster
+	deleted     []string
+	sizes       map[string]int32
+	autoscaling map[string]*containerpb.NodePoolAutoscaling
+}
+
+// newFakeGKE serves a fakeClusterManager for the test and returns a client of
+// it.
+func newFakeGKE(t *testing.T) (*fakeClusterManager, *container.ClusterManagerClient) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeClusterManager{
+		clusters:    map[string]*containerpb.Cluster{},
+		changed:     map[string]*containerpb.Cluster{},
+		sizes:       map[string]int32{},
+		autoscaling: map[string]*containerpb.NodePoolAutoscaling{},
+	}
+	server := grpc.NewServer()
+	containerpb.RegisterClusterManagerServer(server, fake)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	client, err := container.NewClusterManagerClient(context.Background(),
+		option.WithEndpoint(listener.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return fake, client
+}
+
+// put adds or replaces a cluster, filling in its self link.
+func (f *fakeClusterManager) put(project string, cluster *containerpb.Cluster) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	name := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", project, cluster.Location, cluster.Name)
+	cluster.SelfLink = "https://container.googleapis.com/v1/" + name
+	f.clusters[name] = cluster
+}
+
+// change makes GetCluster return the cluster as changed while ListClusters
+// still lists it as it was, as if it changed between the two calls.
+func (f *fakeClusterManager) change(project string, location string, name string, changed *containerpb.Cluster) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.changed[fmt.Sprintf("projects/%s/locations/%s/clusters/%s", project, location, name)] = changed
+}
+
+func (f *fakeClusterManager) remove(project string, location string, name string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.clusters, fmt.Sprintf("projects/%s/locations/%s/clusters/%s", project, location, name))
+}
+
+func (f *fakeClusterManager) GetCluster(ctx context.Context, req *containerpb.GetClusterRequest) (*containerpb.Cluster, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if cluster, found := f.changed[req.Name]; found {
+		return cluster, nil
+	}
+
+	cluster, found := f.clusters[req.Name]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.Name)
+	}
+
+	return cluster, nil
+}
+
+// ListClusters lists the clusters of the project in every location, sorted by
+// name.
+func (f *fakeClusterManager) ListClusters(ctx context.Context, req *containerpb.ListClustersRequest) (*containerpb.ListClustersResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	project := strings.TrimSuffix(req.Parent, "locations/-")
+	names := []string{}
+	for name := range f.clusters {
+		if strings.HasPrefix(name, project) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	response := &containerpb.ListClustersResponse{}
+	for _, name := range names {
+		response.Clusters = append(response.Clusters, f.clusters[name])
+	}
+
+	return response, nil
+}
+
+// DeleteCluster records the deletion and leaves the cluster in place, as GKE
+// does until the delete operation is done.
+func (f *fakeClusterManager) DeleteCluster(ctx context.Context, req *containerpb.DeleteClusterRequest) (*containerpb.Operation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, found := f.clusters[req.Name]; !found {
+		return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.Name)
+	}
+	f.deleted = append(f.deleted, req.Name)
+
+	return &containerpb.Operation{Name: "delete", Status: containerpb.Operation_RUNNING}, nil
+}
+
+// deletedClusters returns the names of the clusters deleted so far.
+func (f *fakeClusterManager) deletedClusters() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.deleted...)
+}
+
+func (f *fakeClusterManager) SetNodePoolSize(ctx context.Context, req *containerpb.SetNodePoolSizeRequest) (*containerpb.Operation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sizes[req.Name] = req.NodeCount
+
+	return &containerpb.Operation{Name: "set-size", Status: containerpb.Operation_DONE}, nil
+}
+
+func (f *fakeClusterManager) SetNodePoolAutoscaling(ctx context.Context, req *containerpb.SetNodePoolAutoscalingRequest) (*containerpb.Operation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.autoscaling[req.Name] = req.Autoscaling
+
+	return &containerpb.Operation{Name: "set-autoscaling", Status: containerpb.Operation_DONE}, nil
+}
+
+// nodePool returns the size and autoscaling the node pool was last set to.
+func (f *fakeClusterManager) nodePool(name string) (int32, *containerpb.NodePoolAutoscaling) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.sizes[name], f.autoscaling[name]
+}
+
+// SetLabels replaces the cluster's labels.
+func (f *fakeClusterManager) SetLabels(ctx context.Context, req *containerpb.SetLabelsRequest) (*containerpb.Operation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster, found := f.clusters[req.Name]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "cluster %s not found", req.Name)
+	}
+	cluster.ResourceLabels = map[string]string{}
+	for k, v := range req.ResourceLabels {
+		cluster.ResourceLabels[k] = v
+	}
+
+	return &containerpb.Operation{Name: "set-labels", Status: containerpb.Operation_DONE}, nil
+}
+
+// labels returns a copy of the cluster's labels.
+func (f *fakeClusterManager) labels(name string) map[string]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	labels := map[string]string{}
+	for k, v := range f.clusters[name].ResourceLabels {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// setLabel sets a label on the cluster as if it was edited in GKE, removing it
+// if the value is empty.
+func (f *fakeClusterManager) setLabel(name string, key string, value string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster := f.clusters[name]
+	if value == "" {
+		delete(cluster.ResourceLabels, key)
+		return
+	}
+	cluster.ResourceLabels[key] = value
+}
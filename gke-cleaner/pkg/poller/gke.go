@@ -2,11 +2,22 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/cost"
+	"github.com/christianang/gke-cleaner/pkg/idle"
+	"github.com/christianang/gke-cleaner/pkg/janitor"
+	"github.com/christianang/gke-cleaner/pkg/nodepool"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/provider"
 	"github.com/christianang/gke-cleaner/pkg/store"
 	"github.com/go-logr/logr"
 
@@ -14,196 +25,735 @@ import (
 	containerpb "google.golang.org/genproto/googleapis/container/v1"
 )
 
-type GKE struct {
-	Log          logr.Logger
-	Client       *container.ClusterManagerClient
-	ClusterStore *store.Cluster
+var ErrNotLeader = errors.New("this instance is not the leader")
+
+type LeaderChecker interface {
+	IsLeader() bool
+}
 
-	Project                string
-	PollInterval           time.Duration
-	LifetimeDuration       time.Duration
-	ResourceLabelFilterMap []string
+type GKE struct {
+	Log           logr.Logger
+	Client        *container.ClusterManagerClient
+	ClusterStore  *store.Cluster
+	EventStore    *store.Event
+	BreakerStore  *store.Breaker
+	NodePoolStore *store.NodePool
+	NodePools     nodepool.Sizer
+	SweepStore    *store.Sweep
+	DeletionStore *store.Deletion
+	Janitor       janitor.Janitor
+	Notifier      *notify.Notifier
+	Owners        *owner.Resolver
+	Idle          *idle.Detector
+	Pricing       *cost.Pricing
+
+	Policy *policy.Store
+	Leader LeaderChecker
+
+	Projects     []string
+	PollInterval time.Duration
+	PollJitter   float64
+
+	// CallTimeout bounds each call to the GKE API and ShutdownTimeout how
+	// long a poll in progress is waited for on shutdown.
+	CallTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	mutex         sync.Mutex
+	policyVersion int
+
+	restoreMutex sync.Mutex
+	restoring    map[string]bool
 }
 
 func (g *GKE) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
-	close(ready)
-	ctx, cancel := context.WithCancel(context.Background())
-	for {
-		select {
-		case <-signals:
-			cancel()
-			return nil
-		case <-time.After(g.PollInterval):
-			g.Log.V(1).Info("Polling")
-			if err := g.syncGKEClusters(ctx); err != nil {
-				g.Log.Error(err, "Failed to sync gke clusters")
-				continue
-			}
+	return pollLoop{
+		log:             g.Log,
+		leader:          g.Leader,
+		interval:        g.PollInterval,
+		jitter:          g.PollJitter,
+		shutdownTimeout: g.ShutdownTimeout,
+		poll:            g.poll,
+	}.run(signals, ready)
+}
 
-			if err := g.cleanupExpiredClusters(ctx); err != nil {
-				g.Log.Error(err, "Failed to cleanup expired clusters")
-				continue
-			}
+func (g *GKE) Sync(ctx context.Context) error {
+	if !g.Leader.IsLeader() {
+		return ErrNotLeader
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.syncGKEClusters(ctx, g.applyPolicy())
+}
+
+func (g *GKE) poll(ctx context.Context) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	policy := g.applyPolicy()
+
+	if err := g.syncGKEClusters(ctx, policy); err != nil {
+		g.Log.Error(err, "Failed to sync gke clusters")
+		return
+	}
+
+	if err := g.detectIdle(ctx, policy); err != nil {
+		g.Log.Error(err, "Failed to detect idle clusters")
+		return
+	}
+
+	if err := g.cleanupExpiredClusters(ctx, policy); err != nil {
+		logPollError(g.Log, err, "Failed to cleanup expired clusters")
+		return
+	}
+
+	if err := g.sweepOrphans(ctx, policy); err != nil {
+		logPollError(g.Log, err, "Failed to sweep orphaned resources")
+		return
+	}
+}
+
+func (g *GKE) applyPolicy() config.Policy {
+	return applyPolicy(g.Log, g.Policy, g.Notifier, &g.policyVersion)
+}
+
+func (g *GKE) records() recordKeeper {
+	return recordKeeper{
+		log:           g.Log,
+		clusterStore:  g.ClusterStore,
+		eventStore:    g.EventStore,
+		deletionStore: g.DeletionStore,
+		notifier:      g.Notifier,
+	}
+}
+
+func (g *GKE) recordEvent(ctx context.Context, clusterName string, eventType string, message string) {
+	recordEvent(ctx, g.Log, g.EventStore, g.Notifier, provider.KindGKECluster, clusterName, eventType, message)
+}
+
+func (g *GKE) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return callContext(ctx, g.CallTimeout)
+}
+
+func (g *GKE) deletionGuard() deletionGuard {
+	return deletionGuard{
+		log:          g.Log,
+		clusterStore: g.ClusterStore,
+		eventStore:   g.EventStore,
+		breakerStore: g.BreakerStore,
+		notifier:     g.Notifier,
+	}
+}
+
+func (g *GKE) listClusters(ctx context.Context) ([]gkeCluster, error) {
+	clusters := []gkeCluster{}
+	for _, project := range g.Projects {
+		callCtx, cancelCall := g.callContext(ctx)
+		response, err := g.Client.ListClusters(callCtx, &containerpb.ListClustersRequest{
+			Parent: fmt.Sprintf("projects/%s/locations/-", project),
+		})
+		cancelCall()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clusters in project %s: %s", project, err)
+		}
+
+		for _, cluster := range response.Clusters {
+			clusters = append(clusters, gkeCluster{Cluster: cluster, Project: project})
 		}
 	}
+
+	return clusters, nil
 }
 
-func (g *GKE) cleanupExpiredClusters(ctx context.Context) error {
-	expiredClusters, err := g.ClusterStore.ListExpired(ctx)
+func (g *GKE) cleanupExpiredClusters(ctx context.Context, policy config.Policy) error {
+	if !policy.Schedule.Allows(time.Now()) {
+		g.Log.V(1).Info("Outside the deletion windows, not deleting", "next", policy.Schedule.Next(time.Now()))
+		return nil
+	}
+
+	expiredClusters, err := g.ClusterStore.ListExpired(ctx, provider.KindGKECluster)
 	if err != nil {
 		return err
 	}
 
-	listClustersResponse, err := g.Client.ListClusters(ctx, &containerpb.ListClustersRequest{
-		Parent: fmt.Sprintf("projects/%s/locations/-", g.Project),
-	})
+	gkeClusters, err := g.listClusters(ctx)
 	if err != nil {
 		return err
 	}
 
+	dueClusters := []dueCluster{}
 	for _, cluster := range expiredClusters {
-		if cluster.Ignore {
+		if !awaitingAction(cluster, time.Now()) {
 			continue
 		}
 
-		location, err := g.getClusterLocation(listClustersResponse, cluster.Name)
+		gkeCluster, err := g.getCluster(gkeClusters, cluster)
 		if err != nil {
 			g.Log.Error(err, "Failed to get cluster location. Skipping.", "cluster", cluster.Name)
 			continue
 		}
 
-		_, err = g.Client.DeleteCluster(ctx, &containerpb.DeleteClusterRequest{
-			Name: fmt.Sprintf("projects/%s/locations/%s/clusters/%s", g.Project, location, cluster.Name),
+		switch gkeCluster.Status {
+		case containerpb.Cluster_STOPPING, containerpb.Cluster_PROVISIONING:
+			g.Log.Info("Cluster is not in a deletable state. Skipping.", "cluster", cluster.Name, "status", gkeCluster.Status.String())
+			continue
+		}
+
+		if protected, reason := policy.Protection.Protects(cluster.Name, gkeCluster.ResourceLabels); protected {
+			g.Log.V(1).Info("Cluster is protected. Skipping.", "cluster", cluster.Name, "reason", reason)
+			continue
+		}
+
+		dueClusters = append(dueClusters, dueCluster{record: cluster, cluster: gkeCluster})
+	}
+
+	if len(dueClusters) == 0 {
+		return nil
+	}
+
+	dueRecords := make([]store.ClusterRecord, 0, len(dueClusters))
+	for _, due := range dueClusters {
+		dueRecords = append(dueRecords, due.record)
+	}
+
+	allowed, limit, err := g.deletionGuard().allowance(ctx, policy.Safeguards, dueRecords)
+	if err != nil {
+		return err
+	}
+
+	for i, due := range dueClusters {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if i >= allowed {
+			g.records().deferred(ctx, len(dueClusters)-i, "clusters", limit)
+			break
+		}
+
+		cluster, gkeCluster := due.record, due.cluster
+
+		// The cluster list may be minutes old by now, so the cluster's identity
+		// and protection are checked again against the live cluster right
+		// before deleting it.
+		callCtx, cancelCall := g.callContext(ctx)
+		liveCluster, err := g.Client.GetCluster(callCtx, &containerpb.GetClusterRequest{
+			Name: clusterPath(gkeCluster),
 		})
+		cancelCall()
 		if err != nil {
-			g.Log.Error(err, "Failed to delete cluster. Skipping.", "cluster", cluster.Name)
+			g.Log.Error(err, "Failed to get cluster before deleting. Skipping.", "cluster", cluster.Name)
 			continue
 		}
-		g.Log.Info("Removed expired cluster", "cluster", cluster.Name)
+
+		live := gkeCluster
+		live.Cluster = liveCluster
+		if mismatch := identityMismatch(cluster, live, policy.LabelFilters); mismatch != "" {
+			g.Log.Info("Cluster changed since it was stored. Skipping.", "cluster", cluster.Name, "mismatch", mismatch)
+			g.recordEvent(ctx, cluster.Name, store.EventMismatch, fmt.Sprintf("deletion skipped, %s", mismatch))
+
+			err = g.resyncCluster(ctx, policy, live)
+			if err != nil {
+				g.Log.Error(err, "Failed to resync cluster", "cluster", cluster.Name)
+			}
+			continue
+		}
+
+		if protected, reason := policy.Protection.Protects(liveCluster.Name, liveCluster.ResourceLabels); protected {
+			g.Log.Info("Cluster became protected. Skipping.", "cluster", cluster.Name, "reason", reason)
+			g.recordEvent(ctx, cluster.Name, store.EventProtected, fmt.Sprintf("deletion refused, cluster is protected: %s", reason))
+			continue
+		}
+
+		action := policy.ExpiryActionFor(cluster.ExpiryAction, liveCluster.ResourceLabels)
+
+		if policy.DryRun {
+			g.Log.Info("Dry run, not acting on expired cluster", "cluster", cluster.Name, "action", action)
+			continue
+		}
+
+		// Another instance may have taken over while this pass was running.
+		if !g.Leader.IsLeader() {
+			return ErrNotLeader
+		}
+
+		switch action {
+		case config.ActionScaleToZero:
+			err = g.scaleToZero(ctx, live)
+			if errors.Is(err, ErrNotLeader) {
+				return err
+			}
+			if err != nil {
+				g.Log.Error(err, "Failed to scale cluster to zero. Skipping.", "cluster", cluster.Name)
+				continue
+			}
+			g.Log.Info("Scaled expired cluster to zero", "cluster", cluster.Name)
+		case config.ActionNotifyOnly:
+			err = g.records().notifyOnly(ctx, cluster)
+			if err != nil {
+				g.Log.Error(err, "Failed to record action taken. Skipping.", "cluster", cluster.Name)
+				continue
+			}
+			g.Log.Info("Cluster expired, notifying only", "cluster", cluster.Name)
+		default:
+			var sweep store.SweepRecord
+			if policy.OrphanCleanup != config.OrphanCleanupOff {
+				sweep = g.sweepTarget(ctx, live)
+			}
+
+			callCtx, cancelCall := g.callContext(ctx)
+			op, err := g.Client.DeleteCluster(callCtx, &containerpb.DeleteClusterRequest{
+				Name: clusterPath(gkeCluster),
+			})
+			cancelCall()
+			if err != nil && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				g.Log.Error(err, "Failed to delete cluster. Skipping.", "cluster", cluster.Name)
+				g.records().deleteFailed(cluster, err)
+				continue
+			}
+			g.Log.Info("Removed expired cluster", "cluster", cluster.Name)
+			g.records().deleted(cluster, fmt.Sprintf("expired at %s", cluster.ExpirationDate.Format(time.RFC3339)))
+
+			if policy.OrphanCleanup != config.OrphanCleanupOff {
+				sweep.Operation = op.Name
+				err = g.SweepStore.Insert(bookkeepingContext(), sweep)
+				if err != nil {
+					g.Log.Error(err, "Failed to record sweep", "cluster", cluster.Name)
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
-func (g *GKE) getClusterLocation(response *containerpb.ListClustersResponse, name string) (string, error) {
-	for _, cluster := range response.Clusters {
-		if name == cluster.Name {
-			return cluster.Location, nil
-		}
+// getCluster returns the gke cluster the record tracks.
+func (g *GKE) getCluster(clusters []gkeCluster, record store.ClusterRecord) (gkeCluster, error) {
+	cluster, found := trackedCluster(clusters, record)
+	if !found {
+		return gkeCluster{}, fmt.Errorf("couldn't find cluster: %s", record.Name)
 	}
 
-	return "", fmt.Errorf("couldn't find cluster: %s", name)
+	return cluster, nil
 }
 
-func (g *GKE) syncGKEClusters(ctx context.Context) error {
-	response, err := g.Client.ListClusters(ctx, &containerpb.ListClustersRequest{
-		Parent: fmt.Sprintf("projects/%s/locations/-", g.Project),
-	})
+func (g *GKE) syncGKEClusters(ctx context.Context, policy config.Policy) error {
+	gkeClusters, err := g.listClusters(ctx)
 	if err != nil {
 		return err
 	}
 
-	knownClusters, err := g.ClusterStore.List(ctx)
+	knownClusters, err := g.ClusterStore.ListByKind(ctx, provider.KindGKECluster)
 	if err != nil {
 		return err
 	}
 
-	clusters := filter(response.Clusters, g.ResourceLabelFilterMap)
+	clusters := filter(gkeClusters, policy.LabelFilters)
 
-	addedClusters, removedClusters, updatedClusters := diffClusters(clusters, knownClusters)
+	addedClusters, removedClusters, updatedClusters, shadowedClusters := diffClusters(clusters, knownClusters)
+	statusChanges := diffStatuses(clusters, knownClusters)
+	relabelledClusters := diffLabels(clusters, knownClusters)
+	unidentifiedClusters := diffIdentities(clusters, knownClusters)
 
-	for _, cluster := range addedClusters {
-		g.Log.Info("Discovered", "cluster", cluster)
-		createTime, err := time.Parse(time.RFC3339, cluster.GetCreateTime())
+	// Removals go first, so a cluster that was replaced by one of the same
+	// name elsewhere can be added in the same poll.
+	for _, cluster := range removedClusters {
+		err = g.removeCluster(ctx, cluster.GetName(), "no longer found in gke")
 		if err != nil {
 			return err
 		}
+	}
 
-		err = g.ClusterStore.Insert(ctx, cluster.GetName(), createTime, createTime.Add(g.LifetimeDuration), false)
+	for _, cluster := range addedClusters {
+		err = g.addCluster(ctx, policy, cluster)
 		if err != nil {
 			return err
 		}
 	}
 
 	for _, cluster := range updatedClusters {
-		g.Log.Info("Updated", "cluster", cluster)
-		createTime, err := time.Parse(time.RFC3339, cluster.GetCreateTime())
+		err = g.recreateCluster(ctx, policy, cluster)
 		if err != nil {
 			return err
 		}
+	}
+
+	for _, cluster := range shadowedClusters {
+		g.Log.V(1).Info("Ignoring a cluster with the name of a tracked cluster elsewhere", "cluster", cluster.GetName(), "project", cluster.Project, "location", cluster.Location)
+	}
 
-		g.Log.Info("Update cluster", "clusterName", cluster.GetName(), "createTime", createTime, "expirationDate", createTime.Add(g.LifetimeDuration))
-		err = g.ClusterStore.UpdateCreateAndExpirationDate(ctx, cluster.GetName(), createTime, createTime.Add(g.LifetimeDuration))
+	for _, change := range statusChanges {
+		expirationDate := change.record.ExpirationDate
+		if isUnhealthy(change.cluster.Status) && policy.UnhealthyLifetime > 0 {
+			unhealthyExpirationDate := time.Now().Add(policy.UnhealthyLifetime)
+			if unhealthyExpirationDate.Before(expirationDate) {
+				expirationDate = unhealthyExpirationDate
+			}
+		} else if !isUnhealthy(change.cluster.Status) && isUnhealthy(parseStatus(change.record.Status)) {
+			healthyExpirationDate := change.record.CreateDate.Add(policy.LifetimeFor(change.cluster.ResourceLabels))
+			if healthyExpirationDate.After(expirationDate) {
+				expirationDate = healthyExpirationDate
+			}
+		}
+
+		g.Log.Info("Status changed", "cluster", change.cluster.GetName(), "from", change.record.Status, "to", change.cluster.Status.String())
+		err = g.ClusterStore.UpdateStatus(ctx, provider.KindGKECluster, change.cluster.GetName(), change.cluster.Status.String(), expirationDate)
 		if err != nil {
 			return err
 		}
+		g.recordEvent(ctx, change.cluster.GetName(), store.EventStatus, fmt.Sprintf("status %s -> %s, expires at %s", change.record.Status, change.cluster.Status, expirationDate.Format(time.RFC3339)))
 	}
 
-	for _, cluster := range removedClusters {
-		g.Log.Info("Detected removal", "cluster", cluster)
-		err = g.ClusterStore.Delete(ctx, cluster.GetName())
+	for _, cluster := range unidentifiedClusters {
+		err = g.ClusterStore.UpdateIdentity(ctx, provider.KindGKECluster, cluster.GetName(), cluster.SelfLink, cluster.Project, cluster.Location)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, cluster := range relabelledClusters {
+		g.Log.V(1).Info("Labels changed", "cluster", cluster.GetName())
+		err = g.ClusterStore.UpdateLabels(ctx, provider.KindGKECluster, cluster.GetName(), cluster.ResourceLabels)
 		if err != nil {
 			return err
 		}
 	}
 
+	err = g.resolveOwners(ctx, clusters)
+	if err != nil {
+		return err
+	}
+
+	err = g.estimateCosts(ctx, clusters)
+	if err != nil {
+		return err
+	}
+
+	return g.syncStateLabels(ctx, policy, clusters, knownClusters)
+}
+
+func (g *GKE) addCluster(ctx context.Context, policy config.Policy, cluster gkeCluster) error {
+	g.Log.Info("Discovered", "cluster", cluster.GetName(), "project", cluster.Project)
+	record, err := g.newRecord(ctx, policy, cluster)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("status %s, expires at %s", cluster.Status, record.ExpirationDate.Format(time.RFC3339))
+	record, adopted := adoptStateLabels(record, cluster.ResourceLabels, time.Now())
+	if len(adopted) > 0 {
+		g.Log.Info("Adopted state labels", "cluster", cluster.GetName(), "labels", adopted)
+		message = fmt.Sprintf("status %s, expires at %s, ignored %t, adopted from the %s labels", cluster.Status, record.ExpirationDate.Format(time.RFC3339), record.Ignore, strings.Join(adopted, " and "))
+	}
+
+	return g.records().discover(ctx, record, message)
+}
+
+func (g *GKE) recreateCluster(ctx context.Context, policy config.Policy, cluster gkeCluster) error {
+	record, err := g.newRecord(ctx, policy, cluster)
+	if err != nil {
+		return err
+	}
+
+	g.Log.Info("Update cluster", "clusterName", cluster.GetName(), "createTime", record.CreateDate, "expirationDate", record.ExpirationDate)
+	err = g.NodePoolStore.DeleteByCluster(ctx, provider.KindGKECluster, cluster.GetName())
+	if err != nil {
+		return err
+	}
+
+	return g.records().recreate(ctx, record)
+}
+
+// newRecord is the record of a newly seen cluster.
+func (g *GKE) newRecord(ctx context.Context, policy config.Policy, cluster gkeCluster) (store.ClusterRecord, error) {
+	createTime, err := time.Parse(time.RFC3339, cluster.GetCreateTime())
+	if err != nil {
+		return store.ClusterRecord{}, err
+	}
+
+	clusterOwner, ownerSource := g.resolveOwner(ctx, cluster, createTime)
+
+	return store.ClusterRecord{
+		Kind:           provider.KindGKECluster,
+		Name:           cluster.GetName(),
+		CreateDate:     createTime,
+		ExpirationDate: expirationFor(policy, cluster, createTime),
+		Status:         cluster.Status.String(),
+		Labels:         cluster.ResourceLabels,
+		SelfLink:       cluster.SelfLink,
+		Project:        cluster.Project,
+		Location:       cluster.Location,
+		Owner:          clusterOwner,
+		OwnerSource:    ownerSource,
+	}, nil
+}
+
+func (g *GKE) removeCluster(ctx context.Context, name string, message string) error {
+	g.Log.Info("Detected removal", "cluster", name)
+	err := g.ClusterStore.Delete(ctx, provider.KindGKECluster, name)
+	if err != nil {
+		return err
+	}
+	err = g.NodePoolStore.DeleteByCluster(ctx, provider.KindGKECluster, name)
+	if err != nil {
+		return err
+	}
+	g.recordEvent(ctx, name, store.EventRemoved, message)
+
 	return nil
 }
 
-func filter(clusters []*containerpb.Cluster, filters []string) []*containerpb.Cluster {
-	filteredClusters := []*containerpb.Cluster{}
+// expirationFor returns when a newly seen cluster expires. Clusters in an
+// unhealthy state expire after the unhealthy lifetime if that is sooner.
+func expirationFor(policy config.Policy, cluster gkeCluster, createTime time.Time) time.Time {
+	expirationDate := createTime.Add(policy.LifetimeFor(cluster.ResourceLabels))
+	if isUnhealthy(cluster.Status) && policy.UnhealthyLifetime > 0 {
+		unhealthyExpirationDate := time.Now().Add(policy.UnhealthyLifetime)
+		if unhealthyExpirationDate.Before(expirationDate) {
+			return unhealthyExpirationDate
+		}
+	}
+
+	return expirationDate
+}
+
+func isUnhealthy(status containerpb.Cluster_Status) bool {
+	return status == containerpb.Cluster_ERROR || status == containerpb.Cluster_DEGRADED
+}
+
+func parseStatus(status string) containerpb.Cluster_Status {
+	return containerpb.Cluster_Status(containerpb.Cluster_Status_value[status])
+}
+
+func filter(clusters []gkeCluster, filters []string) []gkeCluster {
+	filteredClusters := []gkeCluster{}
 	for _, cluster := range clusters {
-		for _, filter := range filters {
-			k, v := parseFilter(filter)
-			if cluster.ResourceLabels[k] == v {
-				filteredClusters = append(filteredClusters, cluster)
-				break
-			}
+		if matchesFilters(cluster.ResourceLabels, filters) {
+			filteredClusters = append(filteredClusters, cluster)
 		}
 	}
 
 	return filteredClusters
 }
 
+func matchesFilters(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		k, v := parseFilter(filter)
+		if labels[k] == v {
+			return true
+		}
+	}
+
+	return false
+}
+
 func parseFilter(filter string) (string, string) {
-	s := strings.Split(filter, "=")
+	s := strings.SplitN(filter, "=", 2)
 	return s[0], s[1]
 }
 
-func diffClusters(gkeClusters []*containerpb.Cluster, knownClusters []store.ClusterRecord) ([]Cluster, []Cluster, []Cluster) {
-	added := []Cluster{}
+// diffClusters returns the gke clusters that are not tracked yet, the
+// records of clusters no longer in gke and the clusters that were recreated
+// since they were stored. Records are only unique by name, so a cluster with
+// the name of a cluster tracked in another project or location is shadowed
+// and left untracked.
+func diffClusters(gkeClusters []gkeCluster, knownClusters []store.ClusterRecord) ([]gkeCluster, []Cluster, []gkeCluster, []gkeCluster) {
+	added := []gkeCluster{}
 	removed := []Cluster{}
-	updated := []Cluster{}
+	updated := []gkeCluster{}
+	shadowed := []gkeCluster{}
+
+	tracked := map[string]bool{}
+	names := map[string]bool{}
+	for i := range knownClusters {
+		cluster, found := trackedCluster(gkeClusters, knownClusters[i])
+		if !found {
+			removed = append(removed, &knownClusters[i])
+			continue
+		}
+
+		tracked[clusterPath(cluster)] = true
+		names[cluster.Name] = true
+		if !sameCreateTime(&knownClusters[i], cluster) {
+			updated = append(updated, cluster)
+		}
+	}
 
-	gkeClusterMap := map[string]*containerpb.Cluster{}
 	for _, cluster := range gkeClusters {
-		gkeClusterMap[cluster.Name] = cluster
+		switch {
+		case tracked[clusterPath(cluster)]:
+		case names[cluster.Name]:
+			shadowed = append(shadowed, cluster)
+		default:
+			added = append(added, cluster)
+			names[cluster.Name] = true
+		}
 	}
 
-	knownClusterMap := map[string]*store.ClusterRecord{}
-	for _, cluster := range knownClusters {
-		knownClusterMap[cluster.Name] = &cluster
+	return added, removed, updated, shadowed
+}
+
+// tracks reports whether the record is of the gke cluster. Cluster names are
+// only unique within a project and location, so the record's project and
+// location must match, or its self link for a record stored before they
+// were. A record stored before its self link was is matched by name.
+func tracks(record store.ClusterRecord, cluster gkeCluster) bool {
+	switch {
+	case record.Name != cluster.Name:
+		return false
+	case record.Project != "":
+		return record.Project == cluster.Project && record.Location == cluster.Location
+	case record.SelfLink != "":
+		return record.SelfLink == cluster.SelfLink
 	}
 
-	for clusterName, cluster := range gkeClusterMap {
-		if _, found := knownClusterMap[clusterName]; !found {
-			added = append(added, cluster)
+	return true
+}
+
+// trackedCluster returns the gke cluster the record tracks.
+func trackedCluster(clusters []gkeCluster, record store.ClusterRecord) (gkeCluster, bool) {
+	for _, cluster := range clusters {
+		if tracks(record, cluster) {
+			return cluster, true
 		}
 	}
 
-	for clusterName, cluster := range knownClusterMap {
-		c, found := gkeClusterMap[clusterName]
+	return gkeCluster{}, false
+}
+
+// identityMismatch describes how a live cluster differs from the stored
+// record of it, or returns "" if it is the same cluster.
+func identityMismatch(record store.ClusterRecord, live gkeCluster, labelFilters []string) string {
+	if !sameCreateTime(&record, live) {
+		return fmt.Sprintf("create time %s does not match stored %s", live.GetCreateTime(), record.GetCreateTime())
+	}
+	if record.SelfLink != "" && record.SelfLink != live.SelfLink {
+		return fmt.Sprintf("self link %s does not match stored %s", live.SelfLink, record.SelfLink)
+	}
+	if len(filter([]gkeCluster{live}, labelFilters)) == 0 {
+		return "cluster no longer matches the label filters"
+	}
+
+	return ""
+}
+
+// resyncCluster brings the stored record of a single cluster up to date with
+// the live cluster.
+func (g *GKE) resyncCluster(ctx context.Context, policy config.Policy, live gkeCluster) error {
+	if len(filter([]gkeCluster{live}, policy.LabelFilters)) == 0 {
+		return g.removeCluster(ctx, live.GetName(), "no longer matches the label filters")
+	}
+
+	return g.recreateCluster(ctx, policy, live)
+}
+
+func clusterPath(cluster gkeCluster) string {
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", cluster.Project, cluster.Location, cluster.Name)
+}
+
+// diffLabels returns the known clusters whose labels in gke differ from the
+// stored labels.
+func diffLabels(gkeClusters []gkeCluster, knownClusters []store.ClusterRecord) []gkeCluster {
+	changed := []gkeCluster{}
+
+	for _, record := range knownClusters {
+		cluster, found := trackedCluster(gkeClusters, record)
 		if !found {
-			removed = append(removed, c)
-		} else if cluster.GetCreateTime() != c.GetCreateTime() {
-			updated = append(updated, c)
+			continue
+		}
+
+		if !sameLabels(record.Labels, cluster.ResourceLabels) {
+			changed = append(changed, cluster)
+		}
+	}
+
+	return changed
+}
+
+func sameLabels(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
 		}
 	}
 
-	return added, removed, updated
+	return true
+}
+
+// diffIdentities returns the known clusters that were stored before their
+// self link, project and location were recorded.
+func diffIdentities(gkeClusters []gkeCluster, knownClusters []store.ClusterRecord) []gkeCluster {
+	unidentified := []gkeCluster{}
+
+	for _, record := range knownClusters {
+		if record.SelfLink != "" && record.Project != "" {
+			continue
+		}
+
+		cluster, found := trackedCluster(gkeClusters, record)
+		if found && sameCreateTime(&record, cluster) {
+			unidentified = append(unidentified, cluster)
+		}
+	}
+
+	return unidentified
+}
+
+type dueCluster struct {
+	record  store.ClusterRecord
+	cluster gkeCluster
+}
+
+type statusChange struct {
+	cluster gkeCluster
+	record  store.ClusterRecord
+}
+
+// diffStatuses returns the known clusters whose status in gke differs from
+// the stored status. Recreated clusters are left to diffClusters.
+func diffStatuses(gkeClusters []gkeCluster, knownClusters []store.ClusterRecord) []statusChange {
+	changes := []statusChange{}
+
+	for i := range knownClusters {
+		record := knownClusters[i]
+		cluster, found := trackedCluster(gkeClusters, record)
+		if !found || !sameCreateTime(&record, cluster) {
+			continue
+		}
+
+		if record.Status != cluster.Status.String() {
+			changes = append(changes, statusChange{cluster: cluster, record: record})
+		}
+	}
+
+	return changes
+}
+
+func sameCreateTime(a Cluster, b Cluster) bool {
+	aTime, aErr := time.Parse(time.RFC3339, a.GetCreateTime())
+	bTime, bErr := time.Parse(time.RFC3339, b.GetCreateTime())
+	if aErr != nil || bErr != nil {
+		return a.GetCreateTime() == b.GetCreateTime()
+	}
+
+	return aTime.Equal(bTime)
 }
 
 type Cluster interface {
 	GetName() string
 	GetCreateTime() string
 }
+
+type gkeCluster struct {
+	*containerpb.Cluster
+	Project string
+}
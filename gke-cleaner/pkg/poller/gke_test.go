@@ -0,0 +1,393 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/schedule"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+func testCluster(name string, createTime time.Time, status containerpb.Cluster_Status) *containerpb.Cluster {
+	return &containerpb.Cluster{
+		Name:           name,
+		Location:       "us-central1-a",
+		CreateTime:     createTime.UTC().Format(time.RFC3339),
+		Status:         status,
+		ResourceLabels: map[string]string{"env": "dev"},
+	}
+}
+
+func getRecord(t *testing.T, gke *GKE, name string) store.ClusterRecord {
+	record, err := gke.ClusterStore.Get(context.Background(), provider.KindGKECluster, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestSyncTracksStatus(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		UnhealthyLifetime:       time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	ctx := context.Background()
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+	err := gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := getRecord(t, gke, "dev")
+	if record.Status != "RUNNING" || !record.ExpirationDate.Equal(createTime.Add(8*time.Hour)) {
+		t.Errorf("expected a running cluster expiring after its lifetime, got %+v", record)
+	}
+
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_ERROR))
+	err = gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record = getRecord(t, gke, "dev")
+	if record.Status != "ERROR" || record.ExpirationDate.After(time.Now().Add(time.Hour)) || record.ExpirationDate.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("expected an unhealthy cluster expiring after the unhealthy lifetime, got %+v", record)
+	}
+
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+	err = gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record = getRecord(t, gke, "dev")
+	if record.Status != "RUNNING" || !record.ExpirationDate.Equal(createTime.Add(8*time.Hour)) {
+		t.Errorf("expected a recovered cluster to get its lifetime back, got %+v", record)
+	}
+
+	expected := []string{store.EventDiscovered, store.EventStatus, store.EventStatus}
+	if types := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected events %q, got %q", expected, types)
+	}
+}
+
+func TestSyncExpiresUnhealthyClustersSooner(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		UnhealthyLifetime:       time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	createTime := time.Now().Add(-7*time.Hour - 30*time.Minute).Truncate(time.Second)
+
+	fake.put("project-a", testCluster("late", createTime, containerpb.Cluster_DEGRADED))
+	fake.put("project-a", testCluster("new", time.Now(), containerpb.Cluster_ERROR))
+	err := gke.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record := getRecord(t, gke, "late"); !record.ExpirationDate.Equal(createTime.Add(8 * time.Hour)) {
+		t.Errorf("expected the sooner lifetime expiration to be kept, got %s", record.ExpirationDate)
+	}
+	if record := getRecord(t, gke, "new"); record.ExpirationDate.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected the unhealthy lifetime expiration, got %s", record.ExpirationDate)
+	}
+}
+
+func TestPollSkipsClustersThatAreNotDeletable(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	createTime := time.Now().Add(-48 * time.Hour)
+
+	fake.put("project-a", testCluster("running", createTime, containerpb.Cluster_RUNNING))
+	fake.put("project-a", testCluster("stopping", createTime, containerpb.Cluster_STOPPING))
+	fake.put("project-a", testCluster("provisioning", createTime, containerpb.Cluster_PROVISIONING))
+	gke.poll(context.Background())
+
+	expected := []string{"projects/project-a/locations/us-central1-a/clusters/running"}
+	if deleted := fake.deletedClusters(); !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected only the running cluster to be deleted, got %q", deleted)
+	}
+}
+
+func TestPollDryRun(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+		DryRun:                  true,
+	})
+
+	fake.put("project-a", testCluster("dev", time.Now().Add(-48*time.Hour), containerpb.Cluster_RUNNING))
+	gke.poll(context.Background())
+
+	if deleted := fake.deletedClusters(); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted in a dry run, got %q", deleted)
+	}
+}
+
+func TestPollSkipsProtectedClusters(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+		Protection: config.Protection{
+			NameGlobs:      []string{"shared-*"},
+			LabelSelectors: []string{"team=data"},
+		},
+	})
+	createTime := time.Now().Add(-48 * time.Hour)
+
+	labelled := testCluster("labelled", createTime, containerpb.Cluster_RUNNING)
+	labelled.ResourceLabels[config.ProtectLabel] = "true"
+	selected := testCluster("selected", createTime, containerpb.Cluster_RUNNING)
+	selected.ResourceLabels["team"] = "data"
+
+	fake.put("project-a", labelled)
+	fake.put("project-a", selected)
+	fake.put("project-a", testCluster("shared-1", createTime, containerpb.Cluster_RUNNING))
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+	gke.poll(context.Background())
+
+	expected := []string{"projects/project-a/locations/us-central1-a/clusters/dev"}
+	if deleted := fake.deletedClusters(); !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected only the unprotected cluster to be deleted, got %q", deleted)
+	}
+
+	if record := getRecord(t, gke, "selected"); record.Labels["team"] != "data" {
+		t.Errorf("expected the cluster labels to be stored, got %+v", record.Labels)
+	}
+}
+
+func TestPollSkipsClustersWhoseIdentityChanged(t *testing.T) {
+	createTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	recreateTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := map[string]struct {
+		change         func(cluster *containerpb.Cluster)
+		mismatch       string
+		expectedEvents []string
+		check          func(t *testing.T, record store.ClusterRecord, err error)
+	}{
+		"create time": {
+			change:         func(cluster *containerpb.Cluster) { cluster.CreateTime = recreateTime.UTC().Format(time.RFC3339) },
+			mismatch:       "deletion skipped, create time " + recreateTime.UTC().Format(time.RFC3339) + " does not match stored",
+			expectedEvents: []string{store.EventDiscovered, store.EventMismatch, store.EventUpdated},
+			check: func(t *testing.T, record store.ClusterRecord, err error) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !record.CreateDate.Equal(recreateTime) || !record.ExpirationDate.Equal(recreateTime.Add(8*time.Hour)) {
+					t.Errorf("expected the record to be resynced with the recreated cluster, got %+v", record)
+				}
+			},
+		},
+		"self link": {
+			change: func(cluster *containerpb.Cluster) {
+				cluster.SelfLink = "https://container.googleapis.com/v1/projects/project-b/locations/us-central1-a/clusters/dev"
+			},
+			mismatch:       "deletion skipped, self link https://container.googleapis.com/v1/projects/project-b/locations/us-central1-a/clusters/dev does not match stored",
+			expectedEvents: []string{store.EventDiscovered, store.EventMismatch, store.EventUpdated},
+			check: func(t *testing.T, record store.ClusterRecord, err error) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if record.SelfLink != "https://container.googleapis.com/v1/projects/project-b/locations/us-central1-a/clusters/dev" {
+					t.Errorf("expected the record to be resynced with the new self link, got %+v", record)
+				}
+			},
+		},
+		"label filters": {
+			change:         func(cluster *containerpb.Cluster) { cluster.ResourceLabels = map[string]string{"env": "prod"} },
+			mismatch:       "deletion skipped, cluster no longer matches the label filters",
+			expectedEvents: []string{store.EventDiscovered, store.EventMismatch, store.EventRemoved},
+			check: func(t *testing.T, record store.ClusterRecord, err error) {
+				if !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("expected the record to be removed, got %+v, %v", record, err)
+				}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gke, fake := newTestGKE(t, config.Policy{
+				ClusterLifetimeDuration: 8 * time.Hour,
+				LabelFilters:            []string{"env=dev"},
+			})
+			ctx := context.Background()
+
+			fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+			err := gke.Sync(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changed := testCluster("dev", createTime, containerpb.Cluster_RUNNING)
+			changed.SelfLink = "https://container.googleapis.com/v1/projects/project-a/locations/us-central1-a/clusters/dev"
+			test.change(changed)
+			fake.change("project-a", "us-central1-a", "dev", changed)
+			gke.poll(ctx)
+
+			if deleted := fake.deletedClusters(); len(deleted) != 0 {
+				t.Errorf("expected the changed cluster not to be deleted, got %q", deleted)
+			}
+
+			if types := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(types, test.expectedEvents) {
+				t.Errorf("expected events %q, got %q", test.expectedEvents, types)
+			}
+
+			events, err := gke.EventStore.ListByCluster(ctx, provider.KindGKECluster, "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) < 2 || !strings.HasPrefix(events[1].Message, test.mismatch) {
+				t.Errorf("expected the mismatch to be described as %q, got %+v", test.mismatch, events)
+			}
+
+			record, err := gke.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+			test.check(t, record, err)
+		})
+	}
+}
+
+func TestSyncRecordsIdentitiesOfStoredClusters(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	ctx := context.Background()
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	err := gke.ClusterStore.Insert(ctx, store.ClusterRecord{
+		Kind:           provider.KindGKECluster,
+		Name:           "dev",
+		CreateDate:     createTime,
+		ExpirationDate: createTime.Add(8 * time.Hour),
+		Status:         "RUNNING",
+		Labels:         map[string]string{"env": "dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+	err = gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := getRecord(t, gke, "dev")
+	if record.SelfLink != "https://container.googleapis.com/v1/projects/project-a/locations/us-central1-a/clusters/dev" || record.Project != "project-a" || record.Location != "us-central1-a" {
+		t.Errorf("expected the self link, project and location to be recorded, got %+v", record)
+	}
+	if types := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); len(types) != 0 {
+		t.Errorf("expected no events, got %q", types)
+	}
+}
+
+func TestSyncTellsClustersOfTheSameNameApart(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	gke.Projects = []string{"project-a", "project-b"}
+	ctx := context.Background()
+	createTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+	fake.put("project-a", testCluster("dev", createTime, containerpb.Cluster_RUNNING))
+	err := gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := testCluster("dev", time.Now().Add(-time.Hour), containerpb.Cluster_ERROR)
+	other.Location = "europe-west1-b"
+	other.ResourceLabels["team"] = "data"
+	fake.put("project-b", other)
+	gke.poll(ctx)
+
+	record := getRecord(t, gke, "dev")
+	if record.Project != "project-a" || record.Status != "RUNNING" || !record.CreateDate.Equal(createTime) || record.Labels["team"] != "" {
+		t.Errorf("expected the record to keep tracking the cluster in project-a, got %+v", record)
+	}
+	expected := []string{"projects/project-a/locations/us-central1-a/clusters/dev"}
+	if deleted := fake.deletedClusters(); !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected only the tracked cluster to be deleted, got %q", deleted)
+	}
+
+	fake.remove("project-a", "us-central1-a", "dev")
+	err = gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record = getRecord(t, gke, "dev")
+	if record.Project != "project-b" || record.Location != "europe-west1-b" || record.Status != "ERROR" {
+		t.Errorf("expected the cluster in project-b to be tracked once the other is gone, got %+v", record)
+	}
+	expectedEvents := []string{store.EventDiscovered, store.EventDeleted, store.EventRemoved, store.EventDiscovered}
+	if types := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(types, expectedEvents) {
+		t.Errorf("expected events %q, got %q", expectedEvents, types)
+	}
+}
+
+func TestPollOutsideTheDeletionWindows(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+		Schedule: &schedule.Schedule{
+			Location: time.UTC,
+			Holidays: []schedule.Holiday{{Name: "Freeze", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}},
+		},
+	})
+
+	fake.put("project-a", testCluster("dev", time.Now().Add(-48*time.Hour), containerpb.Cluster_RUNNING))
+	gke.poll(context.Background())
+
+	if deleted := fake.deletedClusters(); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted outside the deletion windows, got %q", deleted)
+	}
+	if _, err := gke.ClusterStore.Get(context.Background(), provider.KindGKECluster, "dev"); err != nil {
+		t.Errorf("expected clusters to still be synced, got %v", err)
+	}
+}
+
+// leaderFor is the leader for the first checks of leadership only.
+type leaderFor struct {
+	checks int32
+}
+
+func (l *leaderFor) IsLeader() bool {
+	return atomic.AddInt32(&l.checks, -1) >= 0
+}
+
+func TestPollStopsWhenTheLeadershipIsLost(t *testing.T) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+	gke.Leader = &leaderFor{checks: 1}
+
+	createTime := time.Now().Add(-48 * time.Hour)
+	fake.put("project-a", testCluster("first", createTime, containerpb.Cluster_RUNNING))
+	fake.put("project-a", testCluster("second", createTime, containerpb.Cluster_RUNNING))
+	gke.poll(context.Background())
+
+	if deleted := fake.deletedClusters(); len(deleted) != 1 {
+		t.Errorf("expected the poll to stop after losing the leadership, deleted %q", deleted)
+	}
+}
@@ -0,0 +1,108 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/idle"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// detectIdle marks running clusters that were idle for the detector's period
+// and pulls in their expiration. An idle cluster that is in use again gets
+// back the expiration it had before, or a later one it was renewed to while
+// idle.
+func (g *GKE) detectIdle(ctx context.Context, policy config.Policy) error {
+	if g.Idle == nil {
+		return nil
+	}
+
+	records, err := g.ClusterStore.ListByKind(ctx, provider.KindGKECluster)
+	if err != nil {
+		return err
+	}
+
+	gkeClusters, err := g.listClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if record.IsIgnored(now) || record.ActionTaken != "" {
+			continue
+		}
+
+		cluster, err := g.getCluster(gkeClusters, record)
+		if err != nil || !sameCreateTime(&record, cluster) || cluster.Status != containerpb.Cluster_RUNNING {
+			continue
+		}
+
+		if protected, _ := policy.Protection.Protects(cluster.Name, cluster.ResourceLabels); protected {
+			continue
+		}
+
+		isIdle, activeSignal, err := g.Idle.Idle(ctx, idle.Cluster{
+			Project:       cluster.Project,
+			Location:      cluster.Location,
+			Name:          cluster.Name,
+			CreateTime:    record.CreateDate,
+			Endpoint:      cluster.Endpoint,
+			CACertificate: cluster.GetMasterAuth().GetClusterCaCertificate(),
+		}, now)
+		if err != nil {
+			g.Log.Error(err, "Failed to detect whether cluster is idle", "cluster", record.Name)
+			continue
+		}
+
+		switch {
+		case isIdle && !record.IsIdle():
+			expirationDate := now.Add(g.Idle.Lifetime)
+			if record.ExpirationDate.Before(expirationDate) {
+				expirationDate = record.ExpirationDate
+			}
+
+			g.Log.Info("Cluster is idle", "cluster", record.Name, "expirationDate", expirationDate)
+			err = g.ClusterStore.UpdateIdle(ctx, provider.KindGKECluster, record.Name, now, expirationDate, record.ExpirationDate)
+			if err != nil {
+				return err
+			}
+			g.recordOwnerEvent(ctx, record, store.EventIdle, fmt.Sprintf("idle for %s, expires at %s", g.Idle.Period, expirationDate.Format(time.RFC3339)))
+		case !isIdle && record.IsIdle():
+			// A cluster found idle before the expiration was kept gets its
+			// normal lifetime back.
+			expirationDate := record.ExpirationBeforeIdle
+			if expirationDate.IsZero() {
+				expirationDate = record.CreateDate.Add(policy.LifetimeFor(cluster.ResourceLabels))
+			}
+			if record.ExpirationDate.After(expirationDate) {
+				expirationDate = record.ExpirationDate
+			}
+
+			g.Log.Info("Cluster is in use again", "cluster", record.Name, "signal", activeSignal, "expirationDate", expirationDate)
+			err = g.ClusterStore.UpdateIdle(ctx, provider.KindGKECluster, record.Name, time.Time{}, expirationDate, time.Time{})
+			if err != nil {
+				return err
+			}
+			g.recordOwnerEvent(ctx, record, store.EventActive, fmt.Sprintf("in use again according to the %s signal, expires at %s", activeSignal, expirationDate.Format(time.RFC3339)))
+		}
+	}
+
+	return nil
+}
+
+// recordOwnerEvent records an event and addresses its notification to the
+// cluster's owner.
+func (g *GKE) recordOwnerEvent(ctx context.Context, record store.ClusterRecord, eventType string, message string) {
+	err := g.EventStore.Insert(ctx, record.Kind, record.Name, eventType, message)
+	if err != nil {
+		g.Log.Error(err, "Failed to record event", "cluster", record.Name, "type", eventType)
+	}
+
+	g.Notifier.NotifyOwner(ctx, record.Name, record.Owner, eventType, message)
+}
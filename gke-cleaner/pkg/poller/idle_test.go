@@ -0,0 +1,226 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/idle"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+// newIdleTest returns a GKE poller of a fake GKE whose idle detector reads the
+// signal, and stores the cluster as created two days ago with a three day
+// lifetime.
+func newIdleTest(t *testing.T, signal *idle.FakeSignal, name string) (*GKE, config.Policy, store.ClusterRecord) {
+	policy := config.Policy{ClusterLifetimeDuration: 72 * time.Hour}
+	g, fakeGKE := newTestGKE(t, policy)
+	g.Idle = &idle.Detector{
+		Signals:  []idle.Signal{signal},
+		Period:   24 * time.Hour,
+		Lifetime: 2 * time.Hour,
+	}
+
+	createTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	fakeGKE.put("project-a", devCluster("us-central1", name, createTime))
+
+	record := store.ClusterRecord{
+		Kind:           provider.KindGKECluster,
+		Name:           name,
+		CreateDate:     createTime,
+		ExpirationDate: createTime.Add(72 * time.Hour),
+	}
+	err := g.ClusterStore.Insert(context.Background(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, policy, record
+}
+
+func TestDetectIdleMarksAndUnmarks(t *testing.T) {
+	signal := &idle.FakeSignal{SignalName: "fake"}
+	g, policy, original := newIdleTest(t, signal, "dev")
+	ctx := context.Background()
+
+	before := time.Now()
+	err := g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.IsIdle() || record.IdleSince.Before(before.Truncate(time.Second)) {
+		t.Errorf("expected the cluster to be marked idle since about %s, got %s", before, record.IdleSince)
+	}
+	if expected := before.Add(2 * time.Hour); record.ExpirationDate.Before(expected.Add(-time.Second)) || record.ExpirationDate.After(expected.Add(time.Minute)) {
+		t.Errorf("expected the idle cluster to expire at about %s, got %s", expected, record.ExpirationDate)
+	}
+
+	idleSince, idleExpiration := record.IdleSince, record.ExpirationDate
+	err = g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.IdleSince.Equal(idleSince) || !record.ExpirationDate.Equal(idleExpiration) {
+		t.Errorf("expected a cluster that stays idle to be left alone, got idle since %s, expires %s", record.IdleSince, record.ExpirationDate)
+	}
+
+	signal.SetActive("dev", true)
+	err = g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.IsIdle() {
+		t.Errorf("expected the cluster in use again not to be idle, got idle since %s", record.IdleSince)
+	}
+	if !record.ExpirationDate.Equal(original.ExpirationDate) {
+		t.Errorf("expected the cluster in use again to get its lifetime back and expire at %s, got %s", original.ExpirationDate, record.ExpirationDate)
+	}
+
+	events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev")
+	expected := []string{store.EventIdle, store.EventActive}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+}
+
+func TestDetectIdleRestoresTheExpirationBeforeIdle(t *testing.T) {
+	signal := &idle.FakeSignal{SignalName: "fake"}
+	g, policy, original := newIdleTest(t, signal, "dev")
+	ctx := context.Background()
+
+	renewedUntil := time.Now().AddDate(0, 0, 7).Truncate(time.Second)
+	err := g.ClusterStore.UpdateExpirationDate(ctx, original.Kind, original.Name, renewedUntil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.IsIdle() || !record.ExpirationBeforeIdle.Equal(renewedUntil) {
+		t.Errorf("expected the expiration before idle to be kept as %s, got idle %t, %s", renewedUntil, record.IsIdle(), record.ExpirationBeforeIdle)
+	}
+
+	signal.SetActive("dev", true)
+	err = g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.ExpirationDate.Equal(renewedUntil) || !record.ExpirationBeforeIdle.IsZero() {
+		t.Errorf("expected the renewed expiration %s to be restored, got %s and %s before idle", renewedUntil, record.ExpirationDate, record.ExpirationBeforeIdle)
+	}
+}
+
+func TestDetectIdleKeepsAnEarlierExpiration(t *testing.T) {
+	signal := &idle.FakeSignal{SignalName: "fake"}
+	g, policy, original := newIdleTest(t, signal, "dev")
+	ctx := context.Background()
+
+	expirationDate := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := g.ClusterStore.UpdateExpirationDate(ctx, original.Kind, original.Name, expirationDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.detectIdle(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.IsIdle() || !record.ExpirationDate.Equal(expirationDate) {
+		t.Errorf("expected the cluster to be marked idle and still expire at %s, got idle %t, expires %s", expirationDate, record.IsIdle(), record.ExpirationDate)
+	}
+}
+
+func TestDetectIdleLeavesClustersAlone(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal *idle.FakeSignal
+		setup  func(g *GKE, record store.ClusterRecord) error
+	}{
+		{
+			name:   "a cluster in use",
+			signal: &idle.FakeSignal{SignalName: "fake", ActiveClusters: map[string]bool{"dev": true}},
+		},
+		{
+			name:   "a cluster whose signal fails",
+			signal: &idle.FakeSignal{SignalName: "fake", Err: errors.New("quota exceeded")},
+		},
+		{
+			name:   "an ignored cluster",
+			signal: &idle.FakeSignal{SignalName: "fake"},
+			setup: func(g *GKE, record store.ClusterRecord) error {
+				return g.ClusterStore.UpdateIgnore(context.Background(), record.Kind, record.Name, true, time.Time{}, "testing", "user", time.Now())
+			},
+		},
+		{
+			name:   "a cluster younger than the period",
+			signal: &idle.FakeSignal{SignalName: "fake"},
+			setup: func(g *GKE, record store.ClusterRecord) error {
+				g.Idle.Period = 72 * time.Hour
+				return nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, policy, original := newIdleTest(t, test.signal, "dev")
+			ctx := context.Background()
+
+			if test.setup != nil {
+				err := test.setup(g, original)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := g.detectIdle(ctx, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.IsIdle() || !record.ExpirationDate.Equal(original.ExpirationDate) {
+				t.Errorf("expected the cluster to be left alone, got idle %t, expires %s", record.IsIdle(), record.ExpirationDate)
+			}
+		})
+	}
+}
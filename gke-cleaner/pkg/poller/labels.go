@@ -0,0 +1,243 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+const (
+	LabelExpiresAt = "gke-cleaner-expires-at"
+	LabelIgnored   = "gke-cleaner-ignored"
+	LabelOwner     = "gke-cleaner-owner"
+
+	// LabelReason is the reason to ignore a cluster with the ignore label,
+	// which is required once the cluster reached the renewal limits.
+	LabelReason = "gke-cleaner-reason"
+
+	// expiresAtLayout is RFC 3339 in UTC, lower cased and without colons so
+	// it is a valid label value.
+	expiresAtLayout = "2006-01-02t15-04-05z"
+	expiresOnLayout = "2006-01-02"
+
+	// ignoredByLabel is who ignored a cluster that was ignored with the
+	// ignore label.
+	ignoredByLabel = "label"
+)
+
+var invalidLabelValueChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// syncStateLabels keeps the expiration, ignore and owner labels on each
+// tracked cluster in step with the store. A state label edited in gke since
+// the last poll is applied to the store first, so it wins over a change made
+// through the API in the meantime. previous are the records as they were
+// before this poll's sync.
+func (g *GKE) syncStateLabels(ctx context.Context, policy config.Policy, clusters []gkeCluster, previous []store.ClusterRecord) error {
+	records, err := g.ClusterStore.ListByKind(ctx, provider.KindGKECluster)
+	if err != nil {
+		return err
+	}
+
+	previousLabels := map[string]map[string]string{}
+	for _, record := range previous {
+		previousLabels[record.Name] = record.Labels
+	}
+
+	for _, record := range records {
+		cluster, found := trackedCluster(clusters, record)
+		if !found || !sameCreateTime(&record, cluster) {
+			continue
+		}
+
+		if labels, found := previousLabels[cluster.Name]; found {
+			record, err = g.applyStateLabels(ctx, policy.RenewalLimits, record, labels, cluster.ResourceLabels)
+			if err != nil {
+				return err
+			}
+		}
+
+		labels := withStateLabels(cluster.ResourceLabels, record, time.Now())
+		if sameLabels(labels, cluster.ResourceLabels) {
+			continue
+		}
+
+		g.Log.V(1).Info("Setting state labels", "cluster", cluster.GetName())
+		callCtx, cancelCall := g.callContext(ctx)
+		_, err = g.Client.SetLabels(callCtx, &containerpb.SetLabelsRequest{
+			Name:             clusterPath(cluster),
+			ResourceLabels:   labels,
+			LabelFingerprint: cluster.LabelFingerprint,
+		})
+		cancelCall()
+		if err != nil {
+			g.Log.Error(err, "Failed to set state labels", "cluster", cluster.GetName())
+			continue
+		}
+
+		err = g.ClusterStore.UpdateLabels(ctx, provider.KindGKECluster, cluster.GetName(), labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyStateLabels applies the state labels that changed in gke since the
+// last poll to the record. A label can't carry a justification, so a renewal
+// beyond the renewal limits is refused and an ignore at the limits needs the
+// reason label. A refused label is written back with the stored value.
+func (g *GKE) applyStateLabels(ctx context.Context, limits config.RenewalLimits, record store.ClusterRecord, previous map[string]string, current map[string]string) (store.ClusterRecord, error) {
+	if value, changed := labelChanged(previous, current, LabelExpiresAt); changed {
+		expirationDate, err := parseExpiresAt(value)
+		if err != nil {
+			g.Log.Info("Ignoring invalid label", "cluster", record.Name, "label", LabelExpiresAt, "value", value)
+		} else if !expirationDate.Equal(record.ExpirationDate.Truncate(time.Second)) {
+			renewed := record.Renewed(expirationDate, time.Now())
+			if exceeded := limits.Exceeded(renewed.RenewalCount, renewed.AgeAtExpiration()); exceeded != "" {
+				g.Log.Info("Refusing renewal by label beyond the renewal limits", "cluster", record.Name, "value", value)
+				g.recordEvent(ctx, record.Name, store.EventLabelRefused, fmt.Sprintf("%s=%s refused, renewing %s: renew it through the API as an admin or with a justification", LabelExpiresAt, value, exceeded))
+			} else {
+				err = g.ClusterStore.UpdateRenewal(ctx, provider.KindGKECluster, record.Name, expirationDate, renewed.RenewalCount, renewed.RenewalExtension)
+				if err != nil {
+					return record, err
+				}
+				record = renewed
+				g.recordEvent(ctx, record.Name, store.EventRenewed, fmt.Sprintf("expires at %s, renewal %d, set by the %s label", expirationDate.Format(time.RFC3339), record.RenewalCount, LabelExpiresAt))
+			}
+		}
+	}
+
+	if value, changed := labelChanged(previous, current, LabelIgnored); changed {
+		now := time.Now()
+		switch {
+		case value == "true" && !record.IsIgnored(now):
+			reason := current[LabelReason]
+			if reason == "" && limits.Reached(record.RenewalCount, record.AgeAtExpiration()) {
+				g.Log.Info("Refusing ignore by label without a reason", "cluster", record.Name)
+				g.recordEvent(ctx, record.Name, store.EventLabelRefused, fmt.Sprintf("%s=true refused, the cluster reached the renewal limits: set the %s label to ignore it", LabelIgnored, LabelReason))
+				break
+			}
+			if reason == "" {
+				reason = fmt.Sprintf("set by the %s label", LabelIgnored)
+			}
+			err := g.ClusterStore.UpdateIgnore(ctx, provider.KindGKECluster, record.Name, true, time.Time{}, reason, ignoredByLabel, now)
+			if err != nil {
+				return record, err
+			}
+			record.Ignore, record.IgnoreUntil, record.IgnoreReason = true, time.Time{}, reason
+			record.IgnoredBy, record.IgnoreDate = ignoredByLabel, now
+			g.recordEvent(ctx, record.Name, store.EventIgnored, reason)
+		case value == "false" && record.IsIgnored(now):
+			err := g.ClusterStore.UpdateIgnore(ctx, provider.KindGKECluster, record.Name, false, time.Time{}, "", "", time.Time{})
+			if err != nil {
+				return record, err
+			}
+			record.Ignore, record.IgnoreUntil, record.IgnoreReason = false, time.Time{}, ""
+			record.IgnoredBy, record.IgnoreDate = "", time.Time{}
+			g.recordEvent(ctx, record.Name, store.EventUnignored, fmt.Sprintf("set by the %s label", LabelIgnored))
+		}
+	}
+
+	if value, changed := labelChanged(previous, current, LabelOwner); changed && value != labelValue(record.Owner) {
+		err := g.ClusterStore.UpdateOwner(ctx, provider.KindGKECluster, record.Name, value, owner.SourceLabel)
+		if err != nil {
+			return record, err
+		}
+		record.Owner, record.OwnerSource = value, owner.SourceLabel
+	}
+
+	return record, nil
+}
+
+// adoptStateLabels takes the expiration and ignore of a newly discovered
+// cluster from its state labels, which are all that is left of them when the
+// database was lost. The state labels would otherwise be overwritten with a
+// fresh lifetime. An expiration from before the cluster was created was
+// copied from another cluster and is not adopted. It returns the labels that
+// were adopted.
+func adoptStateLabels(record store.ClusterRecord, labels map[string]string, now time.Time) (store.ClusterRecord, []string) {
+	adopted := []string{}
+
+	if value, found := labels[LabelExpiresAt]; found {
+		expirationDate, err := parseExpiresAt(value)
+		if err == nil && expirationDate.After(record.CreateDate) {
+			record.ExpirationDate = expirationDate
+			adopted = append(adopted, LabelExpiresAt)
+		}
+	}
+
+	if labels[LabelIgnored] == "true" {
+		record.Ignore = true
+		record.IgnoreReason = fmt.Sprintf("adopted from the %s label", LabelIgnored)
+		record.IgnoredBy = ignoredByLabel
+		record.IgnoreDate = now
+		adopted = append(adopted, LabelIgnored)
+	}
+
+	return record, adopted
+}
+
+// withStateLabels returns labels with the record's state labels set.
+func withStateLabels(labels map[string]string, record store.ClusterRecord, now time.Time) map[string]string {
+	merged := map[string]string{}
+	for k, v := range labels {
+		merged[k] = v
+	}
+
+	merged[LabelExpiresAt] = formatExpiresAt(record.ExpirationDate)
+	merged[LabelIgnored] = fmt.Sprintf("%t", record.IsIgnored(now))
+	if record.Owner != "" {
+		merged[LabelOwner] = labelValue(record.Owner)
+	} else {
+		delete(merged, LabelOwner)
+	}
+
+	return merged
+}
+
+// labelChanged returns the current value of key and whether it was set to a
+// new value since previous. A removed label is not a change, it is written
+// back.
+func labelChanged(previous map[string]string, current map[string]string, key string) (string, bool) {
+	value, found := current[key]
+	if !found {
+		return "", false
+	}
+
+	return value, previous[key] != value
+}
+
+func formatExpiresAt(t time.Time) string {
+	return strings.ToLower(t.UTC().Format(expiresAtLayout))
+}
+
+// parseExpiresAt accepts the format the label is written in, or a plain date
+// which is easier to type when renewing by hand.
+func parseExpiresAt(value string) (time.Time, error) {
+	t, err := time.Parse(expiresAtLayout, value)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(expiresOnLayout, value)
+}
+
+// labelValue converts s into a valid label value, e.g. an email address.
+func labelValue(s string) string {
+	value := invalidLabelValueChars.ReplaceAllString(strings.ToLower(s), "_")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+
+	return value
+}
@@ -0,0 +1,214 @@
+package poller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+const devClusterPath = "projects/project-a/locations/us-central1-a/clusters/dev"
+
+// newLabelsTest returns a GKE poller that has discovered and labelled the dev
+// cluster, created an hour ago.
+func newLabelsTest(t *testing.T, labels map[string]string) (*GKE, *fakeClusterManager, time.Time) {
+	gke, fake := newTestGKE(t, config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+	})
+
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	cluster := testCluster("dev", createTime, containerpb.Cluster_RUNNING)
+	for k, v := range labels {
+		cluster.ResourceLabels[k] = v
+	}
+	fake.put("project-a", cluster)
+
+	err := gke.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gke, fake, createTime
+}
+
+func TestStateLabelsAreSet(t *testing.T) {
+	gke, fake, createTime := newLabelsTest(t, map[string]string{"owner": "Jane.Doe@example.com"})
+
+	expected := map[string]string{
+		"env":          "dev",
+		"owner":        "Jane.Doe@example.com",
+		LabelExpiresAt: formatExpiresAt(createTime.Add(8 * time.Hour)),
+		LabelIgnored:   "false",
+		LabelOwner:     "jane_doe_example_com",
+	}
+	if labels := fake.labels(devClusterPath); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, labels)
+	}
+
+	record := getRecord(t, gke, "dev")
+	if record.Owner != "Jane.Doe@example.com" {
+		t.Errorf("expected the owner to be taken from the owner label, got %q", record.Owner)
+	}
+	if !reflect.DeepEqual(record.Labels, expected) {
+		t.Errorf("expected the stored labels to be the ones set, got %v", record.Labels)
+	}
+}
+
+func TestStateLabelEditsAreApplied(t *testing.T) {
+	gke, fake, _ := newLabelsTest(t, nil)
+	ctx := context.Background()
+
+	fake.setLabel(devClusterPath, LabelExpiresAt, "2030-01-02")
+	fake.setLabel(devClusterPath, LabelIgnored, "true")
+	fake.setLabel(devClusterPath, LabelOwner, "team-data")
+	err := gke.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := getRecord(t, gke, "dev")
+	if !record.ExpirationDate.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the cluster to be renewed by the label, expires %s", record.ExpirationDate)
+	}
+	if !record.Ignore || record.IgnoreReason != "set by the gke-cleaner-ignored label" {
+		t.Errorf("expected the cluster to be ignored by the label, got %t %q", record.Ignore, record.IgnoreReason)
+	}
+	if record.Owner != "team-data" {
+		t.Errorf("expected the owner to be set by the label, got %q", record.Owner)
+	}
+
+	if labels := fake.labels(devClusterPath); labels[LabelExpiresAt] != "2030-01-02t00-00-00z" {
+		t.Errorf("expected the expiration label to be rewritten in its own format, got %q", labels[LabelExpiresAt])
+	}
+
+	expectedEvents := []string{store.EventDiscovered, store.EventRenewed, store.EventIgnored}
+	if events := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("expected events %v, got %v", expectedEvents, events)
+	}
+}
+
+func TestStateLabelsAreWrittenBack(t *testing.T) {
+	gke, fake, createTime := newLabelsTest(t, nil)
+
+	fake.setLabel(devClusterPath, LabelExpiresAt, "")
+	fake.setLabel(devClusterPath, LabelIgnored, "not-a-bool")
+	err := gke.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := fake.labels(devClusterPath)
+	if labels[LabelExpiresAt] != formatExpiresAt(createTime.Add(8*time.Hour)) || labels[LabelIgnored] != "false" {
+		t.Errorf("expected a removed or invalid state label to be written back, got %v", labels)
+	}
+
+	if record := getRecord(t, gke, "dev"); !record.ExpirationDate.Equal(createTime.Add(8*time.Hour)) || record.Ignore {
+		t.Errorf("expected the record to be left alone, got %+v", record)
+	}
+}
+
+func TestStateLabelEditsBeyondTheRenewalLimitsAreRefused(t *testing.T) {
+	gke, fake, _ := newLabelsTest(t, nil)
+	gke.Policy.Set(config.Policy{
+		ClusterLifetimeDuration: 8 * time.Hour,
+		LabelFilters:            []string{"env=dev"},
+		RenewalLimits:           config.RenewalLimits{MaxRenewals: 1},
+	})
+	ctx := context.Background()
+	renewedUntil := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	sync := func(key string, value string) {
+		fake.setLabel(devClusterPath, key, value)
+		err := gke.Sync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sync(LabelExpiresAt, "2030-01-02")
+	sync(LabelExpiresAt, "2030-02-01")
+	if record := getRecord(t, gke, "dev"); !record.ExpirationDate.Equal(renewedUntil) || record.RenewalCount != 1 {
+		t.Errorf("expected the renewal beyond the limits to be refused, got %s after %d renewals", record.ExpirationDate, record.RenewalCount)
+	}
+	if labels := fake.labels(devClusterPath); labels[LabelExpiresAt] != formatExpiresAt(renewedUntil) {
+		t.Errorf("expected the stored expiration to be written back, got %q", labels[LabelExpiresAt])
+	}
+
+	sync(LabelIgnored, "true")
+	if record := getRecord(t, gke, "dev"); record.Ignore {
+		t.Error("expected the ignore without a reason to be refused")
+	}
+	if labels := fake.labels(devClusterPath); labels[LabelIgnored] != "false" {
+		t.Errorf("expected the ignore label to be written back, got %q", labels[LabelIgnored])
+	}
+
+	fake.setLabel(devClusterPath, LabelReason, "release_testing")
+	sync(LabelIgnored, "true")
+	if record := getRecord(t, gke, "dev"); !record.Ignore || record.IgnoreReason != "release_testing" {
+		t.Errorf("expected the cluster to be ignored with the reason label, got %t %q", record.Ignore, record.IgnoreReason)
+	}
+
+	expectedEvents := []string{store.EventDiscovered, store.EventRenewed, store.EventLabelRefused, store.EventLabelRefused, store.EventIgnored}
+	if events := eventTypes(t, gke.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("expected events %v, got %v", expectedEvents, events)
+	}
+}
+
+func TestParseExpiresAt(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		err      bool
+	}{
+		{value: "2030-01-02t03-04-05z", expected: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{value: "2030-01-02", expected: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{value: "tomorrow", err: true},
+	}
+
+	for _, test := range tests {
+		parsed, err := parseExpiresAt(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.value, parsed)
+			}
+			continue
+		}
+		if err != nil || !parsed.Equal(test.expected) {
+			t.Errorf("%s: expected %s, got %s, %v", test.value, test.expected, parsed, err)
+		}
+	}
+}
+
+func TestDiscoveryAdoptsStateLabels(t *testing.T) {
+	gke, fake, _ := newLabelsTest(t, map[string]string{
+		LabelExpiresAt: "2030-01-02",
+		LabelIgnored:   "true",
+	})
+
+	record := getRecord(t, gke, "dev")
+	if !record.ExpirationDate.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the expiration to be adopted from the label, expires %s", record.ExpirationDate)
+	}
+	if !record.Ignore || record.IgnoreReason != "adopted from the gke-cleaner-ignored label" {
+		t.Errorf("expected the ignore to be adopted from the label, got %t %q", record.Ignore, record.IgnoreReason)
+	}
+
+	if labels := fake.labels(devClusterPath); labels[LabelExpiresAt] != "2030-01-02t00-00-00z" || labels[LabelIgnored] != "true" {
+		t.Errorf("expected the adopted state labels to be kept, got %v", labels)
+	}
+}
+
+func TestDiscoveryIgnoresAnExpirationBeforeCreation(t *testing.T) {
+	gke, _, createTime := newLabelsTest(t, map[string]string{LabelExpiresAt: "2000-01-01"})
+
+	if record := getRecord(t, gke, "dev"); !record.ExpirationDate.Equal(createTime.Add(8 * time.Hour)) {
+		t.Errorf("expected a fresh lifetime for a copied expiration label, expires %s", record.ExpirationDate)
+	}
+}
@@ -0,0 +1,66 @@
+package poller
+
+import (
+	"context"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+)
+
+// resolveOwner finds the owner of a cluster and where it was found. When the
+// audit log can't be read both are empty, so the next poll tries again.
+func (g *GKE) resolveOwner(ctx context.Context, cluster gkeCluster, createTime time.Time) (string, string) {
+	clusterOwner, source, err := g.Owners.Resolve(ctx, owner.Cluster{
+		Project:    cluster.Project,
+		Location:   cluster.Location,
+		Name:       cluster.GetName(),
+		Labels:     cluster.ResourceLabels,
+		CreateTime: createTime,
+	})
+	if err != nil {
+		g.Log.Error(err, "Failed to resolve owner", "cluster", cluster.GetName())
+		return "", ""
+	}
+
+	return clusterOwner, source
+}
+
+// resolveOwners looks for the owners of tracked clusters that have none. The
+// audit log is only read again for clusters it could not be read for, a
+// cluster with no known owner can still be given one by its labels.
+func (g *GKE) resolveOwners(ctx context.Context, clusters []gkeCluster) error {
+	records, err := g.ClusterStore.ListByKind(ctx, provider.KindGKECluster)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Owner != "" {
+			continue
+		}
+
+		cluster, err := g.getCluster(clusters, record)
+		if err != nil || !sameCreateTime(&record, cluster) {
+			continue
+		}
+
+		var clusterOwner, source string
+		if record.OwnerSource == "" {
+			clusterOwner, source = g.resolveOwner(ctx, cluster, record.CreateDate)
+		} else {
+			clusterOwner, source = g.Owners.FromLabels(cluster.ResourceLabels)
+		}
+		if clusterOwner == record.Owner && source == record.OwnerSource {
+			continue
+		}
+
+		g.Log.Info("Resolved owner", "cluster", record.Name, "owner", clusterOwner, "source", source)
+		err = g.ClusterStore.UpdateOwner(ctx, provider.KindGKECluster, record.Name, clusterOwner, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
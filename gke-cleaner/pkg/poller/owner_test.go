@@ -0,0 +1,85 @@
+package poller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+func TestResolveOwners(t *testing.T) {
+	db := newTestDB(t)
+	auditLog := &owner.FakeAuditLog{Creators: map[string]string{
+		"unread":  "alice@example.com",
+		"unknown": "bob@example.com",
+		"labeled": "carol@example.com",
+	}}
+
+	g := &GKE{
+		Log:          testLog,
+		ClusterStore: &store.Cluster{DB: db},
+		Owners:       &owner.Resolver{Labels: []string{"owner"}, AuditLog: auditLog},
+	}
+
+	ctx := context.Background()
+	createTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	clusters := []gkeCluster{}
+	for name, ownerSource := range map[string]string{
+		// The audit log could not be read for it before.
+		"unread": "",
+		// The audit log didn't know, only labels are checked again.
+		"unknown": owner.SourceNone,
+		"labeled": owner.SourceNone,
+	} {
+		err := g.ClusterStore.Insert(ctx, store.ClusterRecord{
+			Kind:           provider.KindGKECluster,
+			Name:           name,
+			CreateDate:     createTime,
+			ExpirationDate: createTime.Add(time.Hour),
+			OwnerSource:    ownerSource,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cluster := gkeCluster{
+			Cluster: &containerpb.Cluster{Name: name, CreateTime: createTime.Format(time.RFC3339)},
+			Project: "project",
+		}
+		if name == "labeled" {
+			cluster.ResourceLabels = map[string]string{"owner": "dave"}
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	err := g.resolveOwners(ctx, clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]string{
+		"unread":  {"alice@example.com", owner.SourceAuditLog},
+		"unknown": {"", owner.SourceNone},
+		"labeled": {"dave", owner.SourceLabel},
+	}
+	for name, want := range expected {
+		record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Owner != want[0] || record.OwnerSource != want[1] {
+			t.Errorf("%s: expected %q from %q, got %q from %q", name, want[0], want[1], record.Owner, record.OwnerSource)
+		}
+	}
+
+	calls := auditLog.Calls()
+	if len(calls) != 1 || calls[0].Name != "unread" {
+		t.Errorf("expected the audit log to only be read for the unread cluster, got %v", calls)
+	}
+}
@@ -0,0 +1,97 @@
+package poller
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/janitor"
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+var testLog logr.Logger = zapr.NewLogger(zap.NewNop())
+
+type alwaysLeader struct{}
+
+func (alwaysLeader) IsLeader() bool { return true }
+
+// newTestDB opens a migrated sqlite database that is removed when the test
+// ends.
+func newTestDB(t *testing.T) *store.DB {
+	dir, err := ioutil.TempDir("", "gke-cleaner-poller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = (&migrate.DB{Log: testLog, DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func newTestNotifier(t *testing.T) *notify.Notifier {
+	notifier, err := notify.NewNotifier(testLog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return notifier
+}
+
+// newTestGKE returns a poller of the fake GKE's project-a with the policy.
+func newTestGKE(t *testing.T, pollerPolicy config.Policy) (*GKE, *fakeClusterManager) {
+	fake, client := newFakeGKE(t)
+	db := newTestDB(t)
+
+	return &GKE{
+		Log:           testLog,
+		Client:        client,
+		ClusterStore:  &store.Cluster{DB: db},
+		EventStore:    &store.Event{DB: db},
+		BreakerStore:  &store.Breaker{DB: db},
+		NodePoolStore: &store.NodePool{DB: db},
+		SweepStore:    &store.Sweep{DB: db},
+		DeletionStore: &store.Deletion{DB: db},
+		Janitor:       &janitor.Fake{},
+		Notifier:      newTestNotifier(t),
+		Owners:        &owner.Resolver{Labels: []string{LabelOwner, "owner"}},
+		Policy:        policy.NewStore(pollerPolicy),
+		Leader:        alwaysLeader{},
+		Projects:      []string{"project-a"},
+	}, fake
+}
+
+// eventTypes returns the types of the events recorded for the cluster, oldest
+// first.
+func eventTypes(t *testing.T, eventStore *store.Event, kind string, clusterName string) []string {
+	events, err := eventStore.ListByCluster(context.Background(), kind, clusterName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
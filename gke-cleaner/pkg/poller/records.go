@@ -0,0 +1,101 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+// recordKeeper keeps the stored records and events of tracked resources. GKE
+// clusters and provider resources are polled differently but discovered,
+// recreated and acted on when they expire the same way through it.
+type recordKeeper struct {
+	log           logr.Logger
+	clusterStore  *store.Cluster
+	eventStore    *store.Event
+	deletionStore *store.Deletion
+	notifier      *notify.Notifier
+}
+
+// discover stores a newly seen resource.
+func (k recordKeeper) discover(ctx context.Context, record store.ClusterRecord, message string) error {
+	err := k.clusterStore.Insert(ctx, record)
+	if err != nil {
+		return err
+	}
+	recordEvent(ctx, k.log, k.eventStore, k.notifier, record.Kind, record.Name, store.EventDiscovered, message)
+
+	return nil
+}
+
+// recreate replaces the stored record of a resource that was deleted and
+// recreated with the same name with record, the resource as if it was newly
+// discovered. Its lifetime, renewals, idleness and the action taken on it
+// start over, while ignores and a chosen expiry action carry over.
+func (k recordKeeper) recreate(ctx context.Context, record store.ClusterRecord) error {
+	stored, err := k.clusterStore.Get(ctx, record.Kind, record.Name)
+	if err != nil {
+		return err
+	}
+
+	record.ID = stored.ID
+	record.Ignore = stored.Ignore
+	record.IgnoreUntil = stored.IgnoreUntil
+	record.IgnoreReason = stored.IgnoreReason
+	record.IgnoredBy = stored.IgnoredBy
+	record.IgnoreDate = stored.IgnoreDate
+	record.ExpiryAction = stored.ExpiryAction
+	record.HourlyCost = stored.HourlyCost
+
+	err = k.clusterStore.Update(ctx, record)
+	if err != nil {
+		return err
+	}
+	recordEvent(ctx, k.log, k.eventStore, k.notifier, record.Kind, record.Name, store.EventUpdated, fmt.Sprintf("recreated at %s", record.CreateDate.Format(time.RFC3339)))
+
+	return nil
+}
+
+// awaitingAction is whether an expired record is neither ignored nor already
+// acted on.
+func awaitingAction(record store.ClusterRecord, now time.Time) bool {
+	return !record.IsIgnored(now) && record.ActionTaken == ""
+}
+
+// deferred records that count expired resources were left for a later pass
+// because of the limit.
+func (k recordKeeper) deferred(ctx context.Context, count int, what string, limit string) {
+	k.log.Info("Deferring expired resources", "count", count, "what", what, "limit", limit)
+	if limit != "" {
+		recordEvent(ctx, k.log, k.eventStore, k.notifier, "", "", store.EventDeletionsDeferred, fmt.Sprintf("%d expired %s deferred to a later pass: %s", count, what, limit))
+	}
+}
+
+// notifyOnly records that the expired resource is left in place and only
+// notified about, until it is renewed or recreated.
+func (k recordKeeper) notifyOnly(ctx context.Context, record store.ClusterRecord) error {
+	err := k.clusterStore.UpdateActionTaken(ctx, record.Kind, record.Name, config.ActionNotifyOnly)
+	if err != nil {
+		return err
+	}
+	recordEvent(ctx, k.log, k.eventStore, k.notifier, record.Kind, record.Name, store.EventExpired, fmt.Sprintf("expired at %s, not deleting", record.ExpirationDate.Format(time.RFC3339)))
+
+	return nil
+}
+
+// deleted records that the expired resource was deleted, and what it cost.
+func (k recordKeeper) deleted(record store.ClusterRecord, message string) {
+	ctx := bookkeepingContext()
+	recordEvent(ctx, k.log, k.eventStore, k.notifier, record.Kind, record.Name, store.EventDeleted, message)
+	recordDeletion(ctx, k.log, k.deletionStore, record)
+}
+
+// deleteFailed records that deleting the expired resource failed.
+func (k recordKeeper) deleteFailed(record store.ClusterRecord, deleteErr error) {
+	recordDeleteFailure(bookkeepingContext(), k.log, k.eventStore, k.notifier, record, deleteErr)
+}
@@ -0,0 +1,66 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+// RecoverFromLabels rebuilds the records of the clusters that carry a
+// gke-cleaner-expires-at state label, e.g. after the database was lost.
+// Clusters without one are left to be discovered by the next poll, as is a
+// cluster with the name of one recovered in another project or location.
+func (g *GKE) RecoverFromLabels(ctx context.Context) ([]store.ClusterRecord, error) {
+	gkeClusters, err := g.listClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []store.ClusterRecord{}
+	names := map[string]bool{}
+	for _, cluster := range filter(gkeClusters, g.Policy.Get().LabelFilters) {
+		value, found := cluster.ResourceLabels[LabelExpiresAt]
+		if !found || names[cluster.GetName()] {
+			continue
+		}
+
+		expirationDate, err := parseExpiresAt(value)
+		if err != nil {
+			g.Log.Info("Ignoring invalid label", "cluster", cluster.GetName(), "label", LabelExpiresAt, "value", value)
+			continue
+		}
+
+		createTime, err := time.Parse(time.RFC3339, cluster.GetCreateTime())
+		if err != nil {
+			return nil, err
+		}
+
+		clusterOwner, ownerSource := g.Owners.FromLabels(cluster.ResourceLabels)
+		record := store.ClusterRecord{
+			Kind:           provider.KindGKECluster,
+			Name:           cluster.GetName(),
+			CreateDate:     createTime,
+			ExpirationDate: expirationDate,
+			Status:         cluster.Status.String(),
+			Labels:         cluster.ResourceLabels,
+			SelfLink:       cluster.SelfLink,
+			Project:        cluster.Project,
+			Location:       cluster.Location,
+			Owner:          clusterOwner,
+			OwnerSource:    ownerSource,
+		}
+		if cluster.ResourceLabels[LabelIgnored] == "true" {
+			record.Ignore = true
+			record.IgnoreReason = fmt.Sprintf("recovered from the %s label", LabelIgnored)
+			record.IgnoredBy = ignoredByLabel
+		}
+
+		records = append(records, record)
+		names[record.Name] = true
+	}
+
+	return records, nil
+}
@@ -0,0 +1,340 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/owner"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+// Resources tracks and deletes the resources of a provider. GKE clusters are
+// polled by GKE instead, but lifetimes, ignores, renewals, expiry actions,
+// protection, safeguards and notifications work the same through the shared
+// deletionGuard and recordKeeper.
+type Resources struct {
+	Log           logr.Logger
+	Provider      provider.Provider
+	ClusterStore  *store.Cluster
+	DeletionStore *store.Deletion
+	EventStore    *store.Event
+	BreakerStore  *store.Breaker
+	Notifier      *notify.Notifier
+	Owners        *owner.Resolver
+
+	Policy *policy.Store
+	Leader LeaderChecker
+
+	PollInterval    time.Duration
+	PollJitter      float64
+	ShutdownTimeout time.Duration
+
+	mutex         sync.Mutex
+	policyVersion int
+}
+
+func (r *Resources) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return pollLoop{
+		log:             r.Log,
+		leader:          r.Leader,
+		interval:        r.PollInterval,
+		jitter:          r.PollJitter,
+		shutdownTimeout: r.ShutdownTimeout,
+		poll:            r.poll,
+	}.run(signals, ready)
+}
+
+func (r *Resources) Sync(ctx context.Context) error {
+	if !r.Leader.IsLeader() {
+		return ErrNotLeader
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.sync(ctx, r.applyPolicy())
+}
+
+func (r *Resources) poll(ctx context.Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	policy := r.applyPolicy()
+
+	if err := r.sync(ctx, policy); err != nil {
+		r.Log.Error(err, "Failed to sync resources", "kind", r.Provider.Kind())
+		return
+	}
+
+	if err := r.cleanupExpired(ctx, policy); err != nil {
+		logPollError(r.Log, err, "Failed to cleanup expired resources", "kind", r.Provider.Kind())
+		return
+	}
+}
+
+func (r *Resources) applyPolicy() config.Policy {
+	return applyPolicy(r.Log, r.Policy, r.Notifier, &r.policyVersion)
+}
+
+func (r *Resources) records() recordKeeper {
+	return recordKeeper{
+		log:           r.Log,
+		clusterStore:  r.ClusterStore,
+		eventStore:    r.EventStore,
+		deletionStore: r.DeletionStore,
+		notifier:      r.Notifier,
+	}
+}
+
+func (r *Resources) recordEvent(ctx context.Context, name string, eventType string, message string) {
+	recordEvent(ctx, r.Log, r.EventStore, r.Notifier, r.Provider.Kind(), name, eventType, message)
+}
+
+func (r *Resources) list(ctx context.Context, labelFilters []string) (map[string]provider.Resource, error) {
+	resources, err := r.Provider.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := map[string]provider.Resource{}
+	for _, resource := range resources {
+		if matchesFilters(resource.Labels, labelFilters) {
+			filtered[resource.Name] = resource
+		}
+	}
+
+	return filtered, nil
+}
+
+func (r *Resources) sync(ctx context.Context, policy config.Policy) error {
+	kind := r.Provider.Kind()
+
+	resources, err := r.list(ctx, policy.LabelFilters)
+	if err != nil {
+		return err
+	}
+
+	knownResources, err := r.ClusterStore.ListByKind(ctx, kind)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, record := range knownResources {
+		known[record.Name] = true
+
+		resource, found := resources[record.Name]
+		if !found {
+			r.Log.Info("Detected removal", "kind", kind, "name", record.Name)
+			err = r.ClusterStore.Delete(ctx, kind, record.Name)
+			if err != nil {
+				return err
+			}
+			r.recordEvent(ctx, record.Name, store.EventRemoved, fmt.Sprintf("no longer found in %s", kind))
+			continue
+		}
+
+		if !sameTime(record.CreateDate, resource.CreateTime) {
+			err = r.recreate(ctx, policy, resource)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if record.Status != resource.Status {
+			err = r.ClusterStore.UpdateStatus(ctx, record.Kind, record.Name, resource.Status, record.ExpirationDate)
+			if err != nil {
+				return err
+			}
+			r.recordEvent(ctx, record.Name, store.EventStatus, fmt.Sprintf("status %s -> %s", record.Status, resource.Status))
+		}
+
+		if !sameLabels(record.Labels, resource.Labels) {
+			err = r.ClusterStore.UpdateLabels(ctx, record.Kind, record.Name, resource.Labels)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, resource := range resources {
+		if known[name] {
+			continue
+		}
+
+		r.Log.Info("Discovered", "kind", kind, "name", name, "project", resource.Project)
+		record := r.newRecord(policy, resource)
+		err = r.records().discover(ctx, record, fmt.Sprintf("%s, status %s, expires at %s", kind, resource.Status, record.ExpirationDate.Format(time.RFC3339)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newRecord is the record of a newly seen resource.
+func (r *Resources) newRecord(policy config.Policy, resource provider.Resource) store.ClusterRecord {
+	resourceOwner, ownerSource := r.Owners.FromLabels(resource.Labels)
+
+	return store.ClusterRecord{
+		Kind:           resource.Kind,
+		Name:           resource.Name,
+		CreateDate:     resource.CreateTime,
+		ExpirationDate: resource.CreateTime.Add(policy.LifetimeFor(resource.Labels)),
+		Status:         resource.Status,
+		Labels:         resource.Labels,
+		SelfLink:       resource.SelfLink,
+		Project:        resource.Project,
+		Location:       resource.Location,
+		Owner:          resourceOwner,
+		OwnerSource:    ownerSource,
+	}
+}
+
+// recreate brings the stored record of a resource that was deleted and
+// recreated with the same name up to date.
+func (r *Resources) recreate(ctx context.Context, policy config.Policy, resource provider.Resource) error {
+	record := r.newRecord(policy, resource)
+	r.Log.Info("Update resource", "kind", resource.Kind, "name", resource.Name, "createTime", resource.CreateTime, "expirationDate", record.ExpirationDate)
+
+	return r.records().recreate(ctx, record)
+}
+
+func (r *Resources) cleanupExpired(ctx context.Context, policy config.Policy) error {
+	if !policy.Schedule.Allows(time.Now()) {
+		r.Log.V(1).Info("Outside the deletion windows, not deleting", "next", policy.Schedule.Next(time.Now()))
+		return nil
+	}
+
+	expiredResources, err := r.ClusterStore.ListExpired(ctx, r.Provider.Kind())
+	if err != nil {
+		return err
+	}
+
+	if len(expiredResources) == 0 {
+		return nil
+	}
+
+	// The resources are listed again so that a resource recreated since the
+	// last sync, or one that became protected, is not deleted.
+	resources, err := r.list(ctx, policy.LabelFilters)
+	if err != nil {
+		return err
+	}
+
+	dueRecords := []store.ClusterRecord{}
+	for _, record := range expiredResources {
+		if !awaitingAction(record, time.Now()) {
+			continue
+		}
+
+		resource, found := resources[record.Name]
+		if !found || !sameTime(record.CreateDate, resource.CreateTime) || record.SelfLink != resource.SelfLink {
+			r.Log.Info("Resource changed since it was stored. Skipping.", "kind", record.Kind, "name", record.Name)
+			continue
+		}
+
+		if protected, reason := policy.Protection.Protects(record.Name, resource.Labels); protected {
+			r.Log.V(1).Info("Resource is protected. Skipping.", "kind", record.Kind, "name", record.Name, "reason", reason)
+			continue
+		}
+
+		dueRecords = append(dueRecords, record)
+	}
+
+	if len(dueRecords) == 0 {
+		return nil
+	}
+
+	guard := deletionGuard{
+		log:          r.Log,
+		clusterStore: r.ClusterStore,
+		eventStore:   r.EventStore,
+		breakerStore: r.BreakerStore,
+		notifier:     r.Notifier,
+	}
+	allowed, limit, err := guard.allowance(ctx, policy.Safeguards, dueRecords)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range dueRecords {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if i >= allowed {
+			r.records().deferred(ctx, len(dueRecords)-i, record.Kind+" resources", limit)
+			break
+		}
+
+		resource := resources[record.Name]
+		action := policy.ExpiryActionFor(record.ExpiryAction, resource.Labels)
+
+		if policy.DryRun {
+			r.Log.Info("Dry run, not acting on expired resource", "kind", record.Kind, "name", record.Name, "action", action)
+			continue
+		}
+
+		if action != config.ActionDelete {
+			if action != config.ActionNotifyOnly {
+				r.Log.Info("Expiry action is not supported for this kind, notifying only", "kind", record.Kind, "name", record.Name, "action", action)
+			}
+
+			err = r.records().notifyOnly(ctx, record)
+			if err != nil {
+				r.Log.Error(err, "Failed to record action taken. Skipping.", "kind", record.Kind, "name", record.Name)
+			}
+			continue
+		}
+
+		if !r.Leader.IsLeader() {
+			return ErrNotLeader
+		}
+
+		err = r.Provider.Delete(ctx, resource)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			r.Log.Error(err, "Failed to delete resource. Skipping.", "kind", record.Kind, "name", record.Name)
+			r.records().deleteFailed(record, err)
+			continue
+		}
+		r.Log.Info("Removed expired resource", "kind", record.Kind, "name", record.Name)
+		r.records().deleted(record, fmt.Sprintf("%s expired at %s", record.Kind, record.ExpirationDate.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+// sameTime compares times to the second, which is all every database keeps.
+func sameTime(a time.Time, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+// Syncers syncs with every provider in turn.
+type Syncers []interface {
+	Sync(ctx context.Context) error
+}
+
+func (s Syncers) Sync(ctx context.Context) error {
+	for _, syncer := range s {
+		err := syncer.Sync(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
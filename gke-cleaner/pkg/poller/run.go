@@ -0,0 +1,103 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/ticker"
+	"github.com/go-logr/logr"
+)
+
+// pollLoop polls on every tick while this instance is the leader. A signal
+// cancels the poll in progress straight away, and the loop then waits up to
+// shutdownTimeout for the poll to record what it already did before
+// returning.
+type pollLoop struct {
+	log             logr.Logger
+	leader          LeaderChecker
+	interval        time.Duration
+	jitter          float64
+	shutdownTimeout time.Duration
+	poll            func(ctx context.Context)
+
+	// clock makes the ticker's timers, nil uses the real clock.
+	clock ticker.Clock
+}
+
+func (p pollLoop) run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := p.clock
+	if clock == nil {
+		clock = ticker.RealClock{}
+	}
+
+	t := ticker.NewWithClock(p.interval, p.jitter, clock)
+	defer t.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if ctx.Err() != nil {
+					return
+				}
+				if !p.leader.IsLeader() {
+					p.log.V(1).Info("Not the leader, skipping poll")
+					continue
+				}
+
+				p.log.V(1).Info("Polling")
+				p.poll(ctx)
+			}
+		}
+	}()
+
+	close(ready)
+	<-signals
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(p.shutdownTimeout):
+		p.log.Info("Timed out waiting for the poll to finish", "timeout", p.shutdownTimeout)
+	}
+
+	return nil
+}
+
+// logPollError logs why a poll stopped. Losing the leadership during a poll
+// is not an error, the new leader carries on.
+func logPollError(log logr.Logger, err error, msg string, keysAndValues ...interface{}) {
+	if errors.Is(err, ErrNotLeader) {
+		log.Info("Lost the leadership, stopping the poll", keysAndValues...)
+		return
+	}
+
+	log.Error(err, msg, keysAndValues...)
+}
+
+// callContext bounds a single call to an external API by timeout, unless it
+// is zero.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// bookkeepingContext is for recording an action already taken on a resource,
+// e.g. that it was deleted. It isn't cancelled with the poll, so shutting down
+// can't lose the record. Each store call is still bounded by the database
+// timeout.
+func bookkeepingContext() context.Context {
+	return context.Background()
+}
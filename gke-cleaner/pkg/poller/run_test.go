@@ -0,0 +1,177 @@
+package poller
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/ticker"
+)
+
+// fakeLeader sends on checks, when set, every time leadership is checked.
+type fakeLeader struct {
+	leader int32
+	checks chan bool
+}
+
+func (f *fakeLeader) IsLeader() bool {
+	leader := atomic.LoadInt32(&f.leader) == 1
+	if f.checks != nil {
+		f.checks <- leader
+	}
+
+	return leader
+}
+
+func (f *fakeLeader) set(leader bool) {
+	value := int32(0)
+	if leader {
+		value = 1
+	}
+	atomic.StoreInt32(&f.leader, value)
+}
+
+// startPollLoop runs the loop until a signal is sent on the returned channel,
+// and returns when the loop is ready. The loop's result is sent on exited.
+func startPollLoop(t *testing.T, loop pollLoop) (chan<- os.Signal, <-chan error) {
+	signals := make(chan os.Signal, 1)
+	ready := make(chan struct{})
+	exited := make(chan error, 1)
+	go func() {
+		exited <- loop.run(signals, ready)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the poll loop to be ready")
+	}
+
+	return signals, exited
+}
+
+func TestPollLoopPollsWhileLeader(t *testing.T) {
+	clock := &ticker.FakeClock{}
+	leader := &fakeLeader{checks: make(chan bool, 10)}
+	polls := make(chan struct{}, 10)
+
+	signals, exited := startPollLoop(t, pollLoop{
+		log:             testLog,
+		leader:          leader,
+		interval:        time.Minute,
+		shutdownTimeout: time.Second,
+		poll:            func(ctx context.Context) { polls <- struct{}{} },
+		clock:           clock,
+	})
+
+	awaitCheck := func() {
+		select {
+		case <-leader.checks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a tick to be handled")
+		}
+	}
+
+	clock.Fire()
+	awaitCheck()
+	select {
+	case <-polls:
+		t.Fatal("expected no poll while not the leader")
+	default:
+	}
+
+	leader.set(true)
+	clock.Fire()
+	awaitCheck()
+	select {
+	case <-polls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a poll as the leader")
+	}
+
+	signals <- os.Interrupt
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("expected the loop to exit cleanly, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the loop to exit")
+	}
+}
+
+func TestPollLoopCancelsThePollOnShutdown(t *testing.T) {
+	clock := &ticker.FakeClock{}
+	polling := make(chan struct{})
+	finished := int32(0)
+
+	signals, exited := startPollLoop(t, pollLoop{
+		log:             testLog,
+		leader:          &fakeLeader{leader: 1},
+		interval:        time.Minute,
+		shutdownTimeout: 5 * time.Second,
+		poll: func(ctx context.Context) {
+			close(polling)
+			<-ctx.Done()
+			// What the poll already did is still recorded after it was
+			// cancelled.
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+		},
+		clock: clock,
+	})
+
+	clock.Fire()
+	<-polling
+
+	start := time.Now()
+	signals <- os.Interrupt
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the loop to exit")
+	}
+
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Errorf("expected the loop to wait for the cancelled poll to finish")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the poll to be cancelled straight away, took %s", elapsed)
+	}
+}
+
+func TestPollLoopShutdownTimeout(t *testing.T) {
+	clock := &ticker.FakeClock{}
+	polling := make(chan struct{})
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	signals, exited := startPollLoop(t, pollLoop{
+		log:             testLog,
+		leader:          &fakeLeader{leader: 1},
+		interval:        time.Minute,
+		shutdownTimeout: 100 * time.Millisecond,
+		poll: func(ctx context.Context) {
+			close(polling)
+			<-stuck
+		},
+		clock: clock,
+	})
+
+	clock.Fire()
+	<-polling
+
+	start := time.Now()
+	signals <- os.Interrupt
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the loop to give up on a poll that ignores cancellation")
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected the loop to wait the shutdown timeout for the poll, returned after %s", elapsed)
+	}
+}
@@ -0,0 +1,139 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/notify"
+	"github.com/christianang/gke-cleaner/pkg/policy"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/logr"
+)
+
+// deletionGuard applies the deletion safeguards, which are shared by every
+// poller.
+type deletionGuard struct {
+	log          logr.Logger
+	clusterStore *store.Cluster
+	eventStore   *store.Event
+	breakerStore *store.Breaker
+	notifier     *notify.Notifier
+}
+
+// allowance returns how many of the due clusters may be deleted in this
+// pass and, if that is fewer than are due, the limit that applied. It trips the
+// deletion circuit breaker if too many of the known clusters are due at once.
+// Clusters that expired before the breaker was last re-armed were acknowledged
+// by the admin and don't count towards tripping it again.
+func (d deletionGuard) allowance(ctx context.Context, safeguards config.Safeguards, dueRecords []store.ClusterRecord) (int, string, error) {
+	due := len(dueRecords)
+
+	breaker, err := d.breakerStore.Get(ctx, store.DeletionBreaker)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get deletion circuit breaker: %s", err)
+	}
+
+	if breaker.Tripped {
+		d.log.Info("Deletion circuit breaker is tripped, not deleting", "due", due, "reason", breaker.Reason)
+		return 0, "", nil
+	}
+
+	newlyDue := 0
+	for _, record := range dueRecords {
+		if record.ExpirationDate.After(breaker.ArmedAt) {
+			newlyDue++
+		}
+	}
+
+	if safeguards.CircuitBreakerPercent > 0 && newlyDue > 1 {
+		knownClusters, err := d.clusterStore.List(ctx)
+		if err != nil {
+			return 0, "", err
+		}
+
+		if newlyDue*100 > safeguards.CircuitBreakerPercent*len(knownClusters) {
+			reason := fmt.Sprintf("%d of %d known clusters are due for deletion at once, more than %d%%", newlyDue, len(knownClusters), safeguards.CircuitBreakerPercent)
+			d.log.Info("Tripping deletion circuit breaker", "reason", reason)
+
+			err = d.breakerStore.Trip(ctx, store.DeletionBreaker, reason)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to trip deletion circuit breaker: %s", err)
+			}
+			recordEvent(ctx, d.log, d.eventStore, d.notifier, "", "", store.EventBreakerTripped, reason)
+
+			return 0, "", nil
+		}
+	}
+
+	allowed := due
+	limit := ""
+
+	if safeguards.MaxDeletionsPerPass > 0 && allowed > safeguards.MaxDeletionsPerPass {
+		allowed = safeguards.MaxDeletionsPerPass
+		limit = fmt.Sprintf("limit of %d deletions per pass reached", safeguards.MaxDeletionsPerPass)
+	}
+
+	if safeguards.MaxDeletionsPerHour > 0 {
+		deletedLastHour, err := d.eventStore.CountSince(ctx, store.EventDeleted, time.Now().Add(-time.Hour))
+		if err != nil {
+			return 0, "", err
+		}
+
+		remaining := safeguards.MaxDeletionsPerHour - deletedLastHour
+		if remaining < 0 {
+			remaining = 0
+		}
+		if allowed > remaining {
+			allowed = remaining
+			limit = fmt.Sprintf("limit of %d deletions per hour reached", safeguards.MaxDeletionsPerHour)
+		}
+	}
+
+	return allowed, limit, nil
+}
+
+// applyPolicy takes a snapshot of the current policy so that a reload only
+// takes effect between poll cycles.
+func applyPolicy(log logr.Logger, policyStore *policy.Store, notifier *notify.Notifier, appliedVersion *int) config.Policy {
+	policy, version := policyStore.GetVersioned()
+	if version == *appliedVersion {
+		return policy
+	}
+
+	if *appliedVersion != 0 {
+		log.Info("Applying reloaded policy", "version", version)
+	}
+	notifier.Configure(policy.NotificationSinks)
+	*appliedVersion = version
+
+	return policy
+}
+
+// recordEvent records an event of the resource of the kind named clusterName,
+// or of the cleaner itself when clusterName is empty.
+func recordEvent(ctx context.Context, log logr.Logger, eventStore *store.Event, notifier *notify.Notifier, kind string, clusterName string, eventType string, message string) {
+	if clusterName == "" {
+		kind = ""
+	}
+
+	err := eventStore.Insert(ctx, kind, clusterName, eventType, message)
+	if err != nil {
+		log.Error(err, "Failed to record event", "cluster", clusterName, "type", eventType)
+	}
+
+	notifier.Notify(ctx, clusterName, eventType, message)
+}
+
+// recordDeleteFailure records that deleting an expired resource failed. It is
+// recorded once per expiration, so a resource that keeps failing on every
+// poll doesn't flood the sinks.
+func recordDeleteFailure(ctx context.Context, log logr.Logger, eventStore *store.Event, notifier *notify.Notifier, record store.ClusterRecord, deleteErr error) {
+	latest, err := eventStore.Latest(ctx, record.Kind, record.Name, store.EventDeleteFailed)
+	if err == nil && latest.CreateDate.After(record.ExpirationDate) {
+		return
+	}
+
+	recordEvent(ctx, log, eventStore, notifier, record.Kind, record.Name, store.EventDeleteFailed, deleteErr.Error())
+}
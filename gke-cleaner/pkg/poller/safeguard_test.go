@@ -0,0 +1,206 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+// dueRecords returns acknowledged records that expired before the breaker was
+// armed at armedAt and newlyDue ones that expired after.
+func dueRecords(armedAt time.Time, acknowledged int, newlyDue int) []store.ClusterRecord {
+	records := []store.ClusterRecord{}
+	for i := 0; i < acknowledged; i++ {
+		records = append(records, store.ClusterRecord{Name: fmt.Sprintf("acknowledged-%d", i), ExpirationDate: armedAt.Add(-time.Hour)})
+	}
+	for i := 0; i < newlyDue; i++ {
+		records = append(records, store.ClusterRecord{Name: fmt.Sprintf("due-%d", i), ExpirationDate: armedAt.Add(time.Minute)})
+	}
+
+	return records
+}
+
+func TestDeletionAllowance(t *testing.T) {
+	armedAt := time.Now().Add(-30 * time.Minute)
+
+	tests := map[string]struct {
+		safeguards    config.Safeguards
+		known         int
+		acknowledged  int
+		due           int
+		deletedRecent int
+		deletedOld    int
+		tripped       bool
+
+		allowed      int
+		limit        string
+		expectTrip   bool
+		expectEvents int
+	}{
+		"no safeguards": {
+			known: 10, due: 10,
+			allowed: 10,
+		},
+		"per pass limit": {
+			safeguards: config.Safeguards{MaxDeletionsPerPass: 2},
+			known:      10, due: 5,
+			allowed: 2, limit: "limit of 2 deletions per pass reached",
+		},
+		"under the per pass limit": {
+			safeguards: config.Safeguards{MaxDeletionsPerPass: 5},
+			known:      10, due: 2,
+			allowed: 2,
+		},
+		"per hour limit": {
+			safeguards: config.Safeguards{MaxDeletionsPerHour: 3},
+			known:      10, due: 5, deletedRecent: 2, deletedOld: 5,
+			allowed: 1, limit: "limit of 3 deletions per hour reached",
+		},
+		"per hour limit used up": {
+			safeguards: config.Safeguards{MaxDeletionsPerHour: 3},
+			known:      10, due: 5, deletedRecent: 4,
+			allowed: 0, limit: "limit of 3 deletions per hour reached",
+		},
+		"per hour limit lower than per pass limit": {
+			safeguards: config.Safeguards{MaxDeletionsPerPass: 3, MaxDeletionsPerHour: 4},
+			known:      10, due: 5, deletedRecent: 2,
+			allowed: 2, limit: "limit of 4 deletions per hour reached",
+		},
+		"breaker trips": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 50},
+			known:      10, due: 6,
+			allowed: 0, expectTrip: true, expectEvents: 1,
+		},
+		"at the breaker percentage": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 50},
+			known:      10, due: 5,
+			allowed: 5,
+		},
+		"a single due cluster never trips the breaker": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 10},
+			known:      2, due: 1,
+			allowed: 1,
+		},
+		"clusters due when the breaker was armed don't trip it": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 50},
+			known:      10, acknowledged: 6, due: 2,
+			allowed: 8,
+		},
+		"a single newly due cluster never trips the breaker": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 10},
+			known:      10, acknowledged: 8, due: 1,
+			allowed: 9,
+		},
+		"newly due clusters trip the breaker": {
+			safeguards: config.Safeguards{CircuitBreakerPercent: 50},
+			known:      10, acknowledged: 2, due: 6,
+			allowed: 0, expectTrip: true, expectEvents: 1,
+		},
+		"tripped breaker": {
+			known: 10, due: 1, tripped: true,
+			allowed: 0, expectTrip: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gke, _ := newTestGKE(t, config.Policy{})
+			ctx := context.Background()
+
+			for i := 0; i < test.known; i++ {
+				err := gke.ClusterStore.Insert(ctx, store.ClusterRecord{Kind: provider.KindGKECluster, Name: fmt.Sprintf("known-%d", i), CreateDate: time.Now(), ExpirationDate: time.Now(), Status: "RUNNING"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < test.deletedOld+test.deletedRecent; i++ {
+				err := gke.EventStore.Insert(ctx, provider.KindGKECluster, fmt.Sprintf("deleted-%d", i), store.EventDeleted, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := gke.EventStore.DB.Exec(`UPDATE Events SET CreateDate = ? WHERE ID <= ?`, time.Now().Add(-2*time.Hour), test.deletedOld)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = gke.BreakerStore.DB.Exec(`UPDATE Breakers SET Tripped = ?, ArmedAt = ?`, test.tripped, armedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			allowed, limit, err := gke.deletionGuard().allowance(ctx, test.safeguards, dueRecords(armedAt, test.acknowledged, test.due))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allowed != test.allowed || limit != test.limit {
+				t.Errorf("expected %d allowed with limit %q, got %d with limit %q", test.allowed, test.limit, allowed, limit)
+			}
+
+			breaker, err := gke.BreakerStore.Get(ctx, store.DeletionBreaker)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if breaker.Tripped != test.expectTrip {
+				t.Errorf("expected the breaker to be tripped=%t, got %+v", test.expectTrip, breaker)
+			}
+
+			if events := eventTypes(t, gke.EventStore, "", ""); len(events) != test.expectEvents {
+				t.Errorf("expected %d breaker-tripped events, got %q", test.expectEvents, events)
+			}
+		})
+	}
+}
+
+func TestDeletionBreakerStaysTrippedUntilArmed(t *testing.T) {
+	gke, _ := newTestGKE(t, config.Policy{})
+	ctx := context.Background()
+	safeguards := config.Safeguards{CircuitBreakerPercent: 50}
+
+	for i := 0; i < 4; i++ {
+		err := gke.ClusterStore.Insert(ctx, store.ClusterRecord{Kind: provider.KindGKECluster, Name: fmt.Sprintf("known-%d", i), CreateDate: time.Now(), ExpirationDate: time.Now(), Status: "RUNNING"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := dueRecords(time.Now().Add(-time.Minute), 0, 3)
+
+	allowed, _, err := gke.deletionGuard().allowance(ctx, safeguards, due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed != 0 {
+		t.Fatalf("expected the breaker to trip, got %d allowed", allowed)
+	}
+
+	allowed, _, err = gke.deletionGuard().allowance(ctx, safeguards, due[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed != 0 {
+		t.Errorf("expected the tripped breaker to stop deletions that would not trip it, got %d allowed", allowed)
+	}
+
+	err = gke.BreakerStore.Arm(ctx, store.DeletionBreaker, "label filter fixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowed, _, err = gke.deletionGuard().allowance(ctx, safeguards, due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed != 3 {
+		t.Errorf("expected the clusters due when the breaker was armed to be deleted, got %d allowed", allowed)
+	}
+
+	expected := []string{store.EventBreakerTripped}
+	if events := eventTypes(t, gke.EventStore, "", ""); len(events) != 1 || events[0] != expected[0] {
+		t.Errorf("expected events %q, got %q", expected, events)
+	}
+}
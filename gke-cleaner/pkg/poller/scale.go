@@ -0,0 +1,288 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+const (
+	operationPollInterval = 5 * time.Second
+	operationTimeout      = 20 * time.Minute
+)
+
+var (
+	ErrNotScaledDown     = errors.New("cluster was not scaled to zero")
+	ErrRestoreInProgress = errors.New("cluster is already being restored")
+)
+
+// scaleToZero records the current size of every node pool of the cluster and
+// then scales each of them to zero nodes, turning off autoscaling first so
+// that the pool stays empty. Sizes recorded by an earlier attempt that failed
+// part way are kept.
+func (g *GKE) scaleToZero(ctx context.Context, cluster gkeCluster) error {
+	nodePools, err := g.NodePoolStore.ListByCluster(ctx, provider.KindGKECluster, cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	if len(nodePools) == 0 {
+		for _, nodePool := range cluster.NodePools {
+			size, err := g.NodePools.Size(ctx, nodePool.InstanceGroupUrls)
+			if err != nil {
+				return fmt.Errorf("failed to get the size of node pool %s: %s", nodePool.Name, err)
+			}
+
+			record := store.NodePoolRecord{
+				Kind:        provider.KindGKECluster,
+				ClusterName: cluster.Name,
+				Name:        nodePool.Name,
+				NodeCount:   size,
+			}
+			if nodePool.Autoscaling != nil && nodePool.Autoscaling.Enabled {
+				record.Autoscaling = true
+				record.MinNodeCount = int(nodePool.Autoscaling.MinNodeCount)
+				record.MaxNodeCount = int(nodePool.Autoscaling.MaxNodeCount)
+			}
+			nodePools = append(nodePools, record)
+		}
+
+		// Every size is read before any is recorded, a later attempt would
+		// otherwise miss the node pools that weren't recorded.
+		for _, record := range nodePools {
+			err = g.NodePoolStore.Insert(ctx, record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	description := []string{}
+	for _, nodePool := range nodePools {
+		if !g.Leader.IsLeader() {
+			return ErrNotLeader
+		}
+
+		path := nodePoolPath(cluster, nodePool.Name)
+
+		if nodePool.Autoscaling {
+			callCtx, cancelCall := g.callContext(ctx)
+			op, err := g.Client.SetNodePoolAutoscaling(callCtx, &containerpb.SetNodePoolAutoscalingRequest{
+				Name:        path,
+				Autoscaling: &containerpb.NodePoolAutoscaling{Enabled: false},
+			})
+			cancelCall()
+			if err != nil {
+				return fmt.Errorf("failed to disable autoscaling of node pool %s: %s", nodePool.Name, err)
+			}
+
+			err = g.waitForOperation(ctx, cluster, op)
+			if err != nil {
+				return fmt.Errorf("failed to disable autoscaling of node pool %s: %s", nodePool.Name, err)
+			}
+		}
+
+		callCtx, cancelCall := g.callContext(ctx)
+		op, err := g.Client.SetNodePoolSize(callCtx, &containerpb.SetNodePoolSizeRequest{
+			Name:      path,
+			NodeCount: 0,
+		})
+		cancelCall()
+		if err != nil {
+			return fmt.Errorf("failed to scale node pool %s to zero: %s", nodePool.Name, err)
+		}
+
+		err = g.waitForOperation(ctx, cluster, op)
+		if err != nil {
+			return fmt.Errorf("failed to scale node pool %s to zero: %s", nodePool.Name, err)
+		}
+
+		description = append(description, fmt.Sprintf("%s from %d", nodePool.Name, nodePool.NodeCount))
+	}
+
+	bookkeepingCtx := bookkeepingContext()
+	err = g.ClusterStore.UpdateActionTaken(bookkeepingCtx, provider.KindGKECluster, cluster.Name, config.ActionScaleToZero)
+	if err != nil {
+		return err
+	}
+
+	g.recordEvent(bookkeepingCtx, cluster.Name, store.EventScaledDown, fmt.Sprintf("scaled node pools to zero: %s", strings.Join(description, ", ")))
+
+	return nil
+}
+
+// Restore scales the node pools of a cluster that was scaled to zero back to
+// their recorded sizes and renews the cluster until expirationDate. It doesn't
+// wait for a poll in progress, a cluster being restored is still recorded as
+// scaled to zero so the poll leaves it alone.
+func (g *GKE) Restore(ctx context.Context, name string, expirationDate time.Time) error {
+	if !g.Leader.IsLeader() {
+		return ErrNotLeader
+	}
+
+	if !g.startRestore(name) {
+		return ErrRestoreInProgress
+	}
+	defer g.finishRestore(name)
+
+	record, err := g.ClusterStore.Get(ctx, provider.KindGKECluster, name)
+	if err != nil {
+		return err
+	}
+
+	if record.ActionTaken != config.ActionScaleToZero {
+		return ErrNotScaledDown
+	}
+
+	nodePools, err := g.NodePoolStore.ListByCluster(ctx, provider.KindGKECluster, name)
+	if err != nil {
+		return err
+	}
+
+	gkeClusters, err := g.listClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := g.getCluster(gkeClusters, record)
+	if err != nil {
+		return err
+	}
+
+	description := []string{}
+	for _, nodePool := range nodePools {
+		if !g.Leader.IsLeader() {
+			return ErrNotLeader
+		}
+
+		path := nodePoolPath(cluster, nodePool.Name)
+
+		callCtx, cancelCall := g.callContext(ctx)
+		op, err := g.Client.SetNodePoolSize(callCtx, &containerpb.SetNodePoolSizeRequest{
+			Name:      path,
+			NodeCount: int32(nodePool.NodeCount),
+		})
+		cancelCall()
+		if err != nil {
+			return fmt.Errorf("failed to restore the size of node pool %s: %s", nodePool.Name, err)
+		}
+
+		err = g.waitForOperation(ctx, cluster, op)
+		if err != nil {
+			return fmt.Errorf("failed to restore the size of node pool %s: %s", nodePool.Name, err)
+		}
+
+		if nodePool.Autoscaling {
+			callCtx, cancelCall := g.callContext(ctx)
+			op, err := g.Client.SetNodePoolAutoscaling(callCtx, &containerpb.SetNodePoolAutoscalingRequest{
+				Name: path,
+				Autoscaling: &containerpb.NodePoolAutoscaling{
+					Enabled:      true,
+					MinNodeCount: int32(nodePool.MinNodeCount),
+					MaxNodeCount: int32(nodePool.MaxNodeCount),
+				},
+			})
+			cancelCall()
+			if err != nil {
+				return fmt.Errorf("failed to restore autoscaling of node pool %s: %s", nodePool.Name, err)
+			}
+
+			err = g.waitForOperation(ctx, cluster, op)
+			if err != nil {
+				return fmt.Errorf("failed to restore autoscaling of node pool %s: %s", nodePool.Name, err)
+			}
+		}
+
+		description = append(description, fmt.Sprintf("%s to %d", nodePool.Name, nodePool.NodeCount))
+	}
+
+	// The cluster is renewed before it stops being recorded as scaled to
+	// zero, so that a poll never sees it expired with no action taken.
+	bookkeepingCtx := bookkeepingContext()
+	err = g.ClusterStore.DB.Transaction(bookkeepingCtx, func(tx *store.DB) error {
+		clusterStore := &store.Cluster{DB: tx}
+		err := clusterStore.UpdateExpirationDate(bookkeepingCtx, provider.KindGKECluster, name, expirationDate)
+		if err != nil {
+			return err
+		}
+
+		err = clusterStore.UpdateActionTaken(bookkeepingCtx, provider.KindGKECluster, name, "")
+		if err != nil {
+			return err
+		}
+
+		return (&store.NodePool{DB: tx}).DeleteByCluster(bookkeepingCtx, provider.KindGKECluster, name)
+	})
+	if err != nil {
+		return err
+	}
+
+	g.recordEvent(bookkeepingCtx, name, store.EventRestored, fmt.Sprintf("restored node pools %s, expires at %s", strings.Join(description, ", "), expirationDate.Format(time.RFC3339)))
+
+	return nil
+}
+
+// startRestore marks the cluster as being restored, unless it already is.
+func (g *GKE) startRestore(name string) bool {
+	g.restoreMutex.Lock()
+	defer g.restoreMutex.Unlock()
+
+	if g.restoring[name] {
+		return false
+	}
+
+	if g.restoring == nil {
+		g.restoring = map[string]bool{}
+	}
+	g.restoring[name] = true
+
+	return true
+}
+
+func (g *GKE) finishRestore(name string) {
+	g.restoreMutex.Lock()
+	defer g.restoreMutex.Unlock()
+
+	delete(g.restoring, name)
+}
+
+func (g *GKE) waitForOperation(ctx context.Context, cluster gkeCluster, op *containerpb.Operation) error {
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	name := fmt.Sprintf("projects/%s/locations/%s/operations/%s", cluster.Project, cluster.Location, op.Name)
+
+	for op.Status != containerpb.Operation_DONE {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for operation %s", op.Name)
+		case <-time.After(operationPollInterval):
+		}
+
+		callCtx, cancelCall := g.callContext(ctx)
+		current, err := g.Client.GetOperation(callCtx, &containerpb.GetOperationRequest{Name: name})
+		cancelCall()
+		if err != nil {
+			return fmt.Errorf("failed to get operation %s: %s", op.Name, err)
+		}
+		op = current
+	}
+
+	if op.StatusMessage != "" {
+		return fmt.Errorf("operation %s failed: %s", op.Name, op.StatusMessage)
+	}
+
+	return nil
+}
+
+func nodePoolPath(cluster gkeCluster, nodePool string) string {
+	return fmt.Sprintf("%s/nodePools/%s", clusterPath(cluster), nodePool)
+}
@@ -0,0 +1,185 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/nodepool"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+const (
+	zoneAGroup = "https://www.googleapis.com/compute/v1/projects/project-a/zones/us-central1-a/instanceGroupManagers/gke-dev-default-pool-1234-grp"
+	zoneBGroup = "https://www.googleapis.com/compute/v1/projects/project-a/zones/us-central1-b/instanceGroupManagers/gke-dev-default-pool-5678-grp"
+
+	defaultPoolPath = "projects/project-a/locations/us-central1-a/clusters/dev/nodePools/default-pool"
+)
+
+type notLeader struct{}
+
+func (notLeader) IsLeader() bool { return false }
+
+// newScaleTest returns a GKE poller of a fake GKE with an expired cluster
+// whose autoscaled node pool was created with one node per zone and has
+// grown to three.
+func newScaleTest(t *testing.T, sizes *nodepool.Fake) (*GKE, *fakeClusterManager, gkeCluster) {
+	g, fake := newTestGKE(t, config.Policy{ClusterLifetimeDuration: 24 * time.Hour})
+	g.NodePools = sizes
+
+	createTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	cluster := testCluster("dev", createTime, containerpb.Cluster_RUNNING)
+	cluster.NodePools = []*containerpb.NodePool{{
+		Name:              "default-pool",
+		InitialNodeCount:  1,
+		InstanceGroupUrls: []string{zoneAGroup, zoneBGroup},
+		Autoscaling:       &containerpb.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 5},
+	}}
+	fake.put("project-a", cluster)
+
+	err := g.ClusterStore.Insert(context.Background(), store.ClusterRecord{
+		Kind:           provider.KindGKECluster,
+		Name:           "dev",
+		CreateDate:     createTime,
+		ExpirationDate: createTime.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, fake, gkeCluster{Cluster: cluster, Project: "project-a"}
+}
+
+func TestScaleToZeroAndRestore(t *testing.T) {
+	g, fake, cluster := newScaleTest(t, &nodepool.Fake{Sizes: map[string]int{zoneAGroup: 3, zoneBGroup: 2}})
+	ctx := context.Background()
+
+	err := g.scaleToZero(ctx, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodePools, err := g.NodePoolStore.ListByCluster(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []store.NodePoolRecord{{
+		Kind:         provider.KindGKECluster,
+		ClusterName:  "dev",
+		Name:         "default-pool",
+		NodeCount:    3,
+		Autoscaling:  true,
+		MinNodeCount: 1,
+		MaxNodeCount: 5,
+	}}
+	if !reflect.DeepEqual(nodePools, expected) {
+		t.Errorf("expected the current size to be recorded, %+v, got %+v", expected, nodePools)
+	}
+
+	size, autoscaling := fake.nodePool(defaultPoolPath)
+	if size != 0 || autoscaling == nil || autoscaling.Enabled {
+		t.Errorf("expected the node pool to be scaled to zero without autoscaling, got %d, %v", size, autoscaling)
+	}
+
+	if record := getRecord(t, g, "dev"); record.ActionTaken != config.ActionScaleToZero {
+		t.Errorf("expected the cluster to be recorded as scaled to zero, got %q", record.ActionTaken)
+	}
+
+	// A poll in progress doesn't hold up the restore.
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	expirationDate := time.Now().Add(8 * time.Hour).Truncate(time.Second)
+	err = g.Restore(ctx, "dev", expirationDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, autoscaling = fake.nodePool(defaultPoolPath)
+	if size != 3 || autoscaling == nil || !autoscaling.Enabled || autoscaling.MinNodeCount != 1 || autoscaling.MaxNodeCount != 5 {
+		t.Errorf("expected the node pool to be restored to 3 nodes between 1 and 5, got %d, %v", size, autoscaling)
+	}
+
+	record := getRecord(t, g, "dev")
+	if record.ActionTaken != "" || !record.ExpirationDate.Equal(expirationDate) {
+		t.Errorf("expected the cluster to be renewed until %s with no action taken, got %q, expires %s", expirationDate, record.ActionTaken, record.ExpirationDate)
+	}
+
+	nodePools, err = g.NodePoolStore.ListByCluster(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodePools) != 0 {
+		t.Errorf("expected the recorded sizes to be deleted, got %+v", nodePools)
+	}
+
+	events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev")
+	expectedEvents := []string{store.EventScaledDown, store.EventRestored}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("expected events %v, got %v", expectedEvents, events)
+	}
+}
+
+func TestScaleToZeroWithoutTheCurrentSize(t *testing.T) {
+	g, fake, cluster := newScaleTest(t, &nodepool.Fake{Err: errors.New("permission denied")})
+	ctx := context.Background()
+
+	err := g.scaleToZero(ctx, cluster)
+	if err == nil {
+		t.Fatal("expected an error when the node pool size can't be read")
+	}
+
+	nodePools, err := g.NodePoolStore.ListByCluster(ctx, provider.KindGKECluster, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodePools) != 0 {
+		t.Errorf("expected no sizes to be recorded, got %+v", nodePools)
+	}
+
+	if _, autoscaling := fake.nodePool(defaultPoolPath); autoscaling != nil {
+		t.Errorf("expected the node pool to be left alone, got autoscaling %v", autoscaling)
+	}
+}
+
+func TestRestoreRefuses(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *GKE)
+		err   error
+	}{
+		{
+			name:  "a cluster that was not scaled to zero",
+			setup: func(g *GKE) {},
+			err:   ErrNotScaledDown,
+		},
+		{
+			name:  "on an instance that is not the leader",
+			setup: func(g *GKE) { g.Leader = notLeader{} },
+			err:   ErrNotLeader,
+		},
+		{
+			name:  "a cluster already being restored",
+			setup: func(g *GKE) { g.startRestore("dev") },
+			err:   ErrRestoreInProgress,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, _, _ := newScaleTest(t, &nodepool.Fake{})
+			test.setup(g)
+
+			err := g.Restore(context.Background(), "dev", time.Now().Add(time.Hour))
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
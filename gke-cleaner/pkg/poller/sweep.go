@@ -0,0 +1,172 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/janitor"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// sweepTimeout is how long a sweep is retried before it is given up.
+const sweepTimeout = 24 * time.Hour
+
+// sweepTarget records what identifies the cluster's compute resources while
+// the cluster still exists.
+func (g *GKE) sweepTarget(ctx context.Context, cluster gkeCluster) store.SweepRecord {
+	sweep := store.SweepRecord{
+		Kind:             provider.KindGKECluster,
+		ClusterName:      cluster.Name,
+		Project:          cluster.Project,
+		Location:         cluster.Location,
+		Network:          cluster.Network,
+		InstancePrefixes: []string{},
+		Zones:            append([]string{}, cluster.Locations...),
+	}
+
+	instanceGroups := []string{}
+	for _, nodePool := range cluster.NodePools {
+		for _, url := range nodePool.InstanceGroupUrls {
+			name := url[strings.LastIndex(url, "/")+1:]
+			sweep.InstancePrefixes = append(sweep.InstancePrefixes, strings.TrimSuffix(name, "-grp"))
+			instanceGroups = append(instanceGroups, url)
+		}
+	}
+
+	target := toTarget(sweep)
+	target.InstanceGroups = instanceGroups
+	tags, err := g.Janitor.Tags(ctx, target)
+	if err != nil {
+		g.Log.Error(err, "Failed to get network tags, orphaned resources will only be found by name", "cluster", cluster.Name)
+	}
+	sweep.NetworkTags = tags
+
+	return sweep
+}
+
+// sweepOrphans looks for the resources left behind by each deleted cluster
+// once its delete operation is done, and reports or deletes them.
+func (g *GKE) sweepOrphans(ctx context.Context, policy config.Policy) error {
+	if policy.OrphanCleanup == config.OrphanCleanupOff {
+		return nil
+	}
+
+	sweeps, err := g.SweepStore.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, sweep := range sweeps {
+		expired := time.Since(sweep.CreateDate) > sweepTimeout
+
+		if sweep.Operation != "" {
+			callCtx, cancelCall := g.callContext(ctx)
+			op, err := g.Client.GetOperation(callCtx, &containerpb.GetOperationRequest{
+				Name: fmt.Sprintf("projects/%s/locations/%s/operations/%s", sweep.Project, sweep.Location, sweep.Operation),
+			})
+			cancelCall()
+			if err != nil {
+				g.Log.Error(err, "Failed to get cluster delete operation", "cluster", sweep.ClusterName)
+				g.giveUpSweep(ctx, sweep, expired)
+				continue
+			}
+
+			if op.Status != containerpb.Operation_DONE {
+				g.Log.V(1).Info("Cluster is still being deleted, sweeping later", "cluster", sweep.ClusterName)
+				g.giveUpSweep(ctx, sweep, expired)
+				continue
+			}
+
+			if op.StatusMessage != "" {
+				g.Log.Info("Cluster deletion failed, not sweeping", "cluster", sweep.ClusterName, "error", op.StatusMessage)
+				g.giveUpSweep(ctx, sweep, true)
+				continue
+			}
+		}
+
+		resources, err := g.Janitor.Find(ctx, toTarget(sweep))
+		if err != nil {
+			g.Log.Error(err, "Failed to find orphaned resources", "cluster", sweep.ClusterName)
+			g.giveUpSweep(ctx, sweep, expired)
+			continue
+		}
+
+		if len(resources) > 0 && (policy.OrphanCleanup == config.OrphanCleanupReport || policy.DryRun) {
+			g.Log.Info("Found orphaned resources", "cluster", sweep.ClusterName, "count", len(resources))
+			g.recordEvent(ctx, sweep.ClusterName, store.EventOrphansFound, describeResources(resources))
+			resources = nil
+		}
+
+		deleted := []janitor.Resource{}
+		failed := false
+		notLeader := false
+		for _, resource := range resources {
+			if !g.Leader.IsLeader() {
+				notLeader = true
+				break
+			}
+
+			err = g.Janitor.Delete(ctx, resource)
+			if err != nil {
+				g.Log.Error(err, "Failed to delete orphaned resource", "cluster", sweep.ClusterName, "resource", resource.Name)
+				failed = true
+				continue
+			}
+			deleted = append(deleted, resource)
+		}
+
+		if len(deleted) > 0 {
+			g.Log.Info("Deleted orphaned resources", "cluster", sweep.ClusterName, "count", len(deleted))
+			g.recordEvent(ctx, sweep.ClusterName, store.EventOrphansDeleted, describeResources(deleted))
+		}
+
+		// The sweep is left to the new leader.
+		if notLeader {
+			return ErrNotLeader
+		}
+
+		g.giveUpSweep(ctx, sweep, !failed || expired)
+	}
+
+	return nil
+}
+
+// giveUpSweep forgets a sweep when it is done, otherwise it is retried on the
+// next poll.
+func (g *GKE) giveUpSweep(ctx context.Context, sweep store.SweepRecord, done bool) {
+	if !done {
+		return
+	}
+
+	err := g.SweepStore.Delete(ctx, sweep.ID)
+	if err != nil {
+		g.Log.Error(err, "Failed to delete sweep", "cluster", sweep.ClusterName)
+	}
+}
+
+func toTarget(sweep store.SweepRecord) janitor.Target {
+	return janitor.Target{
+		ClusterName:      sweep.ClusterName,
+		Project:          sweep.Project,
+		Location:         sweep.Location,
+		Network:          sweep.Network,
+		NetworkTags:      sweep.NetworkTags,
+		InstancePrefixes: sweep.InstancePrefixes,
+		Zones:            sweep.Zones,
+	}
+}
+
+func describeResources(resources []janitor.Resource) string {
+	description := []string{}
+	for _, resource := range resources {
+		description = append(description, resource.String())
+	}
+
+	return strings.Join(description, ", ")
+}
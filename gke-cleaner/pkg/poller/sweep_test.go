@@ -0,0 +1,194 @@
+package poller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/christianang/gke-cleaner/pkg/config"
+	"github.com/christianang/gke-cleaner/pkg/janitor"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+func TestSweepOrphans(t *testing.T) {
+	orphans := []janitor.Resource{
+		{Kind: "disk", Name: "gke-dev-pvc-1", Project: "project", Zone: "us-central1-a", Reason: "name"},
+		{Kind: "firewall", Name: "gke-dev-1a2b3c4d-all", Project: "project", Reason: "target tags"},
+	}
+
+	tests := []struct {
+		name          string
+		orphanCleanup string
+		dryRun        bool
+		deleted       []janitor.Resource
+		events        []string
+		swept         bool
+	}{
+		{
+			name:          "off",
+			orphanCleanup: config.OrphanCleanupOff,
+			deleted:       []janitor.Resource{},
+			events:        []string{},
+			swept:         false,
+		},
+		{
+			name:          "report",
+			orphanCleanup: config.OrphanCleanupReport,
+			deleted:       []janitor.Resource{},
+			events:        []string{store.EventOrphansFound},
+			swept:         true,
+		},
+		{
+			name:          "delete",
+			orphanCleanup: config.OrphanCleanupDelete,
+			deleted:       orphans,
+			events:        []string{store.EventOrphansDeleted},
+			swept:         true,
+		},
+		{
+			name:          "delete in dry run mode",
+			orphanCleanup: config.OrphanCleanupDelete,
+			dryRun:        true,
+			deleted:       []janitor.Resource{},
+			events:        []string{store.EventOrphansFound},
+			swept:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+			fakeJanitor := &janitor.Fake{
+				Resources: map[string][]janitor.Resource{"dev": orphans},
+			}
+
+			g := &GKE{
+				Log:        testLog,
+				EventStore: &store.Event{DB: db},
+				SweepStore: &store.Sweep{DB: db},
+				Janitor:    fakeJanitor,
+				Notifier:   newTestNotifier(t),
+				Leader:     alwaysLeader{},
+			}
+
+			ctx := context.Background()
+			err := g.SweepStore.Insert(ctx, store.SweepRecord{
+				ClusterName: "dev",
+				Project:     "project",
+				Location:    "us-central1",
+				NetworkTags: []string{"gke-dev-1a2b3c4d-node"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = g.sweepOrphans(ctx, config.Policy{OrphanCleanup: test.orphanCleanup, DryRun: test.dryRun})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deleted := append([]janitor.Resource{}, fakeJanitor.Deleted()...)
+			if !reflect.DeepEqual(deleted, test.deleted) {
+				t.Errorf("expected %v to be deleted, got %v", test.deleted, deleted)
+			}
+
+			events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev")
+			if !reflect.DeepEqual(events, test.events) {
+				t.Errorf("expected events %v, got %v", test.events, events)
+			}
+
+			sweeps, err := g.SweepStore.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if swept := len(sweeps) == 0; swept != test.swept {
+				t.Errorf("expected the sweep to be done %t, got %t", test.swept, swept)
+			}
+		})
+	}
+}
+
+func TestSweepOrphansWithoutOrphans(t *testing.T) {
+	db := newTestDB(t)
+	g := &GKE{
+		Log:        testLog,
+		EventStore: &store.Event{DB: db},
+		SweepStore: &store.Sweep{DB: db},
+		Janitor:    &janitor.Fake{},
+		Notifier:   newTestNotifier(t),
+		Leader:     alwaysLeader{},
+	}
+
+	ctx := context.Background()
+	err := g.SweepStore.Insert(ctx, store.SweepRecord{
+		ClusterName: "dev",
+		Project:     "project",
+		Location:    "us-central1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.sweepOrphans(ctx, config.Policy{OrphanCleanup: config.OrphanCleanupDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev")
+	if len(events) != 0 {
+		t.Errorf("expected no events without orphaned resources, got %v", events)
+	}
+
+	sweeps, err := g.SweepStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sweeps) != 0 {
+		t.Errorf("expected the sweep to be done without orphaned resources, got %v", sweeps)
+	}
+}
+
+func TestSweepOrphansStopsWhenTheLeadershipIsLost(t *testing.T) {
+	db := newTestDB(t)
+	fakeJanitor := &janitor.Fake{
+		Resources: map[string][]janitor.Resource{"dev": {
+			{Kind: "disk", Name: "gke-dev-pvc-1", Project: "project", Zone: "us-central1-a"},
+			{Kind: "disk", Name: "gke-dev-pvc-2", Project: "project", Zone: "us-central1-a"},
+		}},
+	}
+	g := &GKE{
+		Log:        testLog,
+		EventStore: &store.Event{DB: db},
+		SweepStore: &store.Sweep{DB: db},
+		Janitor:    fakeJanitor,
+		Notifier:   newTestNotifier(t),
+		Leader:     &leaderFor{checks: 1},
+	}
+
+	ctx := context.Background()
+	err := g.SweepStore.Insert(ctx, store.SweepRecord{ClusterName: "dev", Project: "project", Location: "us-central1-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.sweepOrphans(ctx, config.Policy{OrphanCleanup: config.OrphanCleanupDelete})
+	if err != ErrNotLeader {
+		t.Errorf("expected the sweep to stop with %s, got %v", ErrNotLeader, err)
+	}
+
+	if deleted := fakeJanitor.Deleted(); len(deleted) != 1 {
+		t.Errorf("expected one resource to be deleted before losing the leadership, got %v", deleted)
+	}
+	if events := eventTypes(t, g.EventStore, provider.KindGKECluster, "dev"); !reflect.DeepEqual(events, []string{store.EventOrphansDeleted}) {
+		t.Errorf("expected the deleted resource to be recorded, got %v", events)
+	}
+
+	sweeps, err := g.SweepStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sweeps) != 1 {
+		t.Errorf("expected the sweep to be left to the new leader, got %v", sweeps)
+	}
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// gkeNodeLabel is set on every node of a GKE cluster. Nodes are managed by
+// their cluster and are never treated as instances of their own.
+const gkeNodeLabel = "goog-gke-node"
+
+// ComputeInstances lists and deletes Compute Engine instances.
+type ComputeInstances struct {
+	Service  *compute.Service
+	Projects []string
+}
+
+func (c *ComputeInstances) Kind() string {
+	return KindComputeInstance
+}
+
+func (c *ComputeInstances) List(ctx context.Context) ([]Resource, error) {
+	resources := []Resource{}
+	for _, project := range c.Projects {
+		err := c.Service.Instances.AggregatedList(project).Pages(ctx, func(list *compute.InstanceAggregatedList) error {
+			for scope, scopedList := range list.Items {
+				zone := strings.TrimPrefix(scope, "zones/")
+				for _, instance := range scopedList.Instances {
+					if _, ok := instance.Labels[gkeNodeLabel]; ok || managedByGroup(instance) {
+						continue
+					}
+
+					createTime, err := time.Parse(time.RFC3339, instance.CreationTimestamp)
+					if err != nil {
+						return fmt.Errorf("failed to parse creation time of instance %s: %s", instance.Name, err)
+					}
+
+					resources = append(resources, Resource{
+						Kind:       KindComputeInstance,
+						Name:       instance.Name,
+						Project:    project,
+						Location:   zone,
+						Labels:     instance.Labels,
+						CreateTime: createTime,
+						Status:     instance.Status,
+						SelfLink:   instance.SelfLink,
+					})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances in project %s: %s", project, err)
+		}
+	}
+
+	return resources, nil
+}
+
+func (c *ComputeInstances) Delete(ctx context.Context, resource Resource) error {
+	_, err := c.Service.Instances.Delete(resource.Project, resource.Location, resource.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to delete instance %s: %s", resource.Name, err)
+	}
+
+	return nil
+}
+
+// managedByGroup reports whether an instance belongs to a managed instance
+// group, which would recreate it.
+func managedByGroup(instance *compute.Instance) bool {
+	if instance.Metadata == nil {
+		return false
+	}
+
+	for _, item := range instance.Metadata.Items {
+		if item.Key == "created-by" {
+			return true
+		}
+	}
+
+	return false
+}
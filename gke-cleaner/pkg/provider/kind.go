@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kindClusterLabel is set by kind on every node container of a cluster, its
+// value is the cluster name.
+const kindClusterLabel = "io.x-k8s.kind.cluster"
+
+const kindRoleLabel = "io.x-k8s.kind.role"
+
+// CommandRunner runs a command and returns its stdout.
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// KindClusters finds kind clusters from the docker containers of their nodes
+// and deletes them with the kind cli. The docker and kind binaries must be on
+// the PATH, DOCKER_HOST is respected.
+type KindClusters struct {
+	Run CommandRunner
+}
+
+type kindContainer struct {
+	ID      string
+	Created time.Time
+	State   struct {
+		Status string
+	}
+	Config struct {
+		Labels map[string]string
+	}
+}
+
+func (k *KindClusters) Kind() string {
+	return KindKindCluster
+}
+
+func (k *KindClusters) List(ctx context.Context) ([]Resource, error) {
+	ids, err := k.run(ctx, "docker", "ps", "--all", "--quiet", "--no-trunc", "--filter", "label="+kindClusterLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kind containers: %s", err)
+	}
+
+	if len(bytes.TrimSpace(ids)) == 0 {
+		return []Resource{}, nil
+	}
+
+	output, err := k.run(ctx, "docker", append([]string{"inspect"}, strings.Fields(string(ids))...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect kind containers: %s", err)
+	}
+
+	var containers []kindContainer
+	err = json.Unmarshal(output, &containers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse docker inspect output: %s", err)
+	}
+
+	clusters := map[string][]kindContainer{}
+	for _, container := range containers {
+		name := container.Config.Labels[kindClusterLabel]
+		clusters[name] = append(clusters[name], container)
+	}
+
+	resources := []Resource{}
+	for name, nodes := range clusters {
+		resources = append(resources, kindResource(name, nodes))
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+
+	return resources, nil
+}
+
+func (k *KindClusters) Delete(ctx context.Context, resource Resource) error {
+	_, err := k.run(ctx, "kind", "delete", "cluster", "--name", resource.Name)
+	if err != nil {
+		return fmt.Errorf("failed to delete kind cluster %s: %s", resource.Name, err)
+	}
+
+	return nil
+}
+
+func (k *KindClusters) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if k.Run != nil {
+		return k.Run(ctx, name, args...)
+	}
+
+	return runCommand(ctx, name, args...)
+}
+
+// runCommand runs a command, including its stderr in the error if it fails.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
+
+// kindResource describes a kind cluster from its node containers. The
+// cluster's labels are the control plane's, its create time is when its
+// first node was created and it is running only if every node is.
+func kindResource(name string, nodes []kindContainer) Resource {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Created.Before(nodes[j].Created)
+	})
+
+	controlPlane := nodes[0]
+	status := "running"
+	for _, node := range nodes {
+		if node.Config.Labels[kindRoleLabel] == "control-plane" {
+			controlPlane = node
+		}
+		if node.State.Status != "running" {
+			status = node.State.Status
+		}
+	}
+
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "local"
+	}
+
+	return Resource{
+		Kind:       KindKindCluster,
+		Name:       name,
+		Location:   host,
+		Labels:     controlPlane.Config.Labels,
+		CreateTime: nodes[0].Created,
+		Status:     status,
+		SelfLink:   "docker://" + controlPlane.ID,
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const kindInspectOutput = `[
+  {
+    "ID": "worker",
+    "Created": "2020-01-02T10:05:00Z",
+    "State": {"Status": "exited"},
+    "Config": {"Labels": {"io.x-k8s.kind.cluster": "dev", "io.x-k8s.kind.role": "worker"}}
+  },
+  {
+    "ID": "control-plane",
+    "Created": "2020-01-02T10:00:00Z",
+    "State": {"Status": "running"},
+    "Config": {"Labels": {"io.x-k8s.kind.cluster": "dev", "io.x-k8s.kind.role": "control-plane", "team": "data"}}
+  },
+  {
+    "ID": "other",
+    "Created": "2020-01-01T00:00:00Z",
+    "State": {"Status": "running"},
+    "Config": {"Labels": {"io.x-k8s.kind.cluster": "another", "io.x-k8s.kind.role": "control-plane"}}
+  }
+]`
+
+func TestKindClusters(t *testing.T) {
+	commands := []string{}
+	kind := &KindClusters{
+		Run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			command := strings.Join(append([]string{name}, args...), " ")
+			commands = append(commands, command)
+
+			switch {
+			case strings.HasPrefix(command, "docker ps"):
+				return []byte("worker\ncontrol-plane\nother\n"), nil
+			case command == "docker inspect worker control-plane other":
+				return []byte(kindInspectOutput), nil
+			}
+			return nil, nil
+		},
+	}
+
+	resources, err := kind.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources) != 2 || resources[0].Name != "another" || resources[1].Name != "dev" {
+		t.Fatalf("expected the another and dev clusters, got %+v", resources)
+	}
+
+	dev := resources[1]
+	expectedLabels := map[string]string{"io.x-k8s.kind.cluster": "dev", "io.x-k8s.kind.role": "control-plane", "team": "data"}
+	if !reflect.DeepEqual(dev.Labels, expectedLabels) {
+		t.Errorf("expected the control plane's labels %v, got %v", expectedLabels, dev.Labels)
+	}
+	if !dev.CreateTime.Equal(time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the first node's create time, got %s", dev.CreateTime)
+	}
+	if dev.Status != "exited" {
+		t.Errorf("expected a cluster with a stopped node not to be running, got %q", dev.Status)
+	}
+	if dev.Kind != KindKindCluster || dev.SelfLink != "docker://control-plane" {
+		t.Errorf("expected a kind cluster linked to its control plane, got %+v", dev)
+	}
+
+	err = kind.Delete(context.Background(), dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := commands[len(commands)-1]; last != "kind delete cluster --name dev" {
+		t.Errorf("expected the cluster to be deleted with kind, ran %q", last)
+	}
+}
+
+func TestKindClustersWithoutContainers(t *testing.T) {
+	kind := &KindClusters{
+		Run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			if name != "docker" || args[0] != "ps" {
+				t.Errorf("expected only docker ps to run, ran %s %v", name, args)
+			}
+			return []byte("\n"), nil
+		},
+	}
+
+	resources, err := kind.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("expected no clusters, got %+v", resources)
+	}
+}
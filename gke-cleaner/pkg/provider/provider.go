@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Resource kinds. Every resource of a kind is stored with it.
+const (
+	KindGKECluster      = "gke-cluster"
+	KindComputeInstance = "compute-instance"
+	KindKindCluster     = "kind-cluster"
+)
+
+// Resource is anything a provider can list and delete.
+type Resource struct {
+	Kind       string
+	Name       string
+	Project    string
+	Location   string
+	Labels     map[string]string
+	CreateTime time.Time
+	Status     string
+	SelfLink   string
+}
+
+type Provider interface {
+	Kind() string
+
+	// List returns every resource of the provider's kind.
+	List(ctx context.Context) ([]Resource, error)
+
+	Delete(ctx context.Context, resource Resource) error
+}
@@ -0,0 +1,135 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+// Change is a recovered record that is missing from the store, or differs
+// from it in its expiration, ignore or owner.
+type Change struct {
+	Record   store.ClusterRecord
+	Existing *store.ClusterRecord
+	Fields   []string
+}
+
+func (c Change) String() string {
+	if c.Existing == nil {
+		return fmt.Sprintf("+ %s/%s: expires at %s, ignored %t, owner %q",
+			c.Record.Kind, c.Record.Name, c.Record.ExpirationDate.Format(time.RFC3339), c.Record.Ignore, c.Record.Owner)
+	}
+
+	return fmt.Sprintf("~ %s/%s: %s", c.Record.Kind, c.Record.Name, strings.Join(c.Fields, ", "))
+}
+
+// Diff returns the changes needed to bring the existing records in line with
+// the recovered ones. Existing records that were not recovered are left
+// alone.
+func Diff(existing []store.ClusterRecord, recovered []store.ClusterRecord) []Change {
+	existingMap := map[string]*store.ClusterRecord{}
+	for i := range existing {
+		existingMap[existing[i].Key()] = &existing[i]
+	}
+
+	changes := []Change{}
+	for _, record := range recovered {
+		current, found := existingMap[record.Key()]
+		if !found {
+			changes = append(changes, Change{Record: record})
+			continue
+		}
+
+		fields := diffFields(*current, record)
+		if len(fields) > 0 {
+			changes = append(changes, Change{Record: record, Existing: current, Fields: fields})
+		}
+	}
+
+	return changes
+}
+
+// Apply writes the changes to the store and records a recovered event for
+// each of them, all in one transaction so that a failure leaves the store as
+// it was.
+func Apply(ctx context.Context, db *store.DB, changes []Change, source string) error {
+	return db.Transaction(ctx, func(tx *store.DB) error {
+		return apply(ctx, &store.Cluster{DB: tx}, &store.Event{DB: tx}, changes, source)
+	})
+}
+
+func apply(ctx context.Context, clusterStore *store.Cluster, eventStore *store.Event, changes []Change, source string) error {
+	for _, change := range changes {
+		record := change.Record
+
+		if change.Existing == nil {
+			err := clusterStore.Insert(ctx, record)
+			if err != nil {
+				return fmt.Errorf("failed to insert %s: %s", record.Name, err)
+			}
+		} else {
+			err := clusterStore.UpdateExpirationDate(ctx, record.Kind, record.Name, record.ExpirationDate)
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %s", record.Name, err)
+			}
+			err = clusterStore.UpdateOwner(ctx, record.Kind, record.Name, record.Owner, record.OwnerSource)
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %s", record.Name, err)
+			}
+		}
+
+		err := clusterStore.UpdateIgnore(ctx, record.Kind, record.Name, record.Ignore, record.IgnoreUntil, record.IgnoreReason, record.IgnoredBy, record.IgnoreDate)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %s", record.Name, err)
+		}
+
+		err = eventStore.Insert(ctx, record.Kind, record.Name, store.EventRecovered, fmt.Sprintf("from %s, expires at %s", source, record.ExpirationDate.Format(time.RFC3339)))
+		if err != nil {
+			return fmt.Errorf("failed to record event for %s: %s", record.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func diffFields(current store.ClusterRecord, recovered store.ClusterRecord) []string {
+	fields := []string{}
+
+	if !sameTime(current.ExpirationDate, recovered.ExpirationDate) {
+		fields = append(fields, fmt.Sprintf("expires at %s -> %s", formatTime(current.ExpirationDate), formatTime(recovered.ExpirationDate)))
+	}
+	if current.Ignore != recovered.Ignore || !sameTime(current.IgnoreUntil, recovered.IgnoreUntil) || current.IgnoreReason != recovered.IgnoreReason {
+		fields = append(fields, fmt.Sprintf("ignore %s -> %s", describeIgnore(current), describeIgnore(recovered)))
+	}
+	if current.Owner != recovered.Owner {
+		fields = append(fields, fmt.Sprintf("owner %q -> %q", current.Owner, recovered.Owner))
+	}
+
+	return fields
+}
+
+func describeIgnore(record store.ClusterRecord) string {
+	if !record.Ignore {
+		return "false"
+	}
+	if record.IgnoreUntil.IsZero() {
+		return fmt.Sprintf("true (%q)", record.IgnoreReason)
+	}
+
+	return fmt.Sprintf("until %s (%q)", formatTime(record.IgnoreUntil), record.IgnoreReason)
+}
+
+func sameTime(a time.Time, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,115 @@
+package recovery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+var expirationDate = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestDiff(t *testing.T) {
+	existing := []store.ClusterRecord{
+		{Kind: "gke-cluster", Name: "same", ExpirationDate: expirationDate},
+		{Kind: "gke-cluster", Name: "renewed", ExpirationDate: expirationDate},
+		{Kind: "compute-instance", Name: "dev", ExpirationDate: expirationDate},
+		{Kind: "gke-cluster", Name: "not-recovered", ExpirationDate: expirationDate},
+	}
+	recovered := []store.ClusterRecord{
+		{Kind: "gke-cluster", Name: "same", ExpirationDate: expirationDate.Add(time.Millisecond)},
+		{Kind: "gke-cluster", Name: "renewed", ExpirationDate: expirationDate.Add(time.Hour), Ignore: true, IgnoreReason: "demo", Owner: "jane"},
+		{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate},
+	}
+
+	changes := Diff(existing, recovered)
+
+	expected := []string{
+		`~ gke-cluster/renewed: expires at 2030-01-02T03:04:05Z -> 2030-01-02T04:04:05Z, ignore false -> true ("demo"), owner "" -> "jane"`,
+		`+ gke-cluster/dev: expires at 2030-01-02T03:04:05Z, ignored false, owner ""`,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %v", len(expected), changes)
+	}
+	for i, change := range changes {
+		if change.String() != expected[i] {
+			t.Errorf("expected change %q, got %q", expected[i], change.String())
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-recovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = (&migrate.DB{Log: zapr.NewLogger(zap.NewNop()), DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	clusterStore := &store.Cluster{DB: db}
+	eventStore := &store.Event{DB: db}
+
+	err = clusterStore.Insert(ctx, store.ClusterRecord{Kind: "gke-cluster", Name: "renewed", ExpirationDate: expirationDate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := clusterStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := Diff(existing, []store.ClusterRecord{
+		{Kind: "gke-cluster", Name: "renewed", ExpirationDate: expirationDate.Add(time.Hour), Owner: "jane"},
+		{Kind: "gke-cluster", Name: "lost", ExpirationDate: expirationDate, Ignore: true, IgnoreReason: "demo"},
+	})
+
+	err = Apply(ctx, db, changes, "backup.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := clusterStore.Get(ctx, "gke-cluster", "renewed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.ExpirationDate.Equal(expirationDate.Add(time.Hour)) || renewed.Owner != "jane" {
+		t.Errorf("expected the stored cluster to be updated, got %+v", renewed)
+	}
+
+	lost, err := clusterStore.Get(ctx, "gke-cluster", "lost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lost.Ignore || lost.IgnoreReason != "demo" {
+		t.Errorf("expected the missing cluster to be inserted ignored, got %+v", lost)
+	}
+
+	for _, name := range []string{"renewed", "lost"} {
+		events, err := eventStore.ListByCluster(ctx, "gke-cluster", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Type != store.EventRecovered || !strings.HasPrefix(events[0].Message, "from backup.json") {
+			t.Errorf("expected a recovered event for %s, got %+v", name, events)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Holiday is a range of time during which clusters are never deleted.
+type Holiday struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// ParseICal reads the VEVENTs of an iCal calendar as holidays. All day events
+// are taken to be in loc. Recurrence rules are not supported, every occurrence
+// must be its own event.
+func ParseICal(r io.Reader, loc *time.Location) ([]Holiday, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays := []Holiday{}
+	var event *Holiday
+	allDay := false
+
+	for i, line := range lines {
+		name, params, value := splitProperty(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &Holiday{}
+			allDay = false
+		case name == "END" && value == "VEVENT":
+			if event == nil || event.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event has no DTSTART", i+1)
+			}
+			if event.End.IsZero() {
+				if allDay {
+					event.End = event.Start.AddDate(0, 0, 1)
+				} else {
+					event.End = event.Start
+				}
+			}
+			holidays = append(holidays, *event)
+			event = nil
+		case event == nil:
+			continue
+		case name == "SUMMARY":
+			event.Name = value
+		case name == "DTSTART":
+			event.Start, allDay, err = parseICalTime(params, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		case name == "DTEND":
+			event.End, _, err = parseICalTime(params, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		case name == "RRULE":
+			return nil, fmt.Errorf("line %d: recurring events are not supported", i+1)
+		}
+	}
+
+	return holidays, nil
+}
+
+// unfoldLines joins lines that iCal folded by starting the continuation with
+// a space or tab.
+func unfoldLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+func splitProperty(line string) (string, map[string]string, string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = kv[1]
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+func parseICalTime(params map[string]string, value string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	if tzid, ok := params["TZID"]; ok {
+		tz, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q", tzid)
+		}
+		loc = tz
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
@@ -0,0 +1,157 @@
+package schedule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"  Day\r\n" +
+	"DTSTART;VALUE=DATE:20201225\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company offsite\r\n" +
+	"DTSTART;VALUE=DATE:20200901\r\n" +
+	"DTEND;VALUE=DATE:20200904\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Migration freeze\r\n" +
+	"DTSTART:20200610T080000Z\r\n" +
+	"DTEND:20200610T120000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Launch\r\n" +
+	"DTSTART;TZID=America/New_York:20200615T090000\r\n" +
+	"DTEND;TZID=America/New_York:20200615T170000\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICal(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holidays, err := ParseICal(strings.NewReader(calendar), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Holiday{
+		{Name: "Christmas Day", Start: time.Date(2020, 12, 25, 0, 0, 0, 0, berlin), End: time.Date(2020, 12, 26, 0, 0, 0, 0, berlin)},
+		{Name: "Company offsite", Start: time.Date(2020, 9, 1, 0, 0, 0, 0, berlin), End: time.Date(2020, 9, 4, 0, 0, 0, 0, berlin)},
+		{Name: "Migration freeze", Start: at("2020-06-10T08:00:00Z"), End: at("2020-06-10T12:00:00Z")},
+		{Name: "Launch", Start: time.Date(2020, 6, 15, 9, 0, 0, 0, newYork), End: time.Date(2020, 6, 15, 17, 0, 0, 0, newYork)},
+	}
+	if len(holidays) != len(expected) {
+		t.Fatalf("expected %d holidays, got %+v", len(expected), holidays)
+	}
+	for i := range expected {
+		if holidays[i].Name != expected[i].Name || !holidays[i].Start.Equal(expected[i].Start) || !holidays[i].End.Equal(expected[i].End) {
+			t.Errorf("expected holiday %d to be %+v, got %+v", i, expected[i], holidays[i])
+		}
+	}
+}
+
+func TestParseICalIgnoresTimezoneRules(t *testing.T) {
+	contents := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTIMEZONE\r\n" +
+		"TZID:Europe/Berlin\r\n" +
+		"BEGIN:DAYLIGHT\r\n" +
+		"DTSTART:19700329T020000\r\n" +
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n" +
+		"END:DAYLIGHT\r\n" +
+		"END:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Christmas Day\r\n" +
+		"DTSTART;VALUE=DATE:20201225\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	holidays, err := ParseICal(strings.NewReader(contents), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 1 || !holidays[0].Start.Equal(at("2020-12-25T00:00:00Z")) || !holidays[0].End.Equal(at("2020-12-26T00:00:00Z")) {
+		t.Errorf("expected only the Christmas holiday, got %+v", holidays)
+	}
+}
+
+func TestParseICalErrors(t *testing.T) {
+	tests := map[string]string{
+		"recurring": "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20201225\nRRULE:FREQ=YEARLY\nEND:VEVENT\n",
+		"no start":  "BEGIN:VEVENT\nSUMMARY:Someday\nEND:VEVENT\n",
+		"bad date":  "BEGIN:VEVENT\nDTSTART:2020-12-25\nEND:VEVENT\n",
+		"bad tzid":  "BEGIN:VEVENT\nDTSTART;TZID=Mars/Olympus:20201225T090000\nEND:VEVENT\n",
+	}
+	expected := map[string]string{
+		"recurring": "line 3: recurring events are not supported",
+		"no start":  "line 3: event has no DTSTART",
+		"bad date":  "line 2: ",
+		"bad tzid":  `line 2: unknown TZID "Mars/Olympus"`,
+	}
+
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseICal(strings.NewReader(contents), time.UTC)
+			if err == nil || !strings.HasPrefix(err.Error(), expected[name]) {
+				t.Errorf("expected an error starting with %q, got %v", expected[name], err)
+			}
+		})
+	}
+}
+
+func TestNewSkipsHolidays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-cleaner-schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "holidays.ics")
+	err = ioutil.WriteFile(path, []byte(calendar), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := New([]string{"* 00:00-24:00"}, "Europe/Berlin", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schedule.Allows(at("2020-12-25T12:00:00Z")) {
+		t.Errorf("expected deletion to be refused on a holiday")
+	}
+	if !schedule.Allows(at("2020-12-25T23:30:00Z")) {
+		t.Errorf("expected deletion to be allowed once the holiday ends in Berlin")
+	}
+	if next := schedule.Next(at("2020-09-02T12:00:00Z")); !next.Equal(at("2020-09-03T22:00:00Z")) {
+		t.Errorf("expected the next deletion at the end of the offsite, got %s", next)
+	}
+
+	_, err = New(nil, "UTC", filepath.Join(dir, "missing.ics"))
+	if err == nil || !strings.Contains(err.Error(), "failed to open holiday calendar") {
+		t.Errorf("expected a missing calendar to be an error, got %v", err)
+	}
+
+	_, err = New(nil, "Mars/Olympus", "")
+	if err == nil || !strings.Contains(err.Error(), `failed to load timezone "Mars/Olympus"`) {
+		t.Errorf("expected an unknown timezone to be an error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(schedule.Windows[0].Days, [7]bool{true, true, true, true, true, true, true}) {
+		t.Errorf("expected every day to be in the window, got %v", schedule.Windows[0].Days)
+	}
+}
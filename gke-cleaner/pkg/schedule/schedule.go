@@ -0,0 +1,279 @@
+package schedule
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// horizon is how far ahead Next looks for a time deletions are allowed.
+const horizon = 400
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a time range on some days of the week. A window whose end is
+// before its start runs past midnight into the next day.
+type Window struct {
+	Days  [7]bool
+	Start int
+	End   int
+
+	spec string
+}
+
+// Schedule restricts when clusters may be deleted. A nil Schedule, or one
+// without windows or holidays, allows deletion at any time.
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location
+	Holidays []Holiday
+}
+
+// New builds a schedule from window specs such as "mon-fri 20:00-07:00" or
+// "sat,sun 00:00-24:00", a timezone name and an optional iCal file of holidays.
+func New(windowSpecs []string, timezone string, calendarPath string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %q: %s", timezone, err)
+	}
+
+	schedule := &Schedule{Location: location}
+
+	for _, spec := range windowSpecs {
+		window, err := ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+
+	if calendarPath != "" {
+		f, err := os.Open(calendarPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open holiday calendar: %s", err)
+		}
+		defer f.Close()
+
+		schedule.Holidays, err = ParseICal(f, location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse holiday calendar %s: %s", calendarPath, err)
+		}
+	}
+
+	return schedule, nil
+}
+
+func ParseWindow(spec string) (Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return Window{}, fmt.Errorf("deletion window %q must be of the form <days> <HH:MM>-<HH:MM>", spec)
+	}
+
+	window := Window{spec: spec}
+
+	for _, part := range strings.Split(strings.ToLower(fields[0]), ",") {
+		if part == "*" {
+			for i := range window.Days {
+				window.Days[i] = true
+			}
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return Window{}, fmt.Errorf("deletion window %q has an unknown day %q", spec, bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[bounds[1]]
+			if !ok {
+				return Window{}, fmt.Errorf("deletion window %q has an unknown day %q", spec, bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			window.Days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	hours := strings.SplitN(fields[1], "-", 2)
+	if len(hours) != 2 {
+		return Window{}, fmt.Errorf("deletion window %q must have a time range of the form HH:MM-HH:MM", spec)
+	}
+
+	var err error
+	window.Start, err = parseClock(hours[0])
+	if err != nil {
+		return Window{}, fmt.Errorf("deletion window %q: %s", spec, err)
+	}
+	window.End, err = parseClock(hours[1])
+	if err != nil {
+		return Window{}, fmt.Errorf("deletion window %q: %s", spec, err)
+	}
+	if window.Start == window.End {
+		return Window{}, fmt.Errorf("deletion window %q is empty", spec)
+	}
+
+	return window, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight. 24:00 is allowed as the
+// end of a day.
+func parseClock(clock string) (int, error) {
+	parts := strings.SplitN(clock, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("time %q must be of the form HH:MM", clock)
+	}
+
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("time %q has an invalid hour", clock)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("time %q has invalid minutes", clock)
+	}
+
+	minutes := hour*60 + minute
+	if hour < 0 || minute < 0 || minute > 59 || minutes > 24*60 {
+		return 0, fmt.Errorf("time %q is out of range", clock)
+	}
+
+	return minutes, nil
+}
+
+func (w Window) String() string {
+	return w.spec
+}
+
+// contains reports whether the window covers the given weekday and minute of
+// the day.
+func (w Window) contains(day time.Weekday, minute int) bool {
+	if w.Start < w.End {
+		return w.Days[day] && minute >= w.Start && minute < w.End
+	}
+
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && minute >= w.Start) || (w.Days[yesterday] && minute < w.End)
+}
+
+// Allows reports whether clusters may be deleted at t.
+func (s *Schedule) Allows(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	t = t.In(s.Location)
+
+	for _, holiday := range s.Holidays {
+		if !t.Before(holiday.Start) && t.Before(holiday.End) {
+			return false
+		}
+	}
+
+	if len(s.Windows) == 0 {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range s.Windows {
+		if window.contains(t.Weekday(), minute) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the earliest time at or after t when clusters may be deleted,
+// or the zero time if there is none within the next year.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.Allows(t) {
+		return t
+	}
+
+	local := t.In(s.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.Location)
+
+	for day := 0; day < horizon; day++ {
+		date := midnight.AddDate(0, 0, day)
+
+		// Deletions can only become allowed at midnight, when a window opens
+		// or when a holiday ends.
+		candidates := []time.Time{date}
+		for _, window := range s.Windows {
+			candidates = append(candidates, time.Date(date.Year(), date.Month(), date.Day(), window.Start/60, window.Start%60, 0, 0, s.Location))
+		}
+		for _, holiday := range s.Holidays {
+			if !holiday.End.Before(date) && holiday.End.Before(date.AddDate(0, 0, 1)) {
+				candidates = append(candidates, holiday.End)
+			}
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Before(candidates[j])
+		})
+
+		for _, candidate := range candidates {
+			if candidate.After(t) && s.Allows(candidate) {
+				return candidate
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) Equal(other *Schedule) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+
+	if s.Location.String() != other.Location.String() || len(s.Windows) != len(other.Windows) || len(s.Holidays) != len(other.Holidays) {
+		return false
+	}
+
+	for i := range s.Windows {
+		if s.Windows[i] != other.Windows[i] {
+			return false
+		}
+	}
+
+	for i := range s.Holidays {
+		a, b := s.Holidays[i], other.Holidays[i]
+		if a.Name != b.Name || !a.Start.Equal(b.Start) || !a.End.Equal(b.End) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String describes the schedule for config diffs.
+func (s *Schedule) String() string {
+	if s == nil {
+		return "always"
+	}
+
+	windows := []string{}
+	for _, window := range s.Windows {
+		windows = append(windows, window.String())
+	}
+
+	return fmt.Sprintf("windows %v in %s, %d holidays", windows, s.Location, len(s.Holidays))
+}
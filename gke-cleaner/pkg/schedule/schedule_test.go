@@ -0,0 +1,254 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := map[string]struct {
+		days  []time.Weekday
+		start int
+		end   int
+	}{
+		"mon-fri 20:00-07:00":   {days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, start: 20 * 60, end: 7 * 60},
+		"sat,sun 00:00-24:00":   {days: []time.Weekday{time.Saturday, time.Sunday}, start: 0, end: 24 * 60},
+		"fri-mon 22:30-23:45":   {days: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}, start: 22*60 + 30, end: 23*60 + 45},
+		"* 01:00-02:00":         {days: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, start: 60, end: 120},
+		"Wed,mon-tue 9:05-9:10": {days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday}, start: 9*60 + 5, end: 9*60 + 10},
+	}
+
+	for spec, test := range tests {
+		t.Run(spec, func(t *testing.T) {
+			window, err := ParseWindow(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var days [7]bool
+			for _, day := range test.days {
+				days[day] = true
+			}
+			if window.Days != days || window.Start != test.start || window.End != test.end {
+				t.Errorf("expected days %v from %d to %d, got %+v", days, test.start, test.end, window)
+			}
+			if window.String() != spec {
+				t.Errorf("expected the window to be described as %q, got %q", spec, window.String())
+			}
+		})
+	}
+}
+
+func TestParseWindowErrors(t *testing.T) {
+	tests := map[string]string{
+		"mon-fri":              "must be of the form <days> <HH:MM>-<HH:MM>",
+		"mon-fri 20:00 07:00":  "must be of the form <days> <HH:MM>-<HH:MM>",
+		"mon-fry 20:00-07:00":  `unknown day "fry"`,
+		"funday 20:00-07:00":   `unknown day "funday"`,
+		"mon 20:00":            "must have a time range of the form HH:MM-HH:MM",
+		"mon 2000-0700":        `time "2000" must be of the form HH:MM`,
+		"mon 20-07:00":         `time "20" must be of the form HH:MM`,
+		"mon 20:00-07:60":      `time "07:60" is out of range`,
+		"mon 20:00-24:01":      `time "24:01" is out of range`,
+		"mon aa:00-07:00":      `time "aa:00" has an invalid hour`,
+		"mon 20:00-07:00:00":   `time "07:00:00" has invalid minutes`,
+		"mon 08:00-08:00":      "is empty",
+		"mon,sat 00:00-00:00":  "is empty",
+		"mon,sat 00:00--01:00": `time "-01:00" is out of range`,
+	}
+
+	for spec, expected := range tests {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseWindow(spec)
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected an error containing %q, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	schedule, err := New([]string{"mon-fri 20:00-07:00", "sat 10:00-12:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2020-06-01 is a Monday.
+	tests := map[string]bool{
+		"2020-06-01T19:59:00Z": false,
+		"2020-06-01T20:00:00Z": true,
+		"2020-06-01T23:59:00Z": true,
+		"2020-06-02T06:59:00Z": true,
+		"2020-06-02T07:00:00Z": false,
+		"2020-06-01T06:00:00Z": false, // Sunday night is not in the window.
+		"2020-06-05T23:00:00Z": true,
+		"2020-06-06T06:00:00Z": true, // Friday night runs into Saturday.
+		"2020-06-06T09:00:00Z": false,
+		"2020-06-06T11:00:00Z": true,
+		"2020-06-06T12:00:00Z": false,
+		"2020-06-07T02:00:00Z": false,
+	}
+
+	for value, expected := range tests {
+		if allowed := schedule.Allows(at(value)); allowed != expected {
+			t.Errorf("expected Allows(%s) to be %t", value, expected)
+		}
+	}
+}
+
+func TestAllowsAcrossTheEndOfTheWeek(t *testing.T) {
+	schedule, err := New([]string{"sat,sun 22:00-02:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2020-06-06 is a Saturday.
+	tests := map[string]bool{
+		"2020-06-06T01:00:00Z": false, // Friday night is not in the window.
+		"2020-06-06T22:00:00Z": true,
+		"2020-06-07T01:59:00Z": true, // Saturday night runs into Sunday.
+		"2020-06-07T02:00:00Z": false,
+		"2020-06-07T23:00:00Z": true,
+		"2020-06-08T01:00:00Z": true, // Sunday night runs into Monday.
+		"2020-06-08T22:00:00Z": false,
+		"2020-06-09T01:00:00Z": false,
+	}
+
+	for value, expected := range tests {
+		if allowed := schedule.Allows(at(value)); allowed != expected {
+			t.Errorf("expected Allows(%s) to be %t", value, expected)
+		}
+	}
+}
+
+func TestAllowsInTimezone(t *testing.T) {
+	schedule, err := New([]string{"mon 09:00-10:00"}, "America/New_York", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !schedule.Allows(at("2020-06-01T13:30:00Z")) {
+		t.Errorf("expected 09:30 in New York to be allowed")
+	}
+	if schedule.Allows(at("2020-06-01T09:30:00Z")) {
+		t.Errorf("expected 05:30 in New York not to be allowed")
+	}
+}
+
+func TestAllowsWithoutRestrictions(t *testing.T) {
+	var none *Schedule
+	if !none.Allows(at("2020-06-01T12:00:00Z")) {
+		t.Errorf("expected a nil schedule to allow deletion")
+	}
+
+	empty, err := New(nil, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty.Allows(at("2020-06-01T12:00:00Z")) {
+		t.Errorf("expected a schedule without windows to allow deletion")
+	}
+}
+
+func TestNext(t *testing.T) {
+	schedule, err := New([]string{"mon-fri 20:00-07:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Holidays = []Holiday{
+		{Name: "Long weekend", Start: at("2020-06-05T00:00:00Z"), End: at("2020-06-09T03:00:00Z")},
+	}
+
+	tests := map[string]string{
+		// Already allowed.
+		"2020-06-01T21:00:00Z": "2020-06-01T21:00:00Z",
+		// The window opens in the evening.
+		"2020-06-01T12:00:00Z": "2020-06-01T20:00:00Z",
+		// The Sunday is not in the window, Monday's opens in the evening.
+		"2020-05-31T12:00:00Z": "2020-06-01T20:00:00Z",
+		// The holiday ends during Monday night's window.
+		"2020-06-04T12:00:00Z": "2020-06-04T20:00:00Z",
+		"2020-06-05T01:00:00Z": "2020-06-09T03:00:00Z",
+	}
+
+	for value, expected := range tests {
+		if next := schedule.Next(at(value)); !next.Equal(at(expected)) {
+			t.Errorf("expected Next(%s) to be %s, got %s", value, expected, next)
+		}
+	}
+}
+
+func TestNextAcrossMidnightInTimezone(t *testing.T) {
+	schedule, err := New([]string{"fri 22:00-02:00"}, "Europe/Berlin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2020-06-05 is a Friday, Berlin is two hours ahead of UTC.
+	tests := map[string]string{
+		"2020-06-05T12:00:00Z": "2020-06-05T20:00:00Z",
+		"2020-06-05T23:30:00Z": "2020-06-05T23:30:00Z",
+		"2020-06-06T00:00:00Z": "2020-06-12T20:00:00Z",
+		"2020-06-06T01:00:00Z": "2020-06-12T20:00:00Z",
+	}
+
+	for value, expected := range tests {
+		if next := schedule.Next(at(value)); !next.Equal(at(expected)) {
+			t.Errorf("expected Next(%s) to be %s, got %s", value, expected, next)
+		}
+	}
+}
+
+func TestNextWithinHorizon(t *testing.T) {
+	schedule, err := New([]string{"mon 09:00-10:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule.Holidays = []Holiday{
+		{Name: "Sabbatical", Start: at("2020-06-01T00:00:00Z"), End: at("2021-06-01T00:00:00Z")},
+	}
+
+	if next := schedule.Next(at("2020-06-01T12:00:00Z")); !next.Equal(at("2021-06-07T09:00:00Z")) {
+		t.Errorf("expected the first window after the holiday, got %s", next)
+	}
+
+	schedule.Holidays[0].End = at("2021-08-01T00:00:00Z")
+	if next := schedule.Next(at("2020-06-01T12:00:00Z")); !next.IsZero() {
+		t.Errorf("expected no time beyond the %d day horizon, got %s", horizon, next)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a, err := New([]string{"mon-fri 20:00-07:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New([]string{"mon-fri 20:00-07:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New([]string{"mon-fri 21:00-07:00"}, "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var none *Schedule
+	if !a.Equal(b) || a.Equal(other) || a.Equal(none) || !none.Equal(nil) {
+		t.Errorf("expected schedules to be equal only when their windows, timezone and holidays are")
+	}
+
+	b.Holidays = []Holiday{{Name: "Holiday", Start: at("2020-06-01T00:00:00Z"), End: at("2020-06-02T00:00:00Z")}}
+	if a.Equal(b) {
+		t.Errorf("expected schedules with different holidays to differ")
+	}
+}
@@ -0,0 +1,498 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/api"
+	"github.com/christianang/gke-cleaner/pkg/provider"
+	"github.com/christianang/gke-cleaner/pkg/store"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+
+	StrategySkip      = "skip"
+	StrategyOverwrite = "overwrite"
+	StrategyMerge     = "merge"
+)
+
+var ErrConflict = errors.New("conflicting clusters")
+
+var csvHeader = []string{
+	"Kind",
+	"Name",
+	"CreateDate",
+	"ExpirationDate",
+	"Ignore",
+	"IgnoreUntil",
+	"IgnoreReason",
+	"IgnoredBy",
+	"IgnoreDate",
+	"Status",
+	"Labels",
+	"SelfLink",
+	"Project",
+	"Location",
+	"ExpiryAction",
+	"ActionTaken",
+	"Owner",
+	"OwnerSource",
+	"IdleSince",
+	"HourlyCost",
+	"RenewalCount",
+	"RenewalExtension",
+	"ExpirationBeforeIdle",
+}
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q: must be one of json, csv", format)
+	}
+}
+
+func ValidateStrategy(strategy string) error {
+	switch strategy {
+	case StrategySkip, StrategyOverwrite, StrategyMerge:
+		return nil
+	default:
+		return fmt.Errorf("unknown conflict strategy %q: must be one of skip, overwrite, merge", strategy)
+	}
+}
+
+// FormatFor returns the format of a file from its extension, defaulting to
+// json.
+func FormatFor(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+
+	return FormatJSON
+}
+
+func ToAPI(record store.ClusterRecord) api.ExportedCluster {
+	return api.ExportedCluster{
+		Kind:           record.Kind,
+		Name:           record.Name,
+		CreateDate:     record.CreateDate,
+		ExpirationDate: record.ExpirationDate,
+		Ignore:         record.Ignore,
+		IgnoreUntil:    record.IgnoreUntil,
+		IgnoreReason:   record.IgnoreReason,
+		IgnoredBy:      record.IgnoredBy,
+		IgnoreDate:     record.IgnoreDate,
+		Status:         record.Status,
+		Labels:         record.Labels,
+		SelfLink:       record.SelfLink,
+		Project:        record.Project,
+		Location:       record.Location,
+		ExpiryAction:   record.ExpiryAction,
+		ActionTaken:    record.ActionTaken,
+		Owner:          record.Owner,
+		OwnerSource:    record.OwnerSource,
+		IdleSince:      record.IdleSince,
+		HourlyCost:     record.HourlyCost,
+
+		ExpirationBeforeIdle: record.ExpirationBeforeIdle,
+
+		RenewalCount:     record.RenewalCount,
+		RenewalExtension: record.RenewalExtension,
+	}
+}
+
+func FromAPI(cluster api.ExportedCluster) store.ClusterRecord {
+	kind := cluster.Kind
+	if kind == "" {
+		kind = provider.KindGKECluster
+	}
+
+	return store.ClusterRecord{
+		Kind:           kind,
+		Name:           cluster.Name,
+		CreateDate:     cluster.CreateDate,
+		ExpirationDate: cluster.ExpirationDate,
+		Ignore:         cluster.Ignore,
+		IgnoreUntil:    cluster.IgnoreUntil,
+		IgnoreReason:   cluster.IgnoreReason,
+		IgnoredBy:      cluster.IgnoredBy,
+		IgnoreDate:     cluster.IgnoreDate,
+		Status:         cluster.Status,
+		Labels:         cluster.Labels,
+		SelfLink:       cluster.SelfLink,
+		Project:        cluster.Project,
+		Location:       cluster.Location,
+		ExpiryAction:   cluster.ExpiryAction,
+		ActionTaken:    cluster.ActionTaken,
+		Owner:          cluster.Owner,
+		OwnerSource:    cluster.OwnerSource,
+		IdleSince:      cluster.IdleSince,
+		HourlyCost:     cluster.HourlyCost,
+
+		ExpirationBeforeIdle: cluster.ExpirationBeforeIdle,
+
+		RenewalCount:     cluster.RenewalCount,
+		RenewalExtension: cluster.RenewalExtension,
+	}
+}
+
+func Write(w io.Writer, format string, records []store.ClusterRecord) error {
+	if format == FormatCSV {
+		return writeCSV(w, records)
+	}
+
+	clusters := []api.ExportedCluster{}
+	for _, record := range records {
+		clusters = append(clusters, ToAPI(record))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(clusters)
+}
+
+// Read reads the records of an export. A json export may also be a listing
+// of GET /clusters.
+func Read(r io.Reader, format string) ([]store.ClusterRecord, error) {
+	var clusters []api.ExportedCluster
+	if format == FormatCSV {
+		var err error
+		clusters, err = readCSV(r)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := json.NewDecoder(r).Decode(&clusters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode export: %s", err)
+		}
+	}
+
+	records := []store.ClusterRecord{}
+	for _, cluster := range clusters {
+		if cluster.Name == "" {
+			return nil, fmt.Errorf("export contains a cluster without a name")
+		}
+
+		records = append(records, FromAPI(cluster))
+	}
+
+	return records, nil
+}
+
+// Import upserts the records into the store. A record conflicts with a stored
+// one of the same kind and name and is then skipped, overwritten or merged
+// with it. The records are written in one transaction, so nothing is written
+// if a record appears twice or any write fails.
+func Import(ctx context.Context, clusterStore *store.Cluster, records []store.ClusterRecord, strategy string) (api.ImportResponse, error) {
+	response := api.ImportResponse{Inserted: []string{}, Updated: []string{}, Skipped: []string{}}
+
+	seen := map[string]bool{}
+	for _, record := range records {
+		if seen[record.Key()] {
+			return response, fmt.Errorf("%w: %s appears more than once", ErrConflict, record.Key())
+		}
+		seen[record.Key()] = true
+	}
+
+	err := clusterStore.DB.Transaction(ctx, func(tx *store.DB) error {
+		var err error
+		response, err = importRecords(ctx, &store.Cluster{DB: tx}, records, strategy)
+		return err
+	})
+
+	return response, err
+}
+
+func importRecords(ctx context.Context, clusterStore *store.Cluster, records []store.ClusterRecord, strategy string) (api.ImportResponse, error) {
+	response := api.ImportResponse{Inserted: []string{}, Updated: []string{}, Skipped: []string{}}
+
+	existing, err := clusterStore.List(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	existingMap := map[string]store.ClusterRecord{}
+	for _, record := range existing {
+		existingMap[record.Key()] = record
+	}
+
+	for _, record := range records {
+		current, found := existingMap[record.Key()]
+		if !found {
+			err = clusterStore.Insert(ctx, record)
+			if err != nil {
+				return response, fmt.Errorf("failed to insert %s: %s", record.Name, err)
+			}
+			response.Inserted = append(response.Inserted, record.Name)
+			continue
+		}
+
+		updated := record
+		switch strategy {
+		case StrategySkip:
+			response.Skipped = append(response.Skipped, record.Name)
+			continue
+		case StrategyMerge:
+			updated = merge(current, record)
+			if sameRecord(current, updated) {
+				response.Skipped = append(response.Skipped, record.Name)
+				continue
+			}
+		}
+
+		err = clusterStore.Update(ctx, updated)
+		if err != nil {
+			return response, fmt.Errorf("failed to update %s: %s", record.Name, err)
+		}
+		response.Updated = append(response.Updated, record.Name)
+	}
+
+	return response, nil
+}
+
+// merge keeps the later expiration and the longer ignore of the two records,
+// and fills in the fields that are empty in the existing record. Existing
+// labels win over imported ones.
+func merge(existing store.ClusterRecord, imported store.ClusterRecord) store.ClusterRecord {
+	merged := existing
+
+	if imported.ExpirationDate.After(merged.ExpirationDate) {
+		merged.ExpirationDate = imported.ExpirationDate
+	}
+	if imported.Ignore && (!existing.Ignore || !existing.IgnoreUntil.IsZero() && (imported.IgnoreUntil.IsZero() || imported.IgnoreUntil.After(existing.IgnoreUntil))) {
+		merged.Ignore = true
+		merged.IgnoreUntil = imported.IgnoreUntil
+		merged.IgnoreReason = imported.IgnoreReason
+		merged.IgnoredBy = imported.IgnoredBy
+		merged.IgnoreDate = imported.IgnoreDate
+	}
+	if merged.CreateDate.IsZero() {
+		merged.CreateDate = imported.CreateDate
+	}
+	if merged.Status == "" {
+		merged.Status = imported.Status
+	}
+	if merged.SelfLink == "" {
+		merged.SelfLink = imported.SelfLink
+	}
+	if merged.Project == "" {
+		merged.Project, merged.Location = imported.Project, imported.Location
+	}
+	if merged.ExpiryAction == "" {
+		merged.ExpiryAction = imported.ExpiryAction
+	}
+	if merged.Owner == "" {
+		merged.Owner, merged.OwnerSource = imported.Owner, imported.OwnerSource
+	}
+	if imported.RenewalCount > merged.RenewalCount {
+		merged.RenewalCount, merged.RenewalExtension = imported.RenewalCount, imported.RenewalExtension
+	}
+
+	labels := map[string]string{}
+	for k, v := range imported.Labels {
+		labels[k] = v
+	}
+	for k, v := range existing.Labels {
+		labels[k] = v
+	}
+	merged.Labels = labels
+
+	return merged
+}
+
+// sameRecord reports whether merging changed nothing. The merged labels are a
+// superset of the existing ones, so comparing their lengths is enough.
+func sameRecord(a store.ClusterRecord, b store.ClusterRecord) bool {
+	return a.ExpirationDate.Equal(b.ExpirationDate) &&
+		a.Ignore == b.Ignore &&
+		a.IgnoreUntil.Equal(b.IgnoreUntil) &&
+		a.IgnoreReason == b.IgnoreReason &&
+		a.IgnoredBy == b.IgnoredBy &&
+		a.IgnoreDate.Equal(b.IgnoreDate) &&
+		a.CreateDate.Equal(b.CreateDate) &&
+		a.Status == b.Status &&
+		a.SelfLink == b.SelfLink &&
+		a.Project == b.Project &&
+		a.Location == b.Location &&
+		a.ExpiryAction == b.ExpiryAction &&
+		a.Owner == b.Owner &&
+		a.OwnerSource == b.OwnerSource &&
+		a.RenewalCount == b.RenewalCount &&
+		a.RenewalExtension == b.RenewalExtension &&
+		len(a.Labels) == len(b.Labels)
+}
+
+func writeCSV(w io.Writer, records []store.ClusterRecord) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		labels, err := json.Marshal(record.Labels)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write([]string{
+			record.Kind,
+			record.Name,
+			formatTime(record.CreateDate),
+			formatTime(record.ExpirationDate),
+			strconv.FormatBool(record.Ignore),
+			formatTime(record.IgnoreUntil),
+			record.IgnoreReason,
+			record.IgnoredBy,
+			formatTime(record.IgnoreDate),
+			record.Status,
+			string(labels),
+			record.SelfLink,
+			record.Project,
+			record.Location,
+			record.ExpiryAction,
+			record.ActionTaken,
+			record.Owner,
+			record.OwnerSource,
+			formatTime(record.IdleSince),
+			strconv.FormatFloat(record.HourlyCost, 'f', -1, 64),
+			strconv.Itoa(record.RenewalCount),
+			record.RenewalExtension.String(),
+			formatTime(record.ExpirationBeforeIdle),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// readCSV reads a csv export. Columns are found by the header so they can be
+// in any order, and missing columns are left empty.
+func readCSV(r io.Reader) ([]api.ExportedCluster, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %s", err)
+	}
+	if len(rows) == 0 {
+		return []api.ExportedCluster{}, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	if _, found := columns["Name"]; !found {
+		return nil, fmt.Errorf("csv header has no Name column")
+	}
+
+	clusters := []api.ExportedCluster{}
+	for line, row := range rows[1:] {
+		get := func(column string) string {
+			i, found := columns[column]
+			if !found || i >= len(row) {
+				return ""
+			}
+			return row[i]
+		}
+
+		cluster := api.ExportedCluster{
+			Kind:         get("Kind"),
+			Name:         get("Name"),
+			IgnoreReason: get("IgnoreReason"),
+			IgnoredBy:    get("IgnoredBy"),
+			Status:       get("Status"),
+			SelfLink:     get("SelfLink"),
+			Project:      get("Project"),
+			Location:     get("Location"),
+			ExpiryAction: get("ExpiryAction"),
+			ActionTaken:  get("ActionTaken"),
+			Owner:        get("Owner"),
+			OwnerSource:  get("OwnerSource"),
+		}
+
+		for column, t := range map[string]*time.Time{
+			"CreateDate":     &cluster.CreateDate,
+			"ExpirationDate": &cluster.ExpirationDate,
+			"IgnoreUntil":    &cluster.IgnoreUntil,
+			"IgnoreDate":     &cluster.IgnoreDate,
+			"IdleSince":      &cluster.IdleSince,
+
+			"ExpirationBeforeIdle": &cluster.ExpirationBeforeIdle,
+		} {
+			*t, err = parseTime(get(column))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %s", line+2, column, err)
+			}
+		}
+
+		if value := get("Ignore"); value != "" {
+			cluster.Ignore, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid Ignore: %s", line+2, err)
+			}
+		}
+
+		if value := get("HourlyCost"); value != "" {
+			cluster.HourlyCost, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid HourlyCost: %s", line+2, err)
+			}
+		}
+
+		if value := get("RenewalCount"); value != "" {
+			cluster.RenewalCount, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid RenewalCount: %s", line+2, err)
+			}
+		}
+
+		if value := get("RenewalExtension"); value != "" {
+			cluster.RenewalExtension, err = time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid RenewalExtension: %s", line+2, err)
+			}
+		}
+
+		if value := get("Labels"); value != "" {
+			err = json.Unmarshal([]byte(value), &cluster.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid Labels: %s", line+2, err)
+			}
+		}
+
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,246 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christianang/gke-cleaner/pkg/migrate"
+	"github.com/christianang/gke-cleaner/pkg/store"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+)
+
+var expirationDate = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestStore(t *testing.T) *store.Cluster {
+	dir, err := ioutil.TempDir("", "gke-cleaner-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := store.Open(store.DriverSQLite, "sqlite://"+filepath.Join(dir, "gke-cleaner.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = (&migrate.DB{Log: zapr.NewLogger(zap.NewNop()), DB: db}).Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &store.Cluster{DB: db}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		strategy string
+		expected store.ClusterRecord
+		updated  []string
+		skipped  []string
+	}{
+		{
+			strategy: StrategySkip,
+			expected: store.ClusterRecord{ExpirationDate: expirationDate, Owner: "jane", Labels: map[string]string{"env": "dev"}},
+			skipped:  []string{"dev"},
+		},
+		{
+			strategy: StrategyOverwrite,
+			expected: store.ClusterRecord{ExpirationDate: expirationDate.Add(time.Hour), Ignore: true, IgnoreReason: "demo", Labels: map[string]string{"env": "prod", "team": "data"}},
+			updated:  []string{"dev"},
+		},
+		{
+			strategy: StrategyMerge,
+			expected: store.ClusterRecord{ExpirationDate: expirationDate.Add(time.Hour), Ignore: true, IgnoreReason: "demo", Owner: "jane", Labels: map[string]string{"env": "dev", "team": "data"}},
+			updated:  []string{"dev"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.strategy, func(t *testing.T) {
+			clusterStore := newTestStore(t)
+			ctx := context.Background()
+
+			err := clusterStore.Insert(ctx, store.ClusterRecord{
+				Kind:           "gke-cluster",
+				Name:           "dev",
+				ExpirationDate: expirationDate,
+				Owner:          "jane",
+				Labels:         map[string]string{"env": "dev"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := Import(ctx, clusterStore, []store.ClusterRecord{
+				{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate.Add(time.Hour), Ignore: true, IgnoreReason: "demo", Labels: map[string]string{"env": "prod", "team": "data"}},
+				{Kind: "gke-cluster", Name: "new", ExpirationDate: expirationDate},
+			}, test.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(response.Inserted, []string{"new"}) {
+				t.Errorf("expected new to be inserted, got %v", response.Inserted)
+			}
+			if len(response.Updated) != len(test.updated) || len(response.Skipped) != len(test.skipped) {
+				t.Errorf("expected updated %v and skipped %v, got %v and %v", test.updated, test.skipped, response.Updated, response.Skipped)
+			}
+
+			dev, err := clusterStore.Get(ctx, "gke-cluster", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dev.ExpirationDate.Equal(test.expected.ExpirationDate) ||
+				dev.Ignore != test.expected.Ignore ||
+				dev.IgnoreReason != test.expected.IgnoreReason ||
+				dev.Owner != test.expected.Owner ||
+				!reflect.DeepEqual(dev.Labels, test.expected.Labels) {
+				t.Errorf("expected %+v, got %+v", test.expected, dev)
+			}
+		})
+	}
+}
+
+func TestImportMergeWithoutChangesSkips(t *testing.T) {
+	clusterStore := newTestStore(t)
+	ctx := context.Background()
+
+	record := store.ClusterRecord{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate.Add(time.Hour)}
+	err := clusterStore.Insert(ctx, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record.ExpirationDate = expirationDate
+	response, err := Import(ctx, clusterStore, []store.ClusterRecord{record}, StrategyMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(response.Skipped, []string{"dev"}) || len(response.Updated) != 0 {
+		t.Errorf("expected an earlier expiration to be skipped, got %+v", response)
+	}
+}
+
+func TestImportRejectsDuplicates(t *testing.T) {
+	clusterStore := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := Import(ctx, clusterStore, []store.ClusterRecord{
+		{Kind: "gke-cluster", Name: "new", ExpirationDate: expirationDate},
+		{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate},
+		{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate.Add(time.Hour)},
+	}, StrategyOverwrite)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+
+	records, err := clusterStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected nothing to be imported, got %+v", records)
+	}
+
+	_, err = Import(ctx, clusterStore, []store.ClusterRecord{
+		{Kind: "gke-cluster", Name: "dev", ExpirationDate: expirationDate},
+		{Kind: "compute-instance", Name: "dev", ExpirationDate: expirationDate},
+	}, StrategyOverwrite)
+	if err != nil {
+		t.Errorf("expected resources of different kinds with the same name to be imported, got %s", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := []store.ClusterRecord{
+		{
+			Kind:           "gke-cluster",
+			Name:           "dev",
+			CreateDate:     expirationDate.Add(-8 * time.Hour),
+			ExpirationDate: expirationDate,
+			Ignore:         true,
+			IgnoreUntil:    expirationDate.Add(24 * time.Hour),
+			IgnoreReason:   "demo, friday",
+			Status:         "RUNNING",
+			Labels:         map[string]string{"env": "dev"},
+			SelfLink:       "https://container.googleapis.com/v1/projects/project-a/locations/us-central1-a/clusters/dev",
+			Project:        "project-a",
+			Location:       "us-central1-a",
+			ExpiryAction:   "delete",
+			ActionTaken:    "notified",
+			Owner:          "jane",
+			IdleSince:      expirationDate.Add(-4 * time.Hour),
+
+			ExpirationBeforeIdle: expirationDate.Add(48 * time.Hour),
+		},
+		{Kind: "compute-instance", Name: "vm", ExpirationDate: expirationDate},
+	}
+
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		buffer := &bytes.Buffer{}
+		err := Write(buffer, format, records)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		read, err := Read(buffer, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if len(read) != len(records) {
+			t.Fatalf("%s: expected %d records, got %+v", format, len(records), read)
+		}
+		for i := range records {
+			if !reflect.DeepEqual(ToAPI(read[i]), ToAPI(records[i])) {
+				t.Errorf("%s: expected %+v, got %+v", format, records[i], read[i])
+			}
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	records, err := Read(strings.NewReader("Name,ExpirationDate\ndev,2030-01-02T03:04:05Z\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Kind != "gke-cluster" || !records[0].ExpirationDate.Equal(expirationDate) {
+		t.Errorf("expected a gke cluster from a csv with some of the columns, got %+v", records)
+	}
+
+	for _, input := range []string{
+		"Kind,ExpirationDate\ngke-cluster,2030-01-02T03:04:05Z\n",
+		"Name,ExpirationDate\ndev,tomorrow\n",
+		"Name,Ignore\ndev,maybe\n",
+		"Name,Labels\ndev,env=dev\n",
+		"Name,ExpirationDate\n,2030-01-02T03:04:05Z\n",
+		"Name\n\"dev\n",
+	} {
+		_, err := Read(strings.NewReader(input), FormatCSV)
+		if err == nil {
+			t.Errorf("expected an error reading %q", input)
+		}
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	for path, expected := range map[string]string{
+		"backup.csv":  FormatCSV,
+		"BACKUP.CSV":  FormatCSV,
+		"backup.json": FormatJSON,
+		"backup":      FormatJSON,
+	} {
+		if format := FormatFor(path); format != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, format)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const DeletionBreaker = "deletions"
+
+type Breaker struct {
+	DB *DB
+}
+
+type BreakerRecord struct {
+	Name      string
+	Tripped   bool
+	Reason    string
+	UpdatedAt time.Time
+	ArmedAt   time.Time
+}
+
+func (b *Breaker) Get(ctx context.Context, name string) (BreakerRecord, error) {
+	ctx, cancel := b.DB.WithTimeout(ctx)
+	defer cancel()
+
+	var tripped bool
+	var reason sql.NullString
+	var updatedAt sql.NullTime
+	var armedAt sql.NullTime
+
+	err := b.DB.QueryRowContext(ctx, `
+		SELECT
+			Tripped,
+			Reason,
+			UpdatedAt,
+			ArmedAt
+		FROM Breakers
+		WHERE Name = ?`, name).Scan(&tripped, &reason, &updatedAt, &armedAt)
+	if err == sql.ErrNoRows {
+		return BreakerRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return BreakerRecord{}, err
+	}
+
+	return BreakerRecord{
+		Name:      name,
+		Tripped:   tripped,
+		Reason:    reason.String,
+		UpdatedAt: updatedAt.Time,
+		ArmedAt:   armedAt.Time,
+	}, nil
+}
+
+func (b *Breaker) Trip(ctx context.Context, name string, reason string) error {
+	ctx, cancel := b.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := b.DB.PrepareContext(ctx, `
+		UPDATE Breakers
+		SET Tripped = ?, Reason = ?, UpdatedAt = ?
+		WHERE Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, true, reason, time.Now(), name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Arm re-arms the breaker. The arm time is kept so that what was already due
+// when it was re-armed doesn't trip it again.
+func (b *Breaker) Arm(ctx context.Context, name string, reason string) error {
+	ctx, cancel := b.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := b.DB.PrepareContext(ctx, `
+		UPDATE Breakers
+		SET Tripped = ?, Reason = ?, UpdatedAt = ?, ArmedAt = ?
+		WHERE Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = statement.ExecContext(ctx, false, reason, now, now, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
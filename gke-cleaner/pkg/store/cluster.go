@@ -3,31 +3,70 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
 type Cluster struct {
-	DB *sql.DB
+	DB *DB
 }
 
+// ClusterRecord is a tracked resource. Despite the name it can be of any kind,
+// GKE clusters were the first.
 type ClusterRecord struct {
 	ID             int
+	Kind           string
 	Name           string
 	CreateDate     time.Time
 	ExpirationDate time.Time
 	Ignore         bool
+	IgnoreUntil    time.Time
+	IgnoreReason   string
+	IgnoredBy      string
+	IgnoreDate     time.Time
+	Status         string
+	Labels         map[string]string
+	SelfLink       string
+	Project        string
+	Location       string
+	ExpiryAction   string
+	ActionTaken    string
+	Owner          string
+	OwnerSource    string
+	IdleSince      time.Time
+	HourlyCost     float64
+
+	// ExpirationBeforeIdle is the expiration the cluster had before it was
+	// found idle, it gets it back when it is in use again.
+	ExpirationBeforeIdle time.Time
+
+	// RenewalCount and RenewalExtension are how often and by how much in
+	// total the cluster's expiration was pushed back by renewals.
+	RenewalCount     int
+	RenewalExtension time.Duration
 }
 
-func (c *Cluster) Insert(ctx context.Context, name string, createDate time.Time, expirationDate time.Time, ignore bool) error {
-	statement, err := c.DB.Prepare(`
-		INSERT INTO Clusters (Name, CreateDate, ExpirationDate, IgnoreMe)
-		VALUES (?, ?, ?, ?)
+var ErrNotFound = errors.New("not found")
+
+func (c *Cluster) Insert(ctx context.Context, cluster ClusterRecord) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	labels, err := encodeLabels(cluster.Labels)
+	if err != nil {
+		return err
+	}
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		INSERT INTO Clusters (Kind, Name, CreateDate, ExpirationDate, IgnoreMe, IgnoreUntil, IgnoreReason, IgnoredBy, IgnoreDate, Status, Labels, SelfLink, Project, Location, ExpiryAction, ActionTaken, Owner, OwnerSource, IdleSince, HourlyCost, RenewalCount, RenewalExtension, ExpirationBeforeIdle)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, name, createDate, expirationDate, ignore)
+	_, err = statement.ExecContext(ctx, cluster.Kind, cluster.Name, cluster.CreateDate, cluster.ExpirationDate, cluster.Ignore, nullTime(cluster.IgnoreUntil), cluster.IgnoreReason, cluster.IgnoredBy, nullTime(cluster.IgnoreDate), cluster.Status, labels, cluster.SelfLink, cluster.Project, cluster.Location, cluster.ExpiryAction, cluster.ActionTaken, cluster.Owner, cluster.OwnerSource, nullTime(cluster.IdleSince), cluster.HourlyCost, cluster.RenewalCount, int64(cluster.RenewalExtension.Seconds()), nullTime(cluster.ExpirationBeforeIdle))
 	if err != nil {
 		return err
 	}
@@ -35,16 +74,26 @@ func (c *Cluster) Insert(ctx context.Context, name string, createDate time.Time,
 	return nil
 }
 
-func (c *Cluster) Delete(ctx context.Context, name string) error {
-	statement, err := c.DB.Prepare(`
-		DELETE FROM Clusters
-		WHERE Name=?
+// Update replaces every field of the cluster with the given kind and name.
+func (c *Cluster) Update(ctx context.Context, cluster ClusterRecord) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	labels, err := encodeLabels(cluster.Labels)
+	if err != nil {
+		return err
+	}
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET CreateDate = ?, ExpirationDate = ?, IgnoreMe = ?, IgnoreUntil = ?, IgnoreReason = ?, IgnoredBy = ?, IgnoreDate = ?, Status = ?, Labels = ?, SelfLink = ?, Project = ?, Location = ?, ExpiryAction = ?, ActionTaken = ?, Owner = ?, OwnerSource = ?, IdleSince = ?, HourlyCost = ?, RenewalCount = ?, RenewalExtension = ?, ExpirationBeforeIdle = ?
+		WHERE Kind = ? AND Name = ?
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, name)
+	_, err = statement.ExecContext(ctx, cluster.CreateDate, cluster.ExpirationDate, cluster.Ignore, nullTime(cluster.IgnoreUntil), cluster.IgnoreReason, cluster.IgnoredBy, nullTime(cluster.IgnoreDate), cluster.Status, labels, cluster.SelfLink, cluster.Project, cluster.Location, cluster.ExpiryAction, cluster.ActionTaken, cluster.Owner, cluster.OwnerSource, nullTime(cluster.IdleSince), cluster.HourlyCost, cluster.RenewalCount, int64(cluster.RenewalExtension.Seconds()), nullTime(cluster.ExpirationBeforeIdle), cluster.Kind, cluster.Name)
 	if err != nil {
 		return err
 	}
@@ -52,16 +101,104 @@ func (c *Cluster) Delete(ctx context.Context, name string) error {
 	return nil
 }
 
-func (c *Cluster) List(ctx context.Context) ([]ClusterRecord, error) {
-	var clusters []ClusterRecord
+func (c *Cluster) Delete(ctx context.Context, kind string, name string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, `
-		SELECT
+	statement, err := c.DB.PrepareContext(ctx, `
+		DELETE FROM Clusters
+		WHERE Kind=? AND Name=?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const clusterColumns = `
 			ID,
+			Kind,
 			Name,
+			CreateDate,
 			ExpirationDate,
-			IgnoreMe
+			IgnoreMe,
+			IgnoreUntil,
+			IgnoreReason,
+			IgnoredBy,
+			IgnoreDate,
+			Status,
+			Labels,
+			SelfLink,
+			Project,
+			Location,
+			ExpiryAction,
+			ActionTaken,
+			Owner,
+			OwnerSource,
+			IdleSince,
+			HourlyCost,
+			RenewalCount,
+			RenewalExtension,
+			ExpirationBeforeIdle`
+
+func (c *Cluster) Get(ctx context.Context, kind string, name string) (ClusterRecord, error) {
+	clusters, err := c.query(ctx, `
+		SELECT`+clusterColumns+`
+		FROM Clusters
+		WHERE Kind = ? AND Name = ?`, kind, name)
+	if err != nil {
+		return ClusterRecord{}, err
+	}
+
+	if len(clusters) == 0 {
+		return ClusterRecord{}, ErrNotFound
+	}
+
+	return clusters[0], nil
+}
+
+func (c *Cluster) List(ctx context.Context) ([]ClusterRecord, error) {
+	return c.query(ctx, `
+		SELECT`+clusterColumns+`
 		FROM Clusters`)
+}
+
+// ListByName lists the resources of every kind with the name.
+func (c *Cluster) ListByName(ctx context.Context, name string) ([]ClusterRecord, error) {
+	return c.query(ctx, `
+		SELECT`+clusterColumns+`
+		FROM Clusters
+		WHERE Name = ?
+		ORDER BY Kind`, name)
+}
+
+func (c *Cluster) ListByKind(ctx context.Context, kind string) ([]ClusterRecord, error) {
+	return c.query(ctx, `
+		SELECT`+clusterColumns+`
+		FROM Clusters
+		WHERE Kind = ?`, kind)
+}
+
+func (c *Cluster) ListExpired(ctx context.Context, kind string) ([]ClusterRecord, error) {
+	return c.query(ctx, `
+		SELECT`+clusterColumns+`
+		FROM Clusters
+		WHERE Kind = ? AND ExpirationDate < ?`, kind, time.Now())
+}
+
+func (c *Cluster) query(ctx context.Context, query string, args ...interface{}) ([]ClusterRecord, error) {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	var clusters []ClusterRecord
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return []ClusterRecord{}, err
 	}
@@ -69,20 +206,67 @@ func (c *Cluster) List(ctx context.Context) ([]ClusterRecord, error) {
 
 	for rows.Next() {
 		var id int
+		var kind sql.NullString
 		var name string
+		var createDate sql.NullTime
 		var expirationDate time.Time
 		var ignore bool
+		var ignoreUntil sql.NullTime
+		var ignoreReason sql.NullString
+		var ignoredBy sql.NullString
+		var ignoreDate sql.NullTime
+		var status sql.NullString
+		var labelsStr sql.NullString
+		var selfLink sql.NullString
+		var project sql.NullString
+		var location sql.NullString
+		var expiryAction sql.NullString
+		var actionTaken sql.NullString
+		var owner sql.NullString
+		var ownerSource sql.NullString
+		var idleSince sql.NullTime
+		var hourlyCost sql.NullFloat64
+		var renewalCount sql.NullInt64
+		var renewalExtension sql.NullInt64
+		var expirationBeforeIdle sql.NullTime
+
+		err = rows.Scan(&id, &kind, &name, &createDate, &expirationDate, &ignore, &ignoreUntil, &ignoreReason, &ignoredBy, &ignoreDate, &status, &labelsStr, &selfLink, &project, &location, &expiryAction, &actionTaken, &owner, &ownerSource, &idleSince, &hourlyCost, &renewalCount, &renewalExtension, &expirationBeforeIdle)
+		if err != nil {
+			return []ClusterRecord{}, err
+		}
 
-		err = rows.Scan(&id, &name, &expirationDate, &ignore)
+		labels, err := decodeLabels(labelsStr.String)
 		if err != nil {
 			return []ClusterRecord{}, err
 		}
 
 		clusters = append(clusters, ClusterRecord{
 			ID:             id,
+			Kind:           kind.String,
 			Name:           name,
+			CreateDate:     createDate.Time,
 			ExpirationDate: expirationDate,
 			Ignore:         ignore,
+			IgnoreUntil:    ignoreUntil.Time,
+			IgnoreReason:   ignoreReason.String,
+			IgnoredBy:      ignoredBy.String,
+			IgnoreDate:     ignoreDate.Time,
+			Status:         status.String,
+			Labels:         labels,
+			SelfLink:       selfLink.String,
+			Project:        project.String,
+			Location:       location.String,
+			ExpiryAction:   expiryAction.String,
+			ActionTaken:    actionTaken.String,
+			Owner:          owner.String,
+			OwnerSource:    ownerSource.String,
+			IdleSince:      idleSince.Time,
+			HourlyCost:     hourlyCost.Float64,
+
+			ExpirationBeforeIdle: expirationBeforeIdle.Time,
+
+			RenewalCount:     int(renewalCount.Int64),
+			RenewalExtension: time.Duration(renewalExtension.Int64) * time.Second,
 		})
 	}
 
@@ -94,60 +278,66 @@ func (c *Cluster) List(ctx context.Context) ([]ClusterRecord, error) {
 	return clusters, nil
 }
 
-func (c *Cluster) ListExpired(ctx context.Context) ([]ClusterRecord, error) {
-	var clusters []ClusterRecord
+// UpdateIgnore sets whether the cluster is ignored, and who ignored it since
+// when.
+func (c *Cluster) UpdateIgnore(ctx context.Context, kind string, name string, ignore bool, until time.Time, reason string, ignoredBy string, ignoreDate time.Time) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
 
-	rows, err := c.DB.QueryContext(ctx, `
-		SELECT
-			ID,
-			Name,
-			ExpirationDate,
-			IgnoreMe
-		FROM Clusters
-		WHERE ExpirationDate < ?`, time.Now())
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET IgnoreMe = ?, IgnoreUntil = ?, IgnoreReason = ?, IgnoredBy = ?, IgnoreDate = ?
+		WHERE Kind = ? AND Name = ?
+	`)
 	if err != nil {
-		return []ClusterRecord{}, err
+		return err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id int
-		var name string
-		var expirationDate time.Time
-		var ignore bool
+	_, err = statement.ExecContext(ctx, ignore, nullTime(until), reason, ignoredBy, nullTime(ignoreDate), kind, name)
+	if err != nil {
+		return err
+	}
 
-		err = rows.Scan(&id, &name, &expirationDate, &ignore)
-		if err != nil {
-			return []ClusterRecord{}, err
-		}
+	return nil
+}
 
-		clusters = append(clusters, ClusterRecord{
-			ID:             id,
-			Name:           name,
-			ExpirationDate: expirationDate,
-			Ignore:         ignore,
-		})
+func (c *Cluster) UpdateExpirationDate(ctx context.Context, kind string, name string, expirationDate time.Time) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET ExpirationDate = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
 	}
 
-	err = rows.Err()
+	_, err = statement.ExecContext(ctx, expirationDate, kind, name)
 	if err != nil {
-		return []ClusterRecord{}, err
+		return err
 	}
 
-	return clusters, nil
+	return nil
 }
 
-func (c *Cluster) UpdateIgnore(ctx context.Context, name string, ignore bool) error {
-	statement, err := c.DB.Prepare(`
+// UpdateRenewal sets the expiration of a renewed cluster along with its
+// renewal count and total extension.
+func (c *Cluster) UpdateRenewal(ctx context.Context, kind string, name string, expirationDate time.Time, renewalCount int, renewalExtension time.Duration) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
 		UPDATE Clusters
-		SET IgnoreMe = ?
-		WHERE Name = ?
+		SET ExpirationDate = ?, RenewalCount = ?, RenewalExtension = ?
+		WHERE Kind = ? AND Name = ?
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, ignore, name)
+	_, err = statement.ExecContext(ctx, expirationDate, renewalCount, int64(renewalExtension.Seconds()), kind, name)
 	if err != nil {
 		return err
 	}
@@ -155,17 +345,22 @@ func (c *Cluster) UpdateIgnore(ctx context.Context, name string, ignore bool) er
 	return nil
 }
 
-func (c *Cluster) UpdateExpirationDate(ctx context.Context, name string, expirationDate time.Time) error {
-	statement, err := c.DB.Prepare(`
+// UpdateCreateAndExpirationDate starts a recreated cluster's lifetime over,
+// which also resets its renewals.
+func (c *Cluster) UpdateCreateAndExpirationDate(ctx context.Context, kind string, name string, createDate time.Time, expirationDate time.Time) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
 		UPDATE Clusters
-		SET ExpirationDate = ?
-		WHERE Name = ?
+		SET CreateDate = ?, ExpirationDate = ?, RenewalCount = 0, RenewalExtension = 0
+		WHERE Kind = ? AND Name = ?
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, expirationDate, name)
+	_, err = statement.ExecContext(ctx, createDate, expirationDate, kind, name)
 	if err != nil {
 		return err
 	}
@@ -173,17 +368,46 @@ func (c *Cluster) UpdateExpirationDate(ctx context.Context, name string, expirat
 	return nil
 }
 
-func (c *Cluster) UpdateCreateAndExpirationDate(ctx context.Context, name string, createDate time.Time, expirationDate time.Time) error {
-	statement, err := c.DB.Prepare(`
+func (c *Cluster) UpdateStatus(ctx context.Context, kind string, name string, status string, expirationDate time.Time) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
 		UPDATE Clusters
-		SET CreateDate = ?, ExpirationDate = ?
-		WHERE Name = ?
+		SET Status = ?, ExpirationDate = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, status, expirationDate, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cluster) UpdateLabels(ctx context.Context, kind string, name string, labels map[string]string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	labelsStr, err := encodeLabels(labels)
+	if err != nil {
+		return err
+	}
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET Labels = ?
+		WHERE Kind = ? AND Name = ?
 	`)
 	if err != nil {
 		return err
 	}
 
-	_, err = statement.ExecContext(ctx, createDate, expirationDate, name)
+	_, err = statement.ExecContext(ctx, labelsStr, kind, name)
 	if err != nil {
 		return err
 	}
@@ -191,10 +415,229 @@ func (c *Cluster) UpdateCreateAndExpirationDate(ctx context.Context, name string
 	return nil
 }
 
+// UpdateIdentity sets the self link, project and location that tell the
+// cluster apart from clusters of the same name elsewhere.
+func (c *Cluster) UpdateIdentity(ctx context.Context, kind string, name string, selfLink string, project string, location string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET SelfLink = ?, Project = ?, Location = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, selfLink, project, location, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cluster) UpdateExpiryAction(ctx context.Context, kind string, name string, action string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET ExpiryAction = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, action, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateActionTaken records the expiry action that was taken on the cluster,
+// or clears it when action is empty.
+func (c *Cluster) UpdateActionTaken(ctx context.Context, kind string, name string, action string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET ActionTaken = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, action, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateOwner records the owner of the cluster and where it was found, see
+// the owner package for the sources.
+func (c *Cluster) UpdateOwner(ctx context.Context, kind string, name string, owner string, source string) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET Owner = ?, OwnerSource = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, owner, source, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateIdle records since when the cluster is idle, or that it is in use when
+// idleSince is zero, together with its new expiration date and the one it had
+// before it was idle.
+func (c *Cluster) UpdateIdle(ctx context.Context, kind string, name string, idleSince time.Time, expirationDate time.Time, expirationBeforeIdle time.Time) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET IdleSince = ?, ExpirationDate = ?, ExpirationBeforeIdle = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, nullTime(idleSince), expirationDate, nullTime(expirationBeforeIdle), kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Cluster) UpdateHourlyCost(ctx context.Context, kind string, name string, hourlyCost float64) error {
+	ctx, cancel := c.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := c.DB.PrepareContext(ctx, `
+		UPDATE Clusters
+		SET HourlyCost = ?
+		WHERE Kind = ? AND Name = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, hourlyCost, kind, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func decodeLabels(labelsStr string) (map[string]string, error) {
+	labels := map[string]string{}
+	if labelsStr == "" {
+		return labels, nil
+	}
+
+	err := json.Unmarshal([]byte(labelsStr), &labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// Key identifies the record among resources of every kind.
+func (c *ClusterRecord) Key() string {
+	return c.Kind + "/" + c.Name
+}
+
 func (c *ClusterRecord) GetName() string {
 	return c.Name
 }
 
+func (c *ClusterRecord) IsIgnored(now time.Time) bool {
+	if !c.Ignore {
+		return false
+	}
+
+	return c.IgnoreUntil.IsZero() || now.Before(c.IgnoreUntil)
+}
+
+// IgnoreStart is when the cluster was first ignored, if it still is, so
+// extending an ignore keeps its start. Otherwise it is now.
+func (c *ClusterRecord) IgnoreStart(now time.Time) time.Time {
+	if c.IsIgnored(now) && !c.IgnoreDate.IsZero() {
+		return c.IgnoreDate
+	}
+
+	return now
+}
+
+// Renewed is the cluster renewed at now to expire at expirationDate. The
+// extension is counted from its expiration or, if it already expired, now.
+func (c ClusterRecord) Renewed(expirationDate time.Time, now time.Time) ClusterRecord {
+	from := c.ExpirationDate
+	if from.Before(now) {
+		from = now
+	}
+	if expirationDate.After(from) {
+		c.RenewalExtension += expirationDate.Sub(from)
+	}
+	c.ExpirationDate = expirationDate
+	c.RenewalCount++
+
+	return c
+}
+
+// AgeAtExpiration is how old the cluster will be when it expires, or zero if
+// its creation date is unknown.
+func (c *ClusterRecord) AgeAtExpiration() time.Duration {
+	if c.CreateDate.IsZero() {
+		return 0
+	}
+
+	return c.ExpirationDate.Sub(c.CreateDate)
+}
+
+func (c *ClusterRecord) IsIdle() bool {
+	return !c.IdleSince.IsZero()
+}
+
 func (c *ClusterRecord) GetCreateTime() string {
 	return c.CreateDate.Format(time.RFC3339)
 }
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DB wraps a *sql.DB so that queries written with ? placeholders can be run
+// against every supported driver.
+type DB struct {
+	*sql.DB
+	Driver string
+
+	// Timeout bounds each call the stores make to the database. Zero leaves
+	// calls bounded only by their context.
+	Timeout time.Duration
+
+	tx *sql.Tx
+}
+
+func Open(driver string, uri string) (*DB, error) {
+	driver, dsn, err := ParseURI(driver, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDriver := driver
+	if driver == DriverSQLite {
+		sqlDriver = "sqlite3"
+	}
+
+	db, err := sql.Open(sqlDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DriverSQLite {
+		db.SetMaxOpenConns(1)
+	}
+
+	return &DB{DB: db, Driver: driver}, nil
+}
+
+// ParseURI works out the driver from the uri scheme when no driver is given
+// and converts the uri into a dsn the driver understands.
+func ParseURI(driver string, uri string) (string, string, error) {
+	scheme := ""
+	if i := strings.Index(uri, "://"); i > 0 {
+		scheme = uri[:i]
+	}
+
+	switch scheme {
+	case "mysql":
+		uri = strings.TrimPrefix(uri, "mysql://")
+	case "postgres", "postgresql":
+		scheme = DriverPostgres
+	case "sqlite", "sqlite3":
+		scheme = DriverSQLite
+		uri = uri[strings.Index(uri, "://")+3:]
+	default:
+		scheme = ""
+	}
+
+	if driver == "" {
+		driver = scheme
+	}
+	if driver == "" {
+		driver = DriverMySQL
+	}
+	if scheme != "" && scheme != driver {
+		return "", "", fmt.Errorf("database uri scheme %q does not match driver %q", scheme, driver)
+	}
+
+	switch driver {
+	case DriverMySQL:
+		mysqlConfig, err := mysql.ParseDSN(uri)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse mysql dsn: %s", err)
+		}
+		mysqlConfig.ParseTime = true
+		// Updates report the rows they matched rather than the rows they
+		// changed, so renewing a lease with the values it already has
+		// still counts as renewing it.
+		mysqlConfig.ClientFoundRows = true
+
+		return driver, mysqlConfig.FormatDSN(), nil
+	case DriverPostgres, DriverSQLite:
+		return driver, uri, nil
+	default:
+		return "", "", fmt.Errorf("unknown database driver %q: must be one of mysql, sqlite, postgres", driver)
+	}
+}
+
+func (d *DB) Rebind(query string) string {
+	if d.Driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// WithTimeout derives the context a single store call runs with.
+func (d *DB) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d.Timeout)
+}
+
+// Transaction runs fn with a DB whose calls all go through one transaction.
+// The transaction is committed if fn succeeds and rolled back otherwise, so
+// stores built on the DB passed to fn write all or nothing.
+func (d *DB) Transaction(ctx context.Context, fn func(tx *DB) error) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %s", err)
+	}
+
+	err = fn(&DB{DB: d.DB, Driver: d.Driver, Timeout: d.Timeout, tx: tx})
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			return fmt.Errorf("%s, and failed to roll back: %s", err, rollbackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %s", err)
+	}
+
+	return nil
+}
+
+func (d *DB) Prepare(query string) (*sql.Stmt, error) {
+	if d.tx != nil {
+		return d.tx.Prepare(d.Rebind(query))
+	}
+	return d.DB.Prepare(d.Rebind(query))
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if d.tx != nil {
+		return d.tx.PrepareContext(ctx, d.Rebind(query))
+	}
+	return d.DB.PrepareContext(ctx, d.Rebind(query))
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if d.tx != nil {
+		return d.tx.Exec(d.Rebind(query), args...)
+	}
+	return d.DB.Exec(d.Rebind(query), args...)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if d.tx != nil {
+		return d.tx.ExecContext(ctx, d.Rebind(query), args...)
+	}
+	return d.DB.ExecContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if d.tx != nil {
+		return d.tx.QueryContext(ctx, d.Rebind(query), args...)
+	}
+	return d.DB.QueryContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	if d.tx != nil {
+		return d.tx.QueryRow(d.Rebind(query), args...)
+	}
+	return d.DB.QueryRow(d.Rebind(query), args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if d.tx != nil {
+		return d.tx.QueryRowContext(ctx, d.Rebind(query), args...)
+	}
+	return d.DB.QueryRowContext(ctx, d.Rebind(query), args...)
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Deletion struct {
+	DB *DB
+}
+
+// DeletionRecord keeps what a deleted resource cost, after its cluster record
+// is gone, to report the savings.
+type DeletionRecord struct {
+	ID          int
+	ClusterName string
+	Kind        string
+	Owner       string
+	HourlyCost  float64
+	DeleteDate  time.Time
+}
+
+func (d *Deletion) Insert(ctx context.Context, deletion DeletionRecord) error {
+	ctx, cancel := d.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := d.DB.PrepareContext(ctx, `
+		INSERT INTO Deletions (ClusterName, Kind, Owner, HourlyCost, DeleteDate)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, deletion.ClusterName, deletion.Kind, deletion.Owner, deletion.HourlyCost, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListBetween lists the deletions from from up to but excluding to, oldest
+// first.
+func (d *Deletion) ListBetween(ctx context.Context, from time.Time, to time.Time) ([]DeletionRecord, error) {
+	ctx, cancel := d.DB.WithTimeout(ctx)
+	defer cancel()
+
+	deletions := []DeletionRecord{}
+
+	rows, err := d.DB.QueryContext(ctx, `
+		SELECT
+			ID,
+			ClusterName,
+			Kind,
+			Owner,
+			HourlyCost,
+			DeleteDate
+		FROM Deletions
+		WHERE DeleteDate >= ? AND DeleteDate < ?
+		ORDER BY DeleteDate, ID`, from, to)
+	if err != nil {
+		return []DeletionRecord{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deletion DeletionRecord
+		var kind sql.NullString
+		var owner sql.NullString
+		var hourlyCost sql.NullFloat64
+		err = rows.Scan(&deletion.ID, &deletion.ClusterName, &kind, &owner, &hourlyCost, &deletion.DeleteDate)
+		if err != nil {
+			return []DeletionRecord{}, err
+		}
+		deletion.Kind = kind.String
+		deletion.Owner = owner.String
+		deletion.HourlyCost = hourlyCost.Float64
+
+		deletions = append(deletions, deletion)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return []DeletionRecord{}, err
+	}
+
+	return deletions, nil
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	EventDiscovered = "discovered"
+	EventUpdated    = "updated"
+	EventRemoved    = "removed"
+	EventDeleted    = "deleted"
+	EventRenewed    = "renewed"
+	EventIgnored    = "ignored"
+	EventUnignored  = "unignored"
+	EventStatus     = "status-changed"
+	EventProtected  = "protected"
+	EventMismatch   = "identity-mismatch"
+	EventExpired    = "expired"
+	EventScaledDown = "scaled-down"
+	EventRestored   = "restored"
+	EventAction     = "action-changed"
+	EventRecovered  = "recovered"
+	EventIdle       = "idle"
+	EventActive     = "active"
+
+	EventOrphansFound   = "orphans-found"
+	EventOrphansDeleted = "orphans-deleted"
+
+	EventConfigReloaded     = "config-reloaded"
+	EventConfigReloadFailed = "config-reload-failed"
+	EventLeaderElected      = "leader-elected"
+	EventBreakerTripped     = "breaker-tripped"
+	EventBreakerArmed       = "breaker-armed"
+	EventDeletionsDeferred  = "deletions-deferred"
+	EventImported           = "imported"
+	EventDigestSent         = "digest-sent"
+	EventDeleteFailed       = "delete-failed"
+	EventLabelRefused       = "label-refused"
+)
+
+type Event struct {
+	DB *DB
+}
+
+// EventRecord is something that happened to the resource of Kind named
+// ClusterName, or to the cleaner itself when both are empty.
+type EventRecord struct {
+	ID          int
+	Kind        string
+	ClusterName string
+	Type        string
+	Message     string
+	CreateDate  time.Time
+}
+
+func (e *Event) Insert(ctx context.Context, kind string, clusterName string, eventType string, message string) error {
+	ctx, cancel := e.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := e.DB.PrepareContext(ctx, `
+		INSERT INTO Events (Kind, ClusterName, Type, Message, CreateDate)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, kind, clusterName, eventType, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Event) CountSince(ctx context.Context, eventType string, since time.Time) (int, error) {
+	ctx, cancel := e.DB.WithTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := e.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM Events
+		WHERE Type = ? AND CreateDate >= ?`, eventType, since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Latest returns the most recent event of the type for the cluster, or
+// ErrNotFound.
+func (e *Event) Latest(ctx context.Context, kind string, clusterName string, eventType string) (EventRecord, error) {
+	events, err := e.query(ctx, `
+		SELECT`+eventColumns+`
+		FROM Events
+		WHERE `+eventCluster+` AND Type = ?
+		ORDER BY CreateDate DESC, ID DESC
+		LIMIT 1`, kind, clusterName, eventType)
+	if err != nil {
+		return EventRecord{}, err
+	}
+
+	if len(events) == 0 {
+		return EventRecord{}, ErrNotFound
+	}
+
+	return events[0], nil
+}
+
+func (e *Event) ListByCluster(ctx context.Context, kind string, clusterName string) ([]EventRecord, error) {
+	return e.query(ctx, `
+		SELECT`+eventColumns+`
+		FROM Events
+		WHERE `+eventCluster+`
+		ORDER BY CreateDate, ID`, kind, clusterName)
+}
+
+// ListBetween returns the events of the types from from up to to.
+func (e *Event) ListBetween(ctx context.Context, eventTypes []string, from time.Time, to time.Time) ([]EventRecord, error) {
+	if len(eventTypes) == 0 {
+		return []EventRecord{}, nil
+	}
+
+	args := []interface{}{from, to}
+	placeholders := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		placeholders[i] = "?"
+		args = append(args, eventType)
+	}
+
+	return e.query(ctx, `
+		SELECT`+eventColumns+`
+		FROM Events
+		WHERE CreateDate >= ? AND CreateDate < ? AND Type IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY CreateDate, ID`, args...)
+}
+
+// eventCluster matches the events of a kind and cluster name. Events recorded
+// before events had a kind, of resources that were already gone, have none
+// and match every kind.
+const eventCluster = `(Kind = ? OR Kind IS NULL) AND ClusterName = ?`
+
+const eventColumns = `
+			ID,
+			Kind,
+			ClusterName,
+			Type,
+			Message,
+			CreateDate`
+
+func (e *Event) query(ctx context.Context, query string, args ...interface{}) ([]EventRecord, error) {
+	ctx, cancel := e.DB.WithTimeout(ctx)
+	defer cancel()
+
+	events := []EventRecord{}
+
+	rows, err := e.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []EventRecord{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var kind sql.NullString
+		var name string
+		var eventType string
+		var message sql.NullString
+		var createDate time.Time
+
+		err = rows.Scan(&id, &kind, &name, &eventType, &message, &createDate)
+		if err != nil {
+			return []EventRecord{}, err
+		}
+
+		events = append(events, EventRecord{
+			ID:          id,
+			Kind:        kind.String,
+			ClusterName: name,
+			Type:        eventType,
+			Message:     message.String,
+			CreateDate:  createDate,
+		})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return []EventRecord{}, err
+	}
+
+	return events, nil
+}
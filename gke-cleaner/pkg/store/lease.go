@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Lease struct {
+	DB *DB
+}
+
+type LeaseRecord struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// Acquire takes or renews the lease for holder. It only succeeds if holder
+// already has the lease or the lease has expired.
+func (l *Lease) Acquire(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := l.DB.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	statement, err := l.DB.PrepareContext(ctx, `
+		UPDATE Leases
+		SET Holder = ?, ExpiresAt = ?
+		WHERE Name = ? AND (Holder = ? OR ExpiresAt IS NULL OR ExpiresAt < ?)
+	`)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := statement.ExecContext(ctx, holder, now.Add(ttl), name, holder, now)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func (l *Lease) Release(ctx context.Context, name string, holder string) error {
+	ctx, cancel := l.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := l.DB.PrepareContext(ctx, `
+		UPDATE Leases
+		SET ExpiresAt = NULL
+		WHERE Name = ? AND Holder = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, name, holder)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Lease) Get(ctx context.Context, name string) (LeaseRecord, error) {
+	ctx, cancel := l.DB.WithTimeout(ctx)
+	defer cancel()
+
+	var holder string
+	var expiresAt sql.NullTime
+
+	err := l.DB.QueryRowContext(ctx, `
+		SELECT
+			Holder,
+			ExpiresAt
+		FROM Leases
+		WHERE Name = ?`, name).Scan(&holder, &expiresAt)
+	if err == sql.ErrNoRows {
+		return LeaseRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return LeaseRecord{}, err
+	}
+
+	return LeaseRecord{
+		Name:      name,
+		Holder:    holder,
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
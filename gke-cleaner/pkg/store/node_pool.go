@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+)
+
+type NodePool struct {
+	DB *DB
+}
+
+// NodePoolRecord is the size of a node pool before it was scaled to zero.
+type NodePoolRecord struct {
+	Kind         string
+	ClusterName  string
+	Name         string
+	NodeCount    int
+	Autoscaling  bool
+	MinNodeCount int
+	MaxNodeCount int
+}
+
+func (n *NodePool) Insert(ctx context.Context, nodePool NodePoolRecord) error {
+	ctx, cancel := n.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := n.DB.PrepareContext(ctx, `
+		INSERT INTO NodePools (Kind, ClusterName, Name, NodeCount, Autoscaling, MinNodeCount, MaxNodeCount)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, nodePool.Kind, nodePool.ClusterName, nodePool.Name, nodePool.NodeCount, nodePool.Autoscaling, nodePool.MinNodeCount, nodePool.MaxNodeCount)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (n *NodePool) ListByCluster(ctx context.Context, kind string, clusterName string) ([]NodePoolRecord, error) {
+	ctx, cancel := n.DB.WithTimeout(ctx)
+	defer cancel()
+
+	nodePools := []NodePoolRecord{}
+
+	rows, err := n.DB.QueryContext(ctx, `
+		SELECT
+			Kind,
+			ClusterName,
+			Name,
+			NodeCount,
+			Autoscaling,
+			MinNodeCount,
+			MaxNodeCount
+		FROM NodePools
+		WHERE Kind = ? AND ClusterName = ?
+		ORDER BY ID`, kind, clusterName)
+	if err != nil {
+		return []NodePoolRecord{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nodePool NodePoolRecord
+		err = rows.Scan(&nodePool.Kind, &nodePool.ClusterName, &nodePool.Name, &nodePool.NodeCount, &nodePool.Autoscaling, &nodePool.MinNodeCount, &nodePool.MaxNodeCount)
+		if err != nil {
+			return []NodePoolRecord{}, err
+		}
+
+		nodePools = append(nodePools, nodePool)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return []NodePoolRecord{}, err
+	}
+
+	return nodePools, nil
+}
+
+func (n *NodePool) DeleteByCluster(ctx context.Context, kind string, clusterName string) error {
+	ctx, cancel := n.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := n.DB.PrepareContext(ctx, `
+		DELETE FROM NodePools
+		WHERE Kind = ? AND ClusterName = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, kind, clusterName)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Sweep struct {
+	DB *DB
+}
+
+// SweepRecord identifies the compute resources of a deleted cluster until
+// they are swept. Operation is the cluster's delete operation, the sweep waits
+// for it to finish.
+type SweepRecord struct {
+	ID               int
+	Kind             string
+	ClusterName      string
+	Project          string
+	Location         string
+	Network          string
+	NetworkTags      []string
+	InstancePrefixes []string
+	Zones            []string
+	Operation        string
+	CreateDate       time.Time
+}
+
+func (s *Sweep) Insert(ctx context.Context, sweep SweepRecord) error {
+	ctx, cancel := s.DB.WithTimeout(ctx)
+	defer cancel()
+
+	networkTags, err := json.Marshal(sweep.NetworkTags)
+	if err != nil {
+		return err
+	}
+
+	instancePrefixes, err := json.Marshal(sweep.InstancePrefixes)
+	if err != nil {
+		return err
+	}
+
+	zones, err := json.Marshal(sweep.Zones)
+	if err != nil {
+		return err
+	}
+
+	statement, err := s.DB.PrepareContext(ctx, `
+		INSERT INTO Sweeps (Kind, ClusterName, Project, Location, Network, NetworkTags, InstancePrefixes, Zones, Operation, CreateDate)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, sweep.Kind, sweep.ClusterName, sweep.Project, sweep.Location, sweep.Network, string(networkTags), string(instancePrefixes), string(zones), sweep.Operation, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Sweep) List(ctx context.Context) ([]SweepRecord, error) {
+	ctx, cancel := s.DB.WithTimeout(ctx)
+	defer cancel()
+
+	sweeps := []SweepRecord{}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT
+			ID,
+			Kind,
+			ClusterName,
+			Project,
+			Location,
+			Network,
+			NetworkTags,
+			InstancePrefixes,
+			Zones,
+			Operation,
+			CreateDate
+		FROM Sweeps
+		ORDER BY ID`)
+	if err != nil {
+		return []SweepRecord{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sweep SweepRecord
+		var kind sql.NullString
+		var network sql.NullString
+		var networkTags sql.NullString
+		var instancePrefixes sql.NullString
+		var zones sql.NullString
+		var operation sql.NullString
+		var createDate sql.NullTime
+
+		err = rows.Scan(&sweep.ID, &kind, &sweep.ClusterName, &sweep.Project, &sweep.Location, &network, &networkTags, &instancePrefixes, &zones, &operation, &createDate)
+		if err != nil {
+			return []SweepRecord{}, err
+		}
+
+		sweep.Kind = kind.String
+		sweep.Network = network.String
+		sweep.Operation = operation.String
+		sweep.CreateDate = createDate.Time
+
+		sweep.NetworkTags, err = decodeStrings(networkTags.String)
+		if err != nil {
+			return []SweepRecord{}, err
+		}
+
+		sweep.InstancePrefixes, err = decodeStrings(instancePrefixes.String)
+		if err != nil {
+			return []SweepRecord{}, err
+		}
+
+		sweep.Zones, err = decodeStrings(zones.String)
+		if err != nil {
+			return []SweepRecord{}, err
+		}
+
+		sweeps = append(sweeps, sweep)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return []SweepRecord{}, err
+	}
+
+	return sweeps, nil
+}
+
+func (s *Sweep) Delete(ctx context.Context, id int) error {
+	ctx, cancel := s.DB.WithTimeout(ctx)
+	defer cancel()
+
+	statement, err := s.DB.PrepareContext(ctx, `
+		DELETE FROM Sweeps
+		WHERE ID = ?
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func decodeStrings(str string) ([]string, error) {
+	strs := []string{}
+	if str == "" || str == "null" {
+		return strs, nil
+	}
+
+	err := json.Unmarshal([]byte(str), &strs)
+	if err != nil {
+		return nil, err
+	}
+
+	return strs, nil
+}
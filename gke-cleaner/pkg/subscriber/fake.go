@@ -0,0 +1,117 @@
+package subscriber
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is an in-memory Subscriber. Published messages are delivered in order
+// and nacked messages are delivered again.
+type Fake struct {
+	mutex   sync.Mutex
+	queue   []Message
+	ready   chan struct{}
+	acked   []Message
+	nacked  []Message
+	pending int
+}
+
+func (f *Fake) Publish(message Message) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.queue = append(f.queue, message)
+	f.pending++
+	f.notify()
+}
+
+func (f *Fake) Receive(ctx context.Context, handle func(ctx context.Context, message Message) error) error {
+	for {
+		message, ok := f.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-f.readyChan():
+			}
+			continue
+		}
+
+		err := handle(ctx, message)
+
+		f.mutex.Lock()
+		if err != nil {
+			f.nacked = append(f.nacked, message)
+			f.queue = append(f.queue, message)
+		} else {
+			f.acked = append(f.acked, message)
+			f.pending--
+		}
+		f.mutex.Unlock()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Acked returns every message acked so far.
+func (f *Fake) Acked() []Message {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Message{}, f.acked...)
+}
+
+// Nacked returns every delivery of a message that was nacked so far.
+func (f *Fake) Nacked() []Message {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Message{}, f.nacked...)
+}
+
+// Pending returns how many published messages have not been acked yet.
+func (f *Fake) Pending() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.pending
+}
+
+func (f *Fake) next() (Message, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.queue) == 0 {
+		return Message{}, false
+	}
+
+	message := f.queue[0]
+	f.queue = f.queue[1:]
+
+	return message, true
+}
+
+func (f *Fake) readyChan() chan struct{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.ready == nil {
+		f.ready = make(chan struct{}, 1)
+	}
+
+	return f.ready
+}
+
+// notify wakes up Receive. It must be called with the mutex held.
+func (f *Fake) notify() {
+	if f.ready == nil {
+		f.ready = make(chan struct{}, 1)
+	}
+
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
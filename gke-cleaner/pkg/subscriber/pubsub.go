@@ -0,0 +1,25 @@
+package subscriber
+
+import (
+	"context"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// PubSub receives from a Pub/Sub subscription. The client respects
+// PUBSUB_EMULATOR_HOST, so it also works against the emulator.
+type PubSub struct {
+	Subscription *pubsub.Subscription
+}
+
+func (p *PubSub) Receive(ctx context.Context, f func(ctx context.Context, message Message) error) error {
+	return p.Subscription.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		err := f(ctx, Message{ID: m.ID, Data: m.Data, Attributes: m.Attributes})
+		if err != nil {
+			m.Nack()
+			return
+		}
+
+		m.Ack()
+	})
+}
@@ -0,0 +1,86 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// Message is a Pub/Sub message, stripped down so a fake can produce it.
+type Message struct {
+	ID         string
+	Data       []byte
+	Attributes map[string]string
+}
+
+// Subscriber delivers messages to f until ctx is done. A message is acked if
+// f returns nil and redelivered otherwise.
+type Subscriber interface {
+	Receive(ctx context.Context, f func(ctx context.Context, message Message) error) error
+}
+
+// ClusterEvent says that something happened to a GKE cluster.
+type ClusterEvent struct {
+	Project  string
+	Location string
+	Name     string
+	Source   string
+}
+
+// Parse reads the cluster a message is about. It understands audit log
+// entries of CreateCluster and DeleteCluster calls, exported by a logging
+// sink, and GKE cluster notifications. Any other message is not a cluster
+// event.
+func Parse(message Message) (ClusterEvent, bool) {
+	if name := message.Attributes["cluster_name"]; name != "" {
+		return ClusterEvent{
+			Project:  message.Attributes["project_id"],
+			Location: message.Attributes["cluster_location"],
+			Name:     name,
+			Source:   notificationType(message.Attributes["type_url"]),
+		}, true
+	}
+
+	var entry auditLogEntry
+	err := json.Unmarshal(message.Data, &entry)
+	if err != nil || entry.Resource.Type != "gke_cluster" {
+		return ClusterEvent{}, false
+	}
+
+	method := entry.ProtoPayload.MethodName
+	if !strings.HasSuffix(method, ".CreateCluster") && !strings.HasSuffix(method, ".DeleteCluster") {
+		return ClusterEvent{}, false
+	}
+
+	labels := entry.Resource.Labels
+	if labels["cluster_name"] == "" {
+		return ClusterEvent{}, false
+	}
+
+	return ClusterEvent{
+		Project:  labels["project_id"],
+		Location: labels["location"],
+		Name:     labels["cluster_name"],
+		Source:   method[strings.LastIndex(method, ".")+1:],
+	}, true
+}
+
+type auditLogEntry struct {
+	ProtoPayload struct {
+		MethodName string `json:"methodName"`
+	} `json:"protoPayload"`
+	Resource struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+}
+
+// notificationType returns the event name of a notification type url, e.g.
+// UpgradeEvent for type.googleapis.com/google.container.v1beta1.UpgradeEvent.
+func notificationType(typeURL string) string {
+	if typeURL == "" {
+		return "notification"
+	}
+
+	return typeURL[strings.LastIndex(typeURL, ".")+1:]
+}
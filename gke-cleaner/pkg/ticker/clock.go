@@ -0,0 +1,30 @@
+package ticker
+
+import "time"
+
+// Clock makes the timers a Ticker waits on.
+type Clock interface {
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of a time.Timer a Ticker uses.
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// RealClock makes time.Timers.
+type RealClock struct{}
+
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
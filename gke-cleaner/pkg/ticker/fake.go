@@ -0,0 +1,113 @@
+package ticker
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a Clock whose timers only fire when Fire is called, whatever
+// they were set to.
+type FakeClock struct {
+	mutex  sync.Mutex
+	armed  *sync.Cond
+	timers []*fakeTimer
+	waits  []time.Duration
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	timer := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.timers = append(f.timers, timer)
+	f.arm(timer, d)
+
+	return timer
+}
+
+// Fire waits until a timer is set, then fires every timer that is set.
+func (f *FakeClock) Fire() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for !f.anyArmed() {
+		f.cond().Wait()
+	}
+
+	now := time.Now()
+	for _, timer := range f.timers {
+		if !timer.armed {
+			continue
+		}
+
+		timer.armed = false
+		select {
+		case timer.c <- now:
+		default:
+		}
+	}
+}
+
+// Waits returns every duration a timer was set to so far.
+func (f *FakeClock) Waits() []time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]time.Duration{}, f.waits...)
+}
+
+// arm sets the timer. It must be called with the mutex held.
+func (f *FakeClock) arm(timer *fakeTimer, d time.Duration) bool {
+	wasArmed := timer.armed
+	timer.armed = true
+	f.waits = append(f.waits, d)
+	f.cond().Broadcast()
+
+	return wasArmed
+}
+
+func (f *FakeClock) anyArmed() bool {
+	for _, timer := range f.timers {
+		if timer.armed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cond must be called with the mutex held.
+func (f *FakeClock) cond() *sync.Cond {
+	if f.armed == nil {
+		f.armed = sync.NewCond(&f.mutex)
+	}
+
+	return f.armed
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	c     chan time.Time
+	armed bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	return t.clock.arm(t, d)
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	wasArmed := t.armed
+	t.armed = false
+
+	return wasArmed
+}
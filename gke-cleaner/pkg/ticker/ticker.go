@@ -0,0 +1,74 @@
+package ticker
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Ticker sends the time on C every Interval, each wait made longer or shorter
+// at random by up to Jitter, a fraction of Interval, so that instances started
+// together don't poll together. Like a time.Ticker, ticks are dropped while
+// the receiver is busy.
+type Ticker struct {
+	C <-chan time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func New(interval time.Duration, jitter float64) *Ticker {
+	return NewWithClock(interval, jitter, RealClock{})
+}
+
+// NewWithClock is New with the timers made by clock.
+func NewWithClock(interval time.Duration, jitter float64, clock Clock) *Ticker {
+	c := make(chan time.Time, 1)
+	t := &Ticker{
+		C:    c,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	go t.run(c, clock, func() time.Duration {
+		return Jittered(interval, jitter, random.Float64())
+	})
+
+	return t
+}
+
+// Stop stops the ticker. No tick is sent once it returns.
+func (t *Ticker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+	<-t.done
+}
+
+func (t *Ticker) run(c chan<- time.Time, clock Clock, next func() time.Duration) {
+	defer close(t.done)
+
+	timer := clock.NewTimer(next())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-timer.C():
+			select {
+			case c <- now:
+			default:
+			}
+			timer.Reset(next())
+		}
+	}
+}
+
+// Jittered is interval moved by jitter, a fraction of it, scaled by r from
+// [0, 1) to [-1, 1).
+func Jittered(interval time.Duration, jitter float64, r float64) time.Duration {
+	return interval + time.Duration((2*r-1)*jitter*float64(interval))
+}
@@ -0,0 +1,68 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJittered(t *testing.T) {
+	tests := []struct {
+		name     string
+		jitter   float64
+		r        float64
+		expected time.Duration
+	}{
+		{name: "no jitter", jitter: 0, r: 0.9, expected: 10 * time.Minute},
+		{name: "shortest wait", jitter: 0.1, r: 0, expected: 9 * time.Minute},
+		{name: "middle", jitter: 0.1, r: 0.5, expected: 10 * time.Minute},
+		{name: "longer wait", jitter: 0.1, r: 0.75, expected: 10*time.Minute + 30*time.Second},
+		{name: "whole interval", jitter: 1, r: 0, expected: 0},
+	}
+
+	for _, test := range tests {
+		if got := Jittered(10*time.Minute, test.jitter, test.r); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestTickerWaitsJitteredIntervals(t *testing.T) {
+	clock := &FakeClock{}
+	ticker := NewWithClock(time.Minute, 0.5, clock)
+
+	for i := 0; i < 5; i++ {
+		clock.Fire()
+		select {
+		case <-ticker.C:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for tick %d", i+1)
+		}
+	}
+	ticker.Stop()
+
+	waits := clock.Waits()
+	if len(waits) < 5 {
+		t.Fatalf("expected at least 5 waits, got %v", waits)
+	}
+	for _, wait := range waits {
+		if wait < 30*time.Second || wait >= 90*time.Second {
+			t.Errorf("expected every wait to be within 30s of a minute, got %s", wait)
+		}
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	clock := &FakeClock{}
+	ticker := NewWithClock(time.Minute, 0, clock)
+
+	clock.Fire()
+	<-ticker.C
+	ticker.Stop()
+	ticker.Stop()
+
+	select {
+	case now := <-ticker.C:
+		t.Errorf("expected no tick once stopped, got %s", now)
+	case <-time.After(50 * time.Millisecond):
+	}
+}